@@ -9,15 +9,19 @@ import (
 
 	"github.com/baranovskis/go-ytdlp-bot/internal/bot"
 	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/cookies"
 	"github.com/baranovskis/go-ytdlp-bot/internal/dashboard"
 	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
 	"github.com/baranovskis/go-ytdlp-bot/internal/logger"
+	"github.com/baranovskis/go-ytdlp-bot/internal/retention"
+	"github.com/baranovskis/go-ytdlp-bot/internal/updater"
+	botytdlp "github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
 	"github.com/lrstanley/go-ytdlp"
+	"github.com/rs/zerolog"
 )
 
 func main() {
-	ytdlp.MustInstall(context.TODO(), nil)
-
 	configPath := flag.String("c", "./config.yaml", "path to go-ytdlp-bot config")
 	flag.Parse()
 
@@ -52,31 +56,42 @@ func main() {
 	}
 
 	// Seed config filters into DB (only if table is empty)
-	var seedFilters []struct {
-		Hosts              []string
-		ExcludeQueryParams bool
-		PathRegex          string
-		CookiesFile        string
-	}
+	var seedFilters []database.URLFilter
 	for _, f := range cfg.Bot.Filter {
-		seedFilters = append(seedFilters, struct {
-			Hosts              []string
-			ExcludeQueryParams bool
-			PathRegex          string
-			CookiesFile        string
-		}{
+		// The same allow-list as filters edited on the dashboard.
+		if err := botytdlp.ValidateExtraArgs(f.ExtraArgs); err != nil {
+			log.Fatal().
+				Str("reason", err.Error()).
+				Strs("hosts", f.Hosts).
+				Msg("invalid extra arguments in config filter")
+		}
+		seedFilters = append(seedFilters, database.URLFilter{
 			Hosts:              f.Hosts,
 			ExcludeQueryParams: f.ExcludeQueryParams,
 			PathRegex:          f.PathRegEx,
 			CookiesFile:        f.CookiesFile,
+			Proxy:              f.Proxy,
+			Impersonate:        f.Impersonate,
+			UserAgent:          f.UserAgent,
+			RateLimit:          f.RateLimit,
+			Format:             f.Format,
+			GeoBypassCountry:   f.GeoBypassCountry,
+			ExtraArgs:          f.ExtraArgs,
+			Postprocess:        f.Postprocess,
+			Profile:            f.Profile,
 		})
 	}
 	if err := db.SeedFilters(seedFilters); err != nil {
 		log.Fatal().Str("reason", err.Error()).Msg("failed seed filters")
 	}
 
+	if err := dashboard.SeedAdmin(db, cfg.Dashboard, log); err != nil {
+		log.Fatal().Str("reason", err.Error()).Msg("failed seed dashboard admin")
+	}
+
 	// Re-create logger with DB writer for log capture
-	dbWriter := logger.NewDBWriter(db)
+	dbLevel, _ := zerolog.ParseLevel(cfg.Retention.GetLogLevel())
+	dbWriter := logger.NewDBWriter(db, dbLevel)
 	log = logger.GetLoggerWithDB(dbWriter)
 
 	log.Info().Str("path", dbPath).Msg("database initialized")
@@ -84,9 +99,36 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	dash := dashboard.NewServer(cfg.Dashboard, db, log, dbWriter)
+	// Accept whatever version is installed; the updater manages it from here.
+	resolved, err := ytdlp.Install(ctx, &ytdlp.InstallOptions{AllowVersionMismatch: true})
+	if err != nil {
+		log.Fatal().Str("reason", err.Error()).Msg("failed install yt-dlp")
+	}
+
+	ytdlpUpdater := updater.New(resolved.Executable, cfg.YtDlp, log)
+	go ytdlpUpdater.Run(ctx)
+
+	pruner := retention.New(db, cfg.Retention, log)
+	go pruner.Run(ctx)
+
+	cookieStore, err := cookies.NewStore(cfg.Storage.GetCookiesPath())
+	if err != nil {
+		log.Fatal().Str("reason", err.Error()).Msg("failed open cookie store")
+	}
+
+	jobRegistry := jobs.NewRegistry()
+
+	botApi := bot.Init(cfg, log, db, jobRegistry)
+
+	dash := dashboard.NewServer(cfg.Dashboard, db, log, dbWriter, cookieStore, ytdlpUpdater, jobRegistry, botApi.Cache, botApi, pruner, cfg.Video.ProfileNames())
 	go dash.Run(ctx)
 
-	botApi := bot.Init(cfg, log, db)
 	botApi.Run(ctx)
+
+	// Let an update cut short by shutdown finish rolling back, pruning
+	// finish its current batch and cancelled retries record their status
+	// before the database closes.
+	ytdlpUpdater.Wait()
+	pruner.Wait()
+	botApi.Wait()
 }
@@ -3,36 +3,61 @@ package bot
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/baranovskis/go-ytdlp-bot/internal/cache"
 	"github.com/baranovskis/go-ytdlp-bot/internal/config"
 	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+	"github.com/baranovskis/go-ytdlp-bot/internal/logger"
+	"github.com/baranovskis/go-ytdlp-bot/internal/postprocess"
 	"github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/rs/zerolog"
 )
 
+const maxTelegramFileSize = 50 * 1024 * 1024 // 50 MB
+
+var errFileTooLarge = errors.New("file exceeds Telegram 50 MB upload limit")
+
 type Bot struct {
 	API    *bot.Bot
 	Config *config.Config
 	Logger zerolog.Logger
 	Cache  *cache.Cache
 	DB     *database.DB
+	Jobs   *jobs.Registry
+
+	// encodeSlots bounds how many post-processing pipelines run at once.
+	encodeSlots chan struct{}
+
+	lifetime context.Context // from Run; ends at shutdown
+	retries  sync.WaitGroup
 }
 
-func Init(config *config.Config, log zerolog.Logger, db *database.DB) *Bot {
+func Init(config *config.Config, log zerolog.Logger, db *database.DB, jobRegistry *jobs.Registry) *Bot {
 	return &Bot{
 		Config: config,
 		Logger: log,
 		Cache:  cache.New(config.Cache.GetTTL(), config.Storage.RemoveAfterReply, log),
 		DB:     db,
+		Jobs:   jobRegistry,
+
+		encodeSlots: make(chan struct{}, config.Resources.GetMaxEncodes()),
 	}
 }
 
@@ -44,11 +69,18 @@ func (b *Bot) Run(ctx context.Context) {
 			Msg("failed create new bot api instance")
 	}
 
+	b.lifetime = ctx
 	b.API = botAPI
 
+	b.API.RegisterHandlerMatchFunc(b.matchCancelCommand, b.cancelHandler)
+	b.API.RegisterHandlerMatchFunc(b.matchPlaylistCommand, b.playlistHandler)
+	b.API.RegisterHandlerMatchFunc(b.matchSubscribeCommand, b.subscribeHandler)
+	b.API.RegisterHandlerMatchFunc(b.matchUnsubscribeCommand, b.unsubscribeHandler)
 	b.API.RegisterHandlerMatchFunc(b.matchVideoHostFunc, b.downloadVideoHandler)
 	b.API.RegisterHandlerMatchFunc(b.matchMyChatMember, b.myChatMemberHandler)
 
+	go b.watchSubscriptions(ctx)
+
 	b.API.Start(ctx)
 }
 
@@ -164,60 +196,87 @@ func (b *Bot) downloadVideoHandler(ctx context.Context, chat *bot.Bot, update *m
 		Str("url", u.String()).
 		Msg("triggered video download")
 
-	var cookiesFile string
-	dbFilters, _ := b.DB.ListFilters()
-	for _, filter := range dbFilters {
-		if slices.Contains(filter.Hosts, u.Host) {
-			if filter.ExcludeQueryParams {
-				u.RawQuery = ""
-			}
-
-			if len(filter.CookiesFile) > 0 {
-				cookiesFile = filter.CookiesFile
-			}
-		}
+	matched := b.matchFilter(u.Host)
+	if matched != nil && matched.ExcludeQueryParams {
+		u.RawQuery = ""
 	}
 
 	cleanURL := u.String()
 
-	downloadID, _ := b.DB.InsertDownload(cleanURL, update.Message.From.ID, uname, update.Message.Chat.ID, "pending", "", "")
+	if b.remainingQuota(userID) == 0 {
+		b.Logger.Warn().
+			Int64("user_id", userID).
+			Msg("download refused: daily quota reached")
+		chat.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   "You have reached your daily download limit.",
+			ReplyParameters: &models.ReplyParameters{
+				MessageID: update.Message.ID,
+				ChatID:    update.Message.Chat.ID,
+			},
+		})
+		return
+	}
 
-	result, err := b.Cache.GetOrDownload(ctx, cleanURL, func(_ context.Context) (*cache.Result, error) {
-		command := ytdlp.Init(b.Config, b.Logger)
+	downloadID := b.recordDownload(cleanURL, update.Message.From.ID, uname, update.Message.Chat.ID)
 
-		if cookiesFile != "" {
-			command.Cookies(cookiesFile)
-		}
+	jobCtx, job := b.startJob(ctx, jobs.Job{
+		DownloadID: downloadID,
+		URL:        cleanURL,
+		ChatID:     chatID,
+		MessageID:  update.Message.ID,
+		UserID:     userID,
+		Username:   uname,
+	})
+	defer b.Jobs.Finish(job.ID)
+	log := b.log(jobCtx)
 
-		info, err := command.Run(context.TODO(), cleanURL)
-		if err != nil {
-			return nil, err
+	result, err := b.fetch(jobCtx, job.ID, chatID, cleanURL, matched, downloadID)
+	if err != nil {
+		if b.handleStopped(ctx, jobCtx, chat, update, downloadID, job.ID, err) {
+			return
 		}
 
-		return &cache.Result{
-			FilePath: path.Join(b.Config.Storage.Path, info.Filename),
-			Filename: info.Filename,
-			Title:    info.Title,
-		}, nil
-	})
+		var rejected *rejectedError
+		if errors.As(err, &rejected) {
+			log.Info().
+				Str("url", cleanURL).
+				Str("reason", rejected.Reason).
+				Msg("rejected video download")
+			b.failDownload(downloadID, job.ID, "rejected", rejected.Reason, err)
+			chat.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID: update.Message.Chat.ID,
+				Text:   rejected.Reply,
+				ReplyParameters: &models.ReplyParameters{
+					MessageID: update.Message.ID,
+					ChatID:    update.Message.Chat.ID,
+				},
+			})
+			return
+		}
 
-	if err != nil {
 		if isNoVideoError(err) {
-			b.Logger.Debug().
+			log.Debug().
 				Str("url", cleanURL).
 				Msg("skipped non-video post")
-			if downloadID > 0 {
-				b.DB.UpdateDownloadStatus(downloadID, "skipped", "", err.Error())
-			}
+			b.failDownload(downloadID, job.ID, "skipped", err.Error(), err)
 			return
 		}
 
-		b.Logger.Error().
+		log.Error().
 			Str("url", cleanURL).
 			Str("reason", err.Error()).
 			Msg("failed video download")
-		if downloadID > 0 {
-			b.DB.UpdateDownloadStatus(downloadID, "failed", "", err.Error())
+		b.failDownload(downloadID, job.ID, "failed", err.Error(), err)
+
+		if isLoginRequiredError(err) && matched != nil && matched.CookiesFile != "" {
+			log.Warn().
+				Str("url", cleanURL).
+				Str("cookies_file", matched.CookiesFile).
+				Msg("login required despite cookies, cookie file may be stale")
+			if err := b.DB.RecordCookieLoginFailure(matched.CookiesFile); err != nil {
+				log.Error().Str("reason", err.Error()).Msg("failed record cookie login failure")
+			}
 		}
 
 		errMsg := userFriendlyError(err)
@@ -232,24 +291,24 @@ func (b *Bot) downloadVideoHandler(ctx context.Context, chat *bot.Bot, update *m
 		return
 	}
 
-	if downloadID > 0 {
-		b.DB.UpdateDownloadStatus(downloadID, "success", result.Filename, "")
-	}
-
-	b.Logger.Info().
+	log.Info().
 		Str("url", cleanURL).
 		Str("file", result.Filename).
 		Msg("success video download")
 
-	processedFile, err := os.Open(result.FilePath)
-	if err != nil {
-		b.Logger.Error().
-			Str("path", result.FilePath).
-			Str("reason", err.Error()).
-			Msg("failed video open")
+	b.Jobs.SetPhase(job.ID, jobs.PhaseUpload)
+	uploadCtx, cancelUpload := jobs.WithPhaseTimeout(jobCtx, jobs.PhaseUpload, b.Config.Timeouts.GetUpload())
+	defer cancelUpload()
+
+	if err := b.sendVideo(uploadCtx, chat, chatID, update.Message.ID, result); err != nil {
+		if b.handleStopped(ctx, uploadCtx, chat, update, downloadID, job.ID, err) {
+			return
+		}
+		b.failDownload(downloadID, job.ID, "failed", err.Error(), err)
+
 		chat.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
-			Text:   "Failed to process downloaded video.",
+			Text:   uploadErrorReply(err),
 			ReplyParameters: &models.ReplyParameters{
 				MessageID: update.Message.ID,
 				ChatID:    update.Message.Chat.ID,
@@ -257,61 +316,406 @@ func (b *Bot) downloadVideoHandler(ctx context.Context, chat *bot.Bot, update *m
 		})
 		return
 	}
+
+	b.finishDownload(downloadID, job.ID, result)
+	log.Info().
+		Int("message_id", update.Message.ID).
+		Str("file", result.Filename).
+		Msg("success video upload")
+}
+
+// matchFilter returns the last URL filter covering host, or nil.
+func (b *Bot) matchFilter(host string) *database.URLFilter {
+	filters, err := b.DB.ListFilters()
+	if err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Msg("failed load filters from db")
+		return nil
+	}
+
+	var matched *database.URLFilter
+	for i, filter := range filters {
+		if slices.Contains(filter.Hosts, host) {
+			matched = &filters[i]
+		}
+	}
+	return matched
+}
+
+// remainingQuota returns how many more downloads the user may start in the
+// current 24 hour window, or -1 when no daily quota is configured.
+func (b *Bot) remainingQuota(userID int64) int {
+	quota := b.Config.Bot.DailyQuota
+	if quota <= 0 {
+		return -1
+	}
+
+	used, err := b.DB.CountUserDownloadsSince(userID, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Msg("failed count user downloads")
+		return -1
+	}
+	return max(quota-used, 0)
+}
+
+// startJob registers j with the job registry. The returned context carries
+// a logger that tags every line logged for the job with its download and job
+// IDs; use b.log to get it.
+func (b *Bot) startJob(ctx context.Context, j jobs.Job) (context.Context, *jobs.Job) {
+	jobCtx, job := b.Jobs.Start(ctx, j)
+	l := b.Logger.With().
+		Int64("download_id", j.DownloadID).
+		Int64("job_id", job.ID).
+		Logger()
+	return l.WithContext(jobCtx), job
+}
+
+// log returns the logger for ctx: the job's logger if ctx belongs to a
+// download, otherwise the bot's.
+func (b *Bot) log(ctx context.Context) *zerolog.Logger {
+	return logger.Ctx(ctx, &b.Logger)
+}
+
+// fetch probes url, enforces the download limits, downloads it through the
+// cache and runs the chat's post-processing pipeline on the result.
+func (b *Bot) fetch(jobCtx context.Context, jobID, chatID int64, url string, filter *database.URLFilter, downloadID int64) (*cache.Result, error) {
+	command := ytdlp.Init(b.Config, b.Logger)
+
+	if filter != nil {
+		if err := applyFilterOptions(command, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline, pipelineKey, err := b.pipelineFor(chatID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := b.probe(jobCtx, jobID, command, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkLimits(info, b.Config.Limits); err != nil {
+		return nil, err
+	}
+
+	// Chats with different pipelines must not share processed files.
+	key := cacheKey(url, info) + "|" + pipelineKey
+	command.Tag(fileTag(key))
+	b.recordInfo(downloadID, info, key)
+
+	// The download may be shared with other jobs for the same key, so it
+	// reports its phases and logs through the cache, which passes them on
+	// to every job waiting for it, this one included.
+	onPhase := func(phase string) { b.Jobs.SetPhase(jobID, phase) }
+	return b.Cache.GetOrDownload(jobCtx, key, onPhase, func(dlCtx context.Context) (*cache.Result, error) {
+		cache.SetPhase(dlCtx, jobs.PhaseDownload)
+		runCtx, cancel := jobs.WithPhaseTimeout(dlCtx, jobs.PhaseDownload, b.Config.Timeouts.GetDownload())
+		defer cancel()
+
+		downloaded, err := command.Run(runCtx, url)
+		if err != nil {
+			return nil, err
+		}
+
+		// Waiting for a slot doesn't count towards the encode timeout.
+		cache.SetPhase(dlCtx, jobs.PhaseEncodeWait)
+		select {
+		case b.encodeSlots <- struct{}{}:
+			defer func() { <-b.encodeSlots }()
+		case <-dlCtx.Done():
+			return nil, context.Cause(dlCtx)
+		}
+
+		cache.SetPhase(dlCtx, jobs.PhaseEncode)
+		encodeCtx, cancelEncode := jobs.WithPhaseTimeout(dlCtx, jobs.PhaseEncode, b.Config.Timeouts.GetEncode())
+		defer cancelEncode()
+
+		media := postprocess.Media{
+			Duration: info.Duration,
+			HasAudio: info.HasAudio(),
+		}
+		filePath, err := pipeline.Run(encodeCtx, path.Join(b.Config.Storage.Path, downloaded.Filename), media)
+		if err != nil {
+			return nil, err
+		}
+
+		return &cache.Result{
+			FilePath: filePath,
+			Filename: filepath.Base(filePath),
+			Title:    downloaded.Title,
+			Duration: media.Duration,
+			HasAudio: media.HasAudio,
+		}, nil
+	})
+}
+
+// pipelineFor builds the post-processing pipeline for a download, along with
+// a key identifying its output for the cache. Stages and the encoding profile
+// configured for the chat take precedence over the filter's, which take
+// precedence over the global defaults.
+func (b *Bot) pipelineFor(chatID int64, filter *database.URLFilter) (*postprocess.Pipeline, string, error) {
+	chat := b.Config.Bot.Chat(chatID)
+
+	stages := b.Config.Postprocess.GetStages()
+	profileName := b.Config.Video.GetProfile()
+	if filter != nil {
+		if len(filter.Postprocess) > 0 {
+			stages = filter.Postprocess
+		}
+		if filter.Profile != "" {
+			profileName = filter.Profile
+		}
+	}
+	if len(chat.Postprocess) > 0 {
+		stages = chat.Postprocess
+	}
+	if chat.Profile != "" {
+		profileName = chat.Profile
+	}
+
+	profile, ok := b.Config.Video.LookupProfile(profileName)
+	if !ok {
+		return nil, "", fmt.Errorf("post-processing: unknown encoding profile %q", profileName)
+	}
+
+	parsed, err := postprocess.Parse(stages, postprocess.Options{
+		Encoder:        b.Config.Video.GetEncoder(),
+		Threads:        b.Config.Video.GetThreads(),
+		Profile:        postprocess.Profile(profile),
+		GIFMaxDuration: b.Config.Postprocess.GetGIFMaxDuration(),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("post-processing: %w", err)
+	}
+
+	return &postprocess.Pipeline{
+		FFmpeg: b.Config.Postprocess.GetFFmpeg(),
+		Stages: parsed,
+		Limits: b.Config.Resources.ProcessLimits(),
+		Logger: b.Logger,
+	}, strings.Join(stages, ",") + "|" + profileName, nil
+}
+
+// sendVideo uploads the downloaded file to the chat, as a reply to the
+// replyTo message unless it is zero.
+func (b *Bot) sendVideo(ctx context.Context, chat *bot.Bot, chatID int64, replyTo int, result *cache.Result) error {
+	processedFile, err := os.Open(result.FilePath)
+	if err != nil {
+		b.log(ctx).Error().
+			Str("path", result.FilePath).
+			Str("reason", err.Error()).
+			Msg("failed video open")
+		return err
+	}
 	defer processedFile.Close()
 
-	const maxTelegramFileSize = 50 * 1024 * 1024 // 50 MB
 	if fi, statErr := processedFile.Stat(); statErr == nil && fi.Size() > maxTelegramFileSize {
-		b.Logger.Warn().
+		b.log(ctx).Warn().
 			Str("file", result.Filename).
 			Int64("size_bytes", fi.Size()).
 			Msg("file exceeds Telegram 50 MB upload limit")
-		chat.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "Video is too large to upload (exceeds 50 MB limit).",
-			ReplyParameters: &models.ReplyParameters{
-				MessageID: update.Message.ID,
-				ChatID:    update.Message.Chat.ID,
+		return errFileTooLarge
+	}
+
+	var replyParams *models.ReplyParameters
+	if replyTo != 0 {
+		replyParams = &models.ReplyParameters{
+			MessageID: replyTo,
+			ChatID:    chatID,
+		}
+	}
+
+	// GIFs and short clips without audio are sent as animations so they
+	// autoplay inline. Media groups can't hold animations, so this goes
+	// through sendAnimation in private chats and groups alike.
+	if b.sendAsAnimation(chatID, result) {
+		_, err = chat.SendAnimation(ctx, &bot.SendAnimationParams{
+			ChatID: chatID,
+			Animation: &models.InputFileUpload{
+				Filename: result.Filename,
+				Data:     bufio.NewReader(processedFile),
 			},
+			Duration:        int(result.Duration),
+			Caption:         result.Title,
+			ReplyParameters: replyParams,
 		})
-		return
+	} else {
+		_, err = chat.SendMediaGroup(ctx, &bot.SendMediaGroupParams{
+			ChatID: chatID,
+			Media: []models.InputMedia{
+				&models.InputMediaVideo{
+					Media:           "attach://" + result.Filename,
+					Caption:         result.Title,
+					MediaAttachment: bufio.NewReader(processedFile),
+				},
+			},
+			ReplyParameters: replyParams,
+		})
+	}
+
+	if err != nil {
+		b.log(ctx).Error().
+			Int64("chat_id", chatID).
+			Str("path", processedFile.Name()).
+			Str("error", err.Error()).
+			Msg("failed video to chat upload")
+		return &uploadError{err: err}
+	}
+	return nil
+}
+
+// sendAsAnimation reports whether result should be sent as an animation
+// rather than a video in the given chat.
+func (b *Bot) sendAsAnimation(chatID int64, result *cache.Result) bool {
+	if filepath.Ext(result.Filename) == ".gif" {
+		return true
+	}
+	if !b.Config.Bot.Chat(chatID).AnimationsEnabled() {
+		return false
+	}
+	return postprocess.IsAnimation(postprocess.Media{
+		Duration: result.Duration,
+		HasAudio: result.HasAudio,
+	}, b.Config.Postprocess.GetAnimationMaxDuration())
+}
+
+// probe fetches metadata for the URL within the probe timeout.
+func (b *Bot) probe(ctx context.Context, jobID int64, command *ytdlp.YtDlp, url string) (*ytdlp.Info, error) {
+	b.Jobs.SetPhase(jobID, jobs.PhaseProbe)
+	probeCtx, cancel := jobs.WithPhaseTimeout(ctx, jobs.PhaseProbe, b.Config.Timeouts.GetProbe())
+	defer cancel()
+
+	info, err := command.Probe(probeCtx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	b.log(ctx).Debug().
+		Str("url", url).
+		Str("extractor", info.Extractor).
+		Str("id", info.ID).
+		Float64("duration", info.Duration).
+		Int64("estimated_size", info.EstimatedSize()).
+		Msg("probed video metadata")
+	return info, nil
+}
+
+// cacheKey identifies a video by extractor and ID so different URLs for the
+// same video share a download, falling back to the URL itself.
+func cacheKey(url string, info *ytdlp.Info) string {
+	if info.Extractor == "" || info.ID == "" {
+		return url
+	}
+	return info.Extractor + ":" + info.ID
+}
+
+// fileTag derives a short file name component from a cache key, so every
+// cache entry downloads and processes its own files and removing them never
+// touches another entry's.
+func fileTag(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// handleStopped reports whether err was caused by the job being cancelled,
+// timing out, or the bot shutting down, and if so records and replies accordingly.
+func (b *Bot) handleStopped(ctx, jobCtx context.Context, chat *bot.Bot, update *models.Update, downloadID, jobID int64, err error) bool {
+	if ctx.Err() != nil {
+		b.log(jobCtx).Warn().Msg("download interrupted by shutdown")
+		b.failDownload(downloadID, jobID, "cancelled", "interrupted by shutdown", jobs.ErrCancelled)
+		return true
 	}
 
-	_, err = chat.SendMediaGroup(ctx, &bot.SendMediaGroupParams{
+	cause := jobs.Cause(jobCtx, err)
+
+	var reply string
+	var timeoutErr *jobs.TimeoutError
+	switch {
+	case errors.Is(cause, jobs.ErrCancelled):
+		b.log(jobCtx).Info().Msg("download cancelled")
+		b.failDownload(downloadID, jobID, "cancelled", cause.Error(), cause)
+		reply = "Download cancelled."
+	case errors.As(cause, &timeoutErr):
+		b.log(jobCtx).Warn().
+			Str("phase", timeoutErr.Phase).
+			Msg("download timed out")
+		b.failDownload(downloadID, jobID, "failed", cause.Error(), cause)
+		reply = "Download timed out."
+	default:
+		return false
+	}
+
+	chat.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
-		Media: []models.InputMedia{
-			&models.InputMediaVideo{
-				Media:           "attach://" + result.Filename,
-				Caption:         result.Title,
-				MediaAttachment: bufio.NewReader(processedFile),
-			},
-		},
+		Text:   reply,
 		ReplyParameters: &models.ReplyParameters{
 			MessageID: update.Message.ID,
 			ChatID:    update.Message.Chat.ID,
 		},
 	})
+	return true
+}
 
-	if err != nil {
-		b.Logger.Error().
-			Int64("chat_id", update.Message.Chat.ID).
-			Str("path", processedFile.Name()).
-			Str("error", err.Error()).
-			Msg("failed video to chat upload")
+func (b *Bot) matchCancelCommand(update *models.Update) bool {
+	return isCommand(update, "/cancel")
+}
+
+// isCommand reports whether the message is the given bot command,
+// with or without a @botname suffix.
+func isCommand(update *models.Update, name string) bool {
+	if update.Message == nil {
+		return false
+	}
+	cmd, _, _ := strings.Cut(update.Message.Text, " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	return cmd == name
+}
+
+// cancelHandler stops a running download when its requester replies to the
+// original link message with /cancel.
+func (b *Bot) cancelHandler(ctx context.Context, chat *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if msg.From == nil {
+		return
+	}
+
+	reply := func(text string) {
 		chat.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: update.Message.Chat.ID,
-			Text:   "Failed to upload video. File may be too large.",
+			ChatID: msg.Chat.ID,
+			Text:   text,
 			ReplyParameters: &models.ReplyParameters{
-				MessageID: update.Message.ID,
-				ChatID:    update.Message.Chat.ID,
+				MessageID: msg.ID,
+				ChatID:    msg.Chat.ID,
 			},
 		})
+	}
+
+	if msg.ReplyToMessage == nil {
+		reply("Reply to the link you sent with /cancel to stop its download.")
 		return
 	}
 
-	b.Logger.Info().
-		Int("message_id", update.Message.ID).
-		Str("file", result.Filename).
-		Msg("success video upload")
+	found := b.Jobs.FindByMessage(msg.Chat.ID, msg.ReplyToMessage.ID)
+	if len(found) == 0 {
+		reply("There is no running download for that message.")
+		return
+	}
+
+	if found[0].UserID != msg.From.ID {
+		reply("Only the person who sent the link can cancel this download.")
+		return
+	}
+
+	for _, job := range found {
+		b.Jobs.Cancel(job.ID)
+
+		b.Logger.Info().
+			Int64("job_id", job.ID).
+			Int64("user_id", msg.From.ID).
+			Msg("download cancel requested")
+	}
 }
 
 func (b *Bot) matchMyChatMember(update *models.Update) bool {
@@ -346,12 +750,58 @@ func (b *Bot) myChatMemberHandler(ctx context.Context, chat *bot.Bot, update *mo
 	}
 }
 
+// applyFilterOptions sets the per-filter yt-dlp options on the command.
+func applyFilterOptions(command *ytdlp.YtDlp, filter *database.URLFilter) error {
+	if filter.CookiesFile != "" {
+		command.Cookies(filter.CookiesFile)
+	}
+	if filter.Proxy != "" {
+		command.Proxy(filter.Proxy)
+	}
+	if filter.Impersonate != "" {
+		command.Impersonate(filter.Impersonate)
+	}
+	if filter.UserAgent != "" {
+		command.UserAgent(filter.UserAgent)
+	}
+	if filter.RateLimit != "" {
+		command.LimitRate(filter.RateLimit)
+	}
+	if filter.Format != "" {
+		command.Format(filter.Format)
+	}
+	if filter.GeoBypassCountry != "" {
+		command.GeoBypassCountry(filter.GeoBypassCountry)
+	}
+	return command.ExtraArgs(filter.ExtraArgs)
+}
+
 func isNoVideoError(err error) bool {
 	msg := err.Error()
 	return strings.Contains(msg, "no video in this post") ||
 		strings.Contains(msg, "There is no video in this post")
 }
 
+func isLoginRequiredError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "login required") ||
+		strings.Contains(msg, "Login required") ||
+		strings.Contains(msg, "Sign in to confirm") ||
+		strings.Contains(msg, "use --cookies")
+}
+
+func uploadErrorReply(err error) string {
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &pathErr):
+		return "Failed to process downloaded video."
+	case errors.Is(err, errFileTooLarge):
+		return "Video is too large to upload (exceeds 50 MB limit)."
+	default:
+		return "Failed to upload video. File may be too large."
+	}
+}
+
 func userFriendlyError(err error) string {
 	msg := err.Error()
 	switch {
@@ -371,3 +821,30 @@ func userFriendlyError(err error) string {
 		return "Failed to download video."
 	}
 }
+
+// audit records an administrative command in the audit log. before and after
+// are snapshots of the changed object, nil where it didn't exist.
+func (b *Bot) audit(msg *models.Message, action, target string, before, after any) {
+	actor := msg.From.Username
+	if actor == "" {
+		actor = fmt.Sprintf("%d", msg.From.ID)
+	}
+
+	err := b.DB.InsertAuditEntry(database.AuditEntry{
+		Source: database.AuditSourceTelegram,
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Before: database.AuditJSON(before),
+		After:  database.AuditJSON(after),
+	})
+	if err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Str("action", action).Msg("failed write audit log")
+	}
+}
+
+// auditSubscription is the audit log snapshot of a subscription, matching
+// the dashboard's.
+func auditSubscription(sub database.Subscription) map[string]any {
+	return map[string]any{"id": sub.ID, "chat_id": sub.ChatID, "url": sub.URL, "title": sub.Title}
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/cache"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+	"github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
+)
+
+// uploadError is a failure to send a file to Telegram.
+type uploadError struct {
+	err error
+}
+
+func (e *uploadError) Error() string { return e.err.Error() }
+func (e *uploadError) Unwrap() error { return e.err }
+
+// recordDownload records a pending download and returns its ID, or 0 if it
+// could not be recorded. The download goes ahead either way.
+func (b *Bot) recordDownload(url string, userID int64, username string, chatID int64) int64 {
+	id, err := b.DB.InsertDownload(url, userID, username, chatID, "pending", "", "")
+	if err != nil {
+		b.Logger.Error().Str("url", url).Str("reason", err.Error()).Msg("failed record download")
+		return 0
+	}
+	return id
+}
+
+// recordInfo stores what probing found for the download, and the cache key
+// its file is kept under.
+func (b *Bot) recordInfo(downloadID int64, info *ytdlp.Info, cacheKey string) {
+	if downloadID <= 0 {
+		return
+	}
+	format := info.Format.Format
+	if format == "" {
+		format = info.FormatID
+	}
+	if err := b.DB.RecordDownloadInfo(downloadID, info.Title, info.Extractor, info.Duration, format, cacheKey); err != nil {
+		b.Logger.Error().Int64("download_id", downloadID).Str("reason", err.Error()).Msg("failed record download info")
+	}
+}
+
+// finishDownload records a download whose file was sent.
+func (b *Bot) finishDownload(downloadID, jobID int64, result *cache.Result) {
+	if downloadID <= 0 {
+		return
+	}
+	r := database.DownloadResult{
+		Status:   "success",
+		Filename: result.Filename,
+		Timings:  phaseTimings(b.Jobs.Timings(jobID)),
+	}
+	if fi, err := os.Stat(result.FilePath); err == nil {
+		r.FileSize = fi.Size()
+	}
+	if err := b.DB.FinishDownload(downloadID, r); err != nil {
+		b.Logger.Error().Int64("download_id", downloadID).Str("reason", err.Error()).Msg("failed update download")
+	}
+}
+
+// failDownload records a download that ended with status because of err.
+// message is stored as the error, and may be friendlier than err.
+func (b *Bot) failDownload(downloadID, jobID int64, status, message string, err error) {
+	if downloadID <= 0 {
+		return
+	}
+	r := database.DownloadResult{
+		Status:       status,
+		ErrorMessage: message,
+		ErrorKind:    errorKind(err),
+		Timings:      phaseTimings(b.Jobs.Timings(jobID)),
+	}
+	if err := b.DB.FinishDownload(downloadID, r); err != nil {
+		b.Logger.Error().Int64("download_id", downloadID).Str("reason", err.Error()).Msg("failed update download")
+	}
+}
+
+func phaseTimings(t map[string]time.Duration) database.DownloadTimings {
+	return database.DownloadTimings{
+		Probe:      t[jobs.PhaseProbe],
+		Download:   t[jobs.PhaseDownload],
+		EncodeWait: t[jobs.PhaseEncodeWait],
+		Encode:     t[jobs.PhaseEncode],
+		Upload:     t[jobs.PhaseUpload],
+	}
+}
+
+// errorKind classifies a download error so failures can be grouped without
+// parsing messages.
+func errorKind(err error) string {
+	var rejected *rejectedError
+	var timeoutErr *jobs.TimeoutError
+	var uploadErr *uploadError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, jobs.ErrCancelled):
+		return "cancelled"
+	case errors.As(err, &timeoutErr):
+		return "timeout"
+	case errors.As(err, &rejected):
+		return "rejected"
+	case errors.Is(err, errFileTooLarge):
+		return "too_large"
+	case errors.As(err, &uploadErr):
+		return "upload"
+	case isNoVideoError(err):
+		return "no_video"
+	case isLoginRequiredError(err):
+		return "login_required"
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Private video"):
+		return "private"
+	case strings.Contains(msg, "age-restricted"):
+		return "age_restricted"
+	case strings.Contains(msg, "Video unavailable"):
+		return "unavailable"
+	case strings.Contains(msg, "Requested format is not available"):
+		return "format_unavailable"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
+)
+
+// rejectedError is returned when probed metadata violates a download policy.
+// Reply is sent to the chat as-is.
+type rejectedError struct {
+	Reason string
+	Reply  string
+}
+
+func (e *rejectedError) Error() string {
+	return "rejected: " + e.Reason
+}
+
+// checkLimits enforces the configured policies against probed metadata.
+func checkLimits(info *ytdlp.Info, limits config.Limits) error {
+	if info.Live() {
+		return &rejectedError{
+			Reason: "live stream",
+			Reply:  "Live streams can't be downloaded. Try again once the stream has ended.",
+		}
+	}
+
+	if maxDuration := limits.GetMaxDuration(); maxDuration > 0 {
+		duration := time.Duration(info.Duration * float64(time.Second))
+		if duration > maxDuration {
+			return &rejectedError{
+				Reason: fmt.Sprintf("duration %s exceeds %s", duration.Round(time.Second), maxDuration),
+				Reply:  fmt.Sprintf("Video is too long (%s, limit is %s).", duration.Round(time.Second), maxDuration),
+			}
+		}
+	}
+
+	if maxSize := limits.GetMaxFileSize(); maxSize > 0 {
+		if size := info.EstimatedSize(); size > maxSize {
+			return &rejectedError{
+				Reason: fmt.Sprintf("estimated size %d bytes exceeds %d", size, maxSize),
+				Reply:  fmt.Sprintf("Video is too large to download (about %d MB, limit is %d MB).", size>>20, maxSize>>20),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// playlistRangePattern accepts yt-dlp --playlist-items specs such as "1:10" or "1-3,7".
+var playlistRangePattern = regexp.MustCompile(`^[0-9:,\-]+$`)
+
+type playlistProgress struct {
+	Title  string
+	Total  int
+	Sent   int
+	Failed int
+	Note   string
+}
+
+func (p playlistProgress) String() string {
+	text := fmt.Sprintf("Playlist %q: %d/%d sent", p.Title, p.Sent, p.Total)
+	if p.Failed > 0 {
+		text += fmt.Sprintf(", %d failed", p.Failed)
+	}
+	if p.Note != "" {
+		text += "\n" + p.Note
+	}
+	return text
+}
+
+func (b *Bot) matchPlaylistCommand(update *models.Update) bool {
+	return isCommand(update, "/playlist")
+}
+
+// playlistHandler queues every entry of a playlist or channel as its own job
+// and posts the videos in playlist order. Only bot admins may use it.
+func (b *Bot) playlistHandler(ctx context.Context, chat *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if msg.From == nil {
+		return
+	}
+
+	reply := func(text string) *models.Message {
+		sent, _ := chat.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   text,
+			ReplyParameters: &models.ReplyParameters{
+				MessageID: msg.ID,
+				ChatID:    msg.Chat.ID,
+			},
+		})
+		return sent
+	}
+
+	userID := msg.From.ID
+	if !b.Config.Bot.IsAdmin(userID) {
+		b.Logger.Warn().
+			Int64("user_id", userID).
+			Msg("access denied: /playlist requires admin")
+		reply("Only bot admins can use /playlist.")
+		return
+	}
+
+	args := strings.Fields(msg.Text)[1:]
+	if len(args) == 0 || len(args) > 2 {
+		reply("Usage: /playlist <url> [range], e.g. /playlist https://www.youtube.com/playlist?list=... 1:10")
+		return
+	}
+
+	u, err := url.Parse(args[0])
+	if err != nil || u.Host == "" {
+		reply("Invalid playlist URL.")
+		return
+	}
+
+	maxItems := b.Config.Bot.GetPlaylistMaxItems()
+	items := fmt.Sprintf("1:%d", maxItems)
+	if len(args) == 2 {
+		if !playlistRangePattern.MatchString(args[1]) {
+			reply("Invalid range. Use yt-dlp playlist syntax such as 1:10 or 1-3,7.")
+			return
+		}
+		items = args[1]
+	}
+
+	uname := msg.From.Username
+	if uname == "" {
+		uname = msg.From.FirstName
+	}
+
+	b.Logger.Info().
+		Str("url", u.String()).
+		Str("items", items).
+		Int64("user_id", userID).
+		Msg("triggered playlist download")
+	b.audit(msg, "playlist.queue", u.String(), nil, map[string]any{"chat_id": msg.Chat.ID, "items": items})
+
+	playlist, err := b.listEntries(ctx, u.String(), items)
+	if err != nil {
+		b.Logger.Error().
+			Str("url", u.String()).
+			Str("reason", err.Error()).
+			Msg("failed list playlist")
+		reply(userFriendlyError(err))
+		return
+	}
+
+	entries := playlist.Entries
+	progress := playlistProgress{Title: playlist.Title}
+	if progress.Title == "" {
+		progress.Title = u.String()
+	}
+
+	if len(entries) > maxItems {
+		entries = entries[:maxItems]
+		progress.Note = fmt.Sprintf("Limited to the first %d entries.", maxItems)
+	}
+
+	if remaining := b.remainingQuota(userID); remaining >= 0 && len(entries) > remaining {
+		if remaining == 0 {
+			reply("You have reached your daily download limit.")
+			return
+		}
+		entries = entries[:remaining]
+		progress.Note = fmt.Sprintf("Limited to %d entries by your daily download limit.", remaining)
+	}
+
+	if len(entries) == 0 {
+		reply("No videos found in that playlist.")
+		return
+	}
+	progress.Total = len(entries)
+
+	// Register every entry up front so the whole batch shows in the job list
+	// and can be cancelled before it starts.
+	type queued struct {
+		url        string
+		downloadID int64
+		ctx        context.Context
+		job        *jobs.Job
+	}
+	queue := make([]queued, 0, len(entries))
+	for _, entry := range entries {
+		entryURL := entry.EntryURL()
+		downloadID := b.recordDownload(entryURL, userID, uname, msg.Chat.ID)
+		jobCtx, job := b.startJob(ctx, jobs.Job{
+			DownloadID: downloadID,
+			URL:        entryURL,
+			ChatID:     msg.Chat.ID,
+			MessageID:  msg.ID,
+			UserID:     userID,
+			Username:   uname,
+		})
+		queue = append(queue, queued{url: entryURL, downloadID: downloadID, ctx: jobCtx, job: job})
+	}
+	defer func() {
+		for _, q := range queue {
+			b.Jobs.Finish(q.job.ID)
+		}
+	}()
+
+	status := reply(progress.String())
+
+	for i, q := range queue {
+		err := b.downloadEntry(q.ctx, q.job.ID, chat, msg.Chat.ID, msg.ID, q.url, q.downloadID)
+		if err != nil {
+			progress.Failed++
+		} else {
+			progress.Sent++
+		}
+		b.Jobs.Finish(q.job.ID)
+
+		if status != nil {
+			chat.EditMessageText(ctx, &bot.EditMessageTextParams{
+				ChatID:    msg.Chat.ID,
+				MessageID: status.ID,
+				Text:      progress.String(),
+			})
+		}
+
+		if ctx.Err() != nil {
+			// Entries that never started would otherwise stay pending.
+			for _, rest := range queue[i+1:] {
+				b.failDownload(rest.downloadID, rest.job.ID, "cancelled", "interrupted by shutdown", jobs.ErrCancelled)
+			}
+			return
+		}
+	}
+
+	b.Logger.Info().
+		Str("url", u.String()).
+		Int("sent", progress.Sent).
+		Int("failed", progress.Failed).
+		Msg("playlist download finished")
+}
+
+// downloadEntry downloads and posts one playlist or subscription entry,
+// recording its status. Failures are logged rather than replied to.
+func (b *Bot) downloadEntry(jobCtx context.Context, jobID int64, chat *bot.Bot, chatID int64, replyTo int, entryURL string, downloadID int64) error {
+	result, err := b.fetch(jobCtx, jobID, chatID, entryURL, b.matchFilter(hostOf(entryURL)), downloadID)
+	if err == nil {
+		b.Jobs.SetPhase(jobID, jobs.PhaseUpload)
+		uploadCtx, cancel := jobs.WithPhaseTimeout(jobCtx, jobs.PhaseUpload, b.Config.Timeouts.GetUpload())
+		err = jobs.Cause(uploadCtx, b.sendVideo(uploadCtx, chat, chatID, replyTo, result))
+		cancel()
+	}
+
+	if err == nil {
+		b.finishDownload(downloadID, jobID, result)
+		return nil
+	}
+
+	err = jobs.Cause(jobCtx, err)
+
+	var rejected *rejectedError
+	var status string
+	switch {
+	case errors.Is(err, jobs.ErrCancelled) || jobCtx.Err() != nil:
+		status = "cancelled"
+	case errors.As(err, &rejected):
+		status = "rejected"
+	case isNoVideoError(err):
+		status = "skipped"
+	default:
+		status = "failed"
+	}
+
+	b.log(jobCtx).Warn().
+		Str("url", entryURL).
+		Str("status", status).
+		Str("reason", err.Error()).
+		Msg("entry not sent")
+	b.failDownload(downloadID, jobID, status, err.Error(), err)
+	return err
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"context"
+	"errors"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+)
+
+// Retry downloads d's URL again as a new download and posts it to d's chat
+// without replying to the original message. It returns the new download's
+// ID; the download itself runs in the background and, like any other, is
+// cancelled at shutdown rather than with ctx.
+func (b *Bot) Retry(ctx context.Context, d database.Download) (int64, error) {
+	if b.API == nil {
+		return 0, errors.New("bot is not running")
+	}
+
+	downloadID, err := b.DB.InsertDownload(d.URL, d.TelegramUserID, d.TelegramUsername, d.ChatID, "pending", "", "")
+	if err != nil {
+		return 0, err
+	}
+	if err := b.DB.MarkDownloadRetry(downloadID, d); err != nil {
+		return 0, err
+	}
+
+	b.log(ctx).Info().
+		Int64("download_id", d.ID).
+		Int64("retry_id", downloadID).
+		Str("url", d.URL).
+		Msg("retrying download")
+
+	jobCtx, job := b.startJob(b.lifetime, jobs.Job{
+		DownloadID: downloadID,
+		URL:        d.URL,
+		ChatID:     d.ChatID,
+		UserID:     d.TelegramUserID,
+		Username:   d.TelegramUsername,
+	})
+	b.retries.Add(1)
+	go func() {
+		defer b.retries.Done()
+		defer b.Jobs.Finish(job.ID)
+		b.downloadEntry(jobCtx, job.ID, b.API, d.ChatID, 0, d.URL, downloadID)
+	}()
+
+	return downloadID, nil
+}
+
+// Wait blocks until running retries have finished, including marking the
+// ones cut short by shutdown as cancelled.
+func (b *Bot) Wait() {
+	b.retries.Wait()
+}
+
+// Resend posts d's file to d's chat again from the download cache, without
+// downloading it. It fails if the file is no longer cached.
+func (b *Bot) Resend(ctx context.Context, d database.Download) error {
+	if b.API == nil {
+		return errors.New("bot is not running")
+	}
+	result, ok := b.Cache.Lookup(d.CacheKey)
+	if d.CacheKey == "" || !ok {
+		return errors.New("the file is no longer cached")
+	}
+
+	// Log the resend, and any upload failure, against the original download.
+	ctx = b.Logger.With().Int64("download_id", d.ID).Logger().WithContext(ctx)
+	b.log(ctx).Info().
+		Int64("chat_id", d.ChatID).
+		Str("file", result.Filename).
+		Msg("resending download")
+
+	uploadCtx, cancel := context.WithTimeout(ctx, b.Config.Timeouts.GetUpload())
+	defer cancel()
+	return b.sendVideo(uploadCtx, b.API, d.ChatID, 0, result)
+}
@@ -0,0 +1,291 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+	"github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func (b *Bot) matchSubscribeCommand(update *models.Update) bool {
+	return isCommand(update, "/subscribe")
+}
+
+func (b *Bot) matchUnsubscribeCommand(update *models.Update) bool {
+	return isCommand(update, "/unsubscribe")
+}
+
+// subscribeHandler subscribes an approved chat to a channel. Entries already
+// on the channel are marked as seen so only new uploads get posted.
+func (b *Bot) subscribeHandler(ctx context.Context, chat *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if msg.From == nil || !b.isApprovedChat(msg) {
+		return
+	}
+
+	reply := func(text string) {
+		chat.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   text,
+			ReplyParameters: &models.ReplyParameters{
+				MessageID: msg.ID,
+				ChatID:    msg.Chat.ID,
+			},
+		})
+	}
+
+	args := strings.Fields(msg.Text)[1:]
+	if len(args) != 1 {
+		reply("Usage: /subscribe <channel-url>")
+		return
+	}
+
+	u, err := url.Parse(args[0])
+	if err != nil || u.Host == "" {
+		reply("Invalid channel URL.")
+		return
+	}
+	channelURL := u.String()
+
+	if _, exists, _ := b.DB.FindSubscription(msg.Chat.ID, channelURL); exists {
+		reply("This chat is already subscribed to that channel.")
+		return
+	}
+
+	channel, err := b.listEntries(ctx, channelURL, fmt.Sprintf("1:%d", b.Config.Subscriptions.GetEntries()))
+	if err != nil {
+		b.Logger.Error().
+			Str("url", channelURL).
+			Str("reason", err.Error()).
+			Msg("failed list subscription channel")
+		reply(userFriendlyError(err))
+		return
+	}
+
+	uname := msg.From.Username
+	if uname == "" {
+		uname = msg.From.FirstName
+	}
+
+	created, err := b.DB.InsertSubscription(msg.Chat.ID, channelURL, channel.Title, msg.From.ID, uname)
+	if err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Msg("failed insert subscription")
+		reply("Failed to save subscription.")
+		return
+	}
+	if !created {
+		reply("This chat is already subscribed to that channel.")
+		return
+	}
+
+	sub, _, err := b.DB.FindSubscription(msg.Chat.ID, channelURL)
+	if err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Msg("failed load subscription")
+		return
+	}
+
+	b.seedSubscription(sub, channel)
+	b.audit(msg, "subscription.create", fmt.Sprintf("subscription:%d", sub.ID), nil, auditSubscription(sub))
+
+	b.Logger.Info().
+		Int64("chat_id", msg.Chat.ID).
+		Str("url", channelURL).
+		Int("existing_entries", len(channel.Entries)).
+		Msg("subscription added")
+
+	title := channel.Title
+	if title == "" {
+		title = channelURL
+	}
+	reply(fmt.Sprintf("Subscribed to %s. New uploads will be posted here.", title))
+}
+
+// unsubscribeHandler removes the chat's subscription to a channel.
+func (b *Bot) unsubscribeHandler(ctx context.Context, chat *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if msg.From == nil || !b.isApprovedChat(msg) {
+		return
+	}
+
+	reply := func(text string) {
+		chat.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: msg.Chat.ID,
+			Text:   text,
+			ReplyParameters: &models.ReplyParameters{
+				MessageID: msg.ID,
+				ChatID:    msg.Chat.ID,
+			},
+		})
+	}
+
+	args := strings.Fields(msg.Text)[1:]
+	if len(args) != 1 {
+		reply("Usage: /unsubscribe <channel-url>")
+		return
+	}
+
+	sub, exists, err := b.DB.FindSubscription(msg.Chat.ID, args[0])
+	if err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Msg("failed load subscription")
+		return
+	}
+	if !exists {
+		reply("This chat is not subscribed to that channel.")
+		return
+	}
+
+	if err := b.DB.DeleteSubscription(sub.ID); err != nil {
+		b.Logger.Error().Str("reason", err.Error()).Msg("failed delete subscription")
+		reply("Failed to remove subscription.")
+		return
+	}
+	b.audit(msg, "subscription.delete", fmt.Sprintf("subscription:%d", sub.ID), auditSubscription(sub), nil)
+
+	b.Logger.Info().
+		Int64("chat_id", msg.Chat.ID).
+		Str("url", sub.URL).
+		Msg("subscription removed")
+	reply("Unsubscribed.")
+}
+
+// watchSubscriptions checks every subscription on the configured interval
+// until ctx is cancelled.
+func (b *Bot) watchSubscriptions(ctx context.Context) {
+	ticker := time.NewTicker(b.Config.Subscriptions.GetInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			subs, err := b.DB.ListSubscriptions(0)
+			if err != nil {
+				b.Logger.Error().Str("reason", err.Error()).Msg("failed list subscriptions")
+				continue
+			}
+			for _, sub := range subs {
+				if ctx.Err() != nil {
+					return
+				}
+				b.checkSubscription(ctx, sub)
+			}
+		}
+	}
+}
+
+// checkSubscription lists the channel's latest entries and posts the ones
+// not seen before, oldest first.
+func (b *Bot) checkSubscription(ctx context.Context, sub database.Subscription) {
+	channel, err := b.listEntries(ctx, sub.URL, fmt.Sprintf("1:%d", b.Config.Subscriptions.GetEntries()))
+	if err != nil {
+		b.Logger.Error().
+			Int64("subscription_id", sub.ID).
+			Str("url", sub.URL).
+			Str("reason", err.Error()).
+			Msg("failed check subscription")
+		b.DB.RecordSubscriptionError(sub.ID, err.Error())
+		return
+	}
+
+	// Subscriptions added from the dashboard have never been listed; treat
+	// everything already on the channel as seen.
+	if sub.LastCheckedAt.IsZero() {
+		b.seedSubscription(sub, channel)
+		return
+	}
+	b.DB.MarkSubscriptionChecked(sub.ID, channel.Title)
+
+	// Channels list newest first.
+	entries := slices.Clone(channel.Entries)
+	slices.Reverse(entries)
+
+	for _, entry := range entries {
+		// Marking before downloading means a failing entry is not retried
+		// on every check.
+		isNew, err := b.DB.MarkSubscriptionItemSeen(sub.ID, entryKey(entry))
+		if err != nil {
+			b.Logger.Error().Str("reason", err.Error()).Msg("failed mark subscription item seen")
+			continue
+		}
+		if !isNew {
+			continue
+		}
+
+		entryURL := entry.EntryURL()
+		b.Logger.Info().
+			Int64("subscription_id", sub.ID).
+			Str("url", entryURL).
+			Msg("new subscription entry")
+
+		// Posts are automatic, so they are recorded under no user and count
+		// towards no one's quota.
+		username := fmt.Sprintf("subscription:%d", sub.ID)
+		downloadID := b.recordDownload(entryURL, 0, username, sub.ChatID)
+		jobCtx, job := b.startJob(ctx, jobs.Job{
+			DownloadID: downloadID,
+			URL:        entryURL,
+			ChatID:     sub.ChatID,
+			Username:   username,
+		})
+		b.downloadEntry(jobCtx, job.ID, b.API, sub.ChatID, 0, entryURL, downloadID)
+		b.Jobs.Finish(job.ID)
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// seedSubscription marks the channel's current entries as seen without posting them.
+func (b *Bot) seedSubscription(sub database.Subscription, channel *ytdlp.Info) {
+	for _, entry := range channel.Entries {
+		if _, err := b.DB.MarkSubscriptionItemSeen(sub.ID, entryKey(entry)); err != nil {
+			b.Logger.Error().Str("reason", err.Error()).Msg("failed mark subscription item seen")
+		}
+	}
+	b.DB.MarkSubscriptionChecked(sub.ID, channel.Title)
+}
+
+// listEntries lists the entries of a playlist or channel selected by items,
+// applying the options of the URL filter matching its host.
+func (b *Bot) listEntries(ctx context.Context, listURL, items string) (*ytdlp.Info, error) {
+	command := ytdlp.Init(b.Config, b.Logger)
+	if filter := b.matchFilter(hostOf(listURL)); filter != nil {
+		if err := applyFilterOptions(command, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	listCtx, cancel := jobs.WithPhaseTimeout(ctx, jobs.PhaseProbe, b.Config.Timeouts.GetProbe())
+	defer cancel()
+
+	return command.Playlist(listCtx, listURL, items)
+}
+
+// isApprovedChat reports whether the message comes from an approved group
+// or an approved user's private chat.
+func (b *Bot) isApprovedChat(msg *models.Message) bool {
+	if msg.Chat.Type == "group" || msg.Chat.Type == "supergroup" {
+		allowed, _ := b.DB.IsGroupAllowed(msg.Chat.ID)
+		return allowed
+	}
+	allowed, _ := b.DB.IsUserAllowed(msg.From.ID)
+	return allowed
+}
+
+// entryKey identifies a channel entry for deduplication.
+func entryKey(entry ytdlp.Info) string {
+	if entry.ID != "" {
+		return entry.ID
+	}
+	return entry.EntryURL()
+}
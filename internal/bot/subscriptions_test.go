@@ -0,0 +1,190 @@
+//go:build unix
+
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+	"github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
+	"github.com/rs/zerolog"
+)
+
+// fakeYtDlp lists the channel in $FAKE_YTDLP_CHANNEL and fails to find a
+// video at any other URL, so entries are recorded as skipped without
+// anything being downloaded or sent.
+const fakeYtDlp = `#!/bin/sh
+for arg in "$@"; do
+	if [ "$arg" = --flat-playlist ]; then
+		exec cat "$FAKE_YTDLP_CHANNEL"
+	fi
+done
+echo "ERROR: There is no video in this post" >&2
+exit 1
+`
+
+// subscriptionTest runs checkSubscription against a fake yt-dlp whose
+// channel listing is set with list.
+type subscriptionTest struct {
+	t       *testing.T
+	b       *Bot
+	channel string
+	sub     database.Subscription
+}
+
+func newSubscriptionTest(t *testing.T) *subscriptionTest {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "yt-dlp"), []byte(fakeYtDlp), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	channel := filepath.Join(dir, "channel.json")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(dir, "cache"))
+	t.Setenv("FAKE_YTDLP_CHANNEL", channel)
+
+	db, err := database.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "https://www.youtube.com/@channel"
+	if _, err := db.InsertSubscription(-100, url, "", 1, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	sub, _, err := db.FindSubscription(-100, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Storage.Path = dir
+	b := Init(cfg, zerolog.Nop(), db, jobs.NewRegistry())
+	t.Cleanup(b.Cache.Stop)
+
+	return &subscriptionTest{t: t, b: b, channel: channel, sub: sub}
+}
+
+// list makes the fake yt-dlp list the channel with ids, newest first.
+func (s *subscriptionTest) list(ids ...string) {
+	s.t.Helper()
+	channel := ytdlp.Info{Title: "Channel"}
+	for _, id := range ids {
+		channel.Entries = append(channel.Entries, ytdlp.Info{ID: id, URL: entryURL(id)})
+	}
+	data, err := json.Marshal(channel)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := os.WriteFile(s.channel, data, 0o644); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// check runs checkSubscription with the subscription as stored and returns
+// the URLs of the entries it queued, in order.
+func (s *subscriptionTest) check() []string {
+	s.t.Helper()
+	sub, _, err := s.b.DB.FindSubscription(s.sub.ChatID, s.sub.URL)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	before, _, err := s.b.DB.ListDownloads(database.DownloadFilter{})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	s.b.checkSubscription(context.Background(), sub)
+
+	downloads, _, err := s.b.DB.ListDownloads(database.DownloadFilter{Asc: true})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var queued []string
+	for _, d := range downloads[len(before):] {
+		if d.ChatID != sub.ChatID || d.Status != "skipped" {
+			s.t.Errorf("download %s: chat %d, status %q, want chat %d, status skipped", d.URL, d.ChatID, d.Status, sub.ChatID)
+		}
+		queued = append(queued, d.URL)
+	}
+	return queued
+}
+
+func entryURL(id string) string {
+	return "https://www.youtube.com/watch?v=" + id
+}
+
+func entryURLs(ids ...string) []string {
+	urls := make([]string, len(ids))
+	for i, id := range ids {
+		urls[i] = entryURL(id)
+	}
+	return urls
+}
+
+func TestCheckSubscriptionSeedsFirstRun(t *testing.T) {
+	s := newSubscriptionTest(t)
+	s.list("c", "b", "a")
+
+	if queued := s.check(); len(queued) != 0 {
+		t.Errorf("first check queued %q, want nothing", queued)
+	}
+	sub, _, err := s.b.DB.FindSubscription(s.sub.ChatID, s.sub.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.LastCheckedAt.IsZero() || sub.Title != "Channel" {
+		t.Errorf("subscription after seeding = %+v, want checked with title Channel", sub)
+	}
+
+	// Seeded entries count as seen.
+	s.list("d", "c", "b", "a")
+	if got, want := s.check(), entryURLs("d"); !slices.Equal(got, want) {
+		t.Errorf("second check queued %q, want %q", got, want)
+	}
+}
+
+func TestCheckSubscriptionSkipsSeenEntries(t *testing.T) {
+	s := newSubscriptionTest(t)
+	s.list("b", "a")
+	s.check()
+
+	s.list("c", "b", "a")
+	if got, want := s.check(), entryURLs("c"); !slices.Equal(got, want) {
+		t.Errorf("check queued %q, want %q", got, want)
+	}
+
+	// Entries queued once are not queued again, even when they failed or
+	// fell out of the listing and came back.
+	if queued := s.check(); len(queued) != 0 {
+		t.Errorf("repeated check queued %q, want nothing", queued)
+	}
+	s.list("d", "b")
+	s.check()
+	s.list("d", "c", "b")
+	if queued := s.check(); len(queued) != 0 {
+		t.Errorf("check after relisting queued %q, want nothing", queued)
+	}
+}
+
+func TestCheckSubscriptionQueuesOldestFirst(t *testing.T) {
+	s := newSubscriptionTest(t)
+	s.list("a")
+	s.check()
+
+	// Channels list newest first; new entries are posted in upload order.
+	s.list("e", "d", "c", "a")
+	if got, want := s.check(), entryURLs("c", "d", "e"); !slices.Equal(got, want) {
+		t.Errorf("check queued %q, want %q", got, want)
+	}
+}
@@ -2,10 +2,14 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/baranovskis/go-ytdlp-bot/internal/logger"
 	"github.com/rs/zerolog"
 )
 
@@ -14,9 +18,13 @@ type Result struct {
 	FilePath string
 	Filename string
 	Title    string
+	Duration float64
+	HasAudio bool
 }
 
-// DownloadFunc performs the actual download and returns the result.
+// DownloadFunc performs the actual download and returns the result. It
+// reports its progress with SetPhase and logs through the logger attached
+// to ctx, which reaches every caller waiting for it.
 type DownloadFunc func(ctx context.Context) (*Result, error)
 
 type entry struct {
@@ -24,6 +32,26 @@ type entry struct {
 	ready  chan struct{}
 	err    error
 	expAt  time.Time
+
+	// waiters are the callers waiting for the download. The last one to
+	// give up stops it with cancel.
+	waiters []*waiter
+	cancel  context.CancelCauseFunc
+	phase   string
+}
+
+// waiter is a caller waiting for a download, shared or not.
+type waiter struct {
+	log     *zerolog.Logger
+	onPhase func(phase string)
+}
+
+// progressKey attaches a download's entry to its context for SetPhase.
+type progressKey struct{}
+
+type progress struct {
+	c *Cache
+	e *entry
 }
 
 // Cache coordinates concurrent downloads of the same URL and provides
@@ -52,7 +80,16 @@ func New(ttl time.Duration, removeFiles bool, logger zerolog.Logger) *Cache {
 
 // GetOrDownload returns a cached result or runs downloadFn exactly once per URL.
 // Concurrent callers for the same URL will wait for the single download to complete.
-func (c *Cache) GetOrDownload(ctx context.Context, url string, downloadFn DownloadFunc) (*Result, error) {
+// The download is not tied to any one caller: a caller whose ctx ends stops
+// waiting, and the download is cancelled only once every caller has.
+//
+// Each caller follows the download as if it were its own: onPhase, if not
+// nil, is called with every phase the download enters while the caller
+// waits, starting with the current one, and every line the download logs is
+// logged again through the logger attached to the caller's ctx, if any.
+func (c *Cache) GetOrDownload(ctx context.Context, url string, onPhase func(phase string), downloadFn DownloadFunc) (*Result, error) {
+	log := logger.Ctx(ctx, &c.logger)
+	w := &waiter{log: log, onPhase: onPhase}
 	c.mu.Lock()
 
 	if e, ok := c.entries[url]; ok {
@@ -61,45 +98,228 @@ func (c *Cache) GetOrDownload(ctx context.Context, url string, downloadFn Downlo
 			// Download complete — check if still valid
 			if e.err == nil && time.Now().Before(e.expAt) {
 				c.mu.Unlock()
+				log.Debug().Str("key", url).Msg("cache hit")
 				return e.result, nil
 			}
 			// Expired or errored — remove and re-download
 			delete(c.entries, url)
 		default:
 			// Download in progress — wait for it
+			e.waiters = append(e.waiters, w)
+			phase := e.phase
 			c.mu.Unlock()
-			select {
-			case <-e.ready:
-				return e.result, e.err
-			case <-ctx.Done():
-				return nil, ctx.Err()
+			log.Debug().Str("key", url).Msg("waiting for download in progress")
+			if phase != "" && onPhase != nil {
+				onPhase(phase)
 			}
+			return c.wait(ctx, url, e, w)
 		}
 	}
 
-	// Create new entry
+	// Create new entry. The download keeps ctx's values but not its
+	// cancellation, which wait handles for all callers, nor its logger,
+	// which would tag the lines as this caller's alone.
+	dlCtx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
 	e := &entry{
-		ready: make(chan struct{}),
+		ready:   make(chan struct{}),
+		waiters: []*waiter{w},
+		cancel:  cancel,
 	}
+	shared := zerolog.New(fanout{c: c, e: e})
+	dlCtx = shared.WithContext(context.WithValue(dlCtx, progressKey{}, &progress{c: c, e: e}))
 	c.entries[url] = e
 	c.mu.Unlock()
 
+	log.Debug().Str("key", url).Msg("cache miss")
+
 	// Perform download
-	result, err := downloadFn(ctx)
-	e.result = result
-	e.err = err
-	if err == nil {
-		e.expAt = time.Now().Add(c.ttl)
-	}
-	close(e.ready)
+	go func() {
+		defer cancel(nil)
+		result, err := downloadFn(dlCtx)
+		e.result = result
+		e.err = err
+		if err == nil {
+			e.expAt = time.Now().Add(c.ttl)
+		}
+		close(e.ready)
 
-	if err != nil {
+		if err != nil {
+			c.mu.Lock()
+			if c.entries[url] == e {
+				delete(c.entries, url)
+			}
+			c.mu.Unlock()
+		}
+	}()
+
+	return c.wait(ctx, url, e, w)
+}
+
+// wait waits for e's download to finish or for ctx to end. The last caller
+// to stop waiting cancels the download with ctx's cause and drops the entry,
+// so that later requests start over rather than join a cancelled download.
+func (c *Cache) wait(ctx context.Context, url string, e *entry, w *waiter) (*Result, error) {
+	select {
+	case <-e.ready:
+		c.mu.Lock()
+		e.removeWaiter(w)
+		c.mu.Unlock()
+		return e.result, e.err
+	case <-ctx.Done():
 		c.mu.Lock()
-		delete(c.entries, url)
+		e.removeWaiter(w)
+		if len(e.waiters) == 0 {
+			e.cancel(context.Cause(ctx))
+			if c.entries[url] == e {
+				delete(c.entries, url)
+			}
+		}
 		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (e *entry) removeWaiter(w *waiter) {
+	e.waiters = slices.DeleteFunc(e.waiters, func(x *waiter) bool { return x == w })
+}
+
+// SetPhase records that the download running under ctx has entered phase,
+// telling every caller waiting for it. It does nothing outside a download.
+func SetPhase(ctx context.Context, phase string) {
+	p, ok := ctx.Value(progressKey{}).(*progress)
+	if !ok {
+		return
 	}
+	p.c.mu.Lock()
+	p.e.phase = phase
+	waiters := slices.Clone(p.e.waiters)
+	p.c.mu.Unlock()
 
-	return result, err
+	for _, w := range waiters {
+		if w.onPhase != nil {
+			w.onPhase(phase)
+		}
+	}
+}
+
+// fanout is the writer of a download's logger. It logs every line again
+// through the logger of each caller waiting for the download, so the line
+// carries that caller's fields, such as its download ID.
+type fanout struct {
+	c *Cache
+	e *entry
+}
+
+func (f fanout) Write(p []byte) (int, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(p, &fields); err != nil {
+		return len(p), nil
+	}
+	var levelName, message string
+	json.Unmarshal(fields[zerolog.LevelFieldName], &levelName)
+	json.Unmarshal(fields[zerolog.MessageFieldName], &message)
+	level, err := zerolog.ParseLevel(levelName)
+	if err != nil {
+		level = zerolog.NoLevel
+	}
+	delete(fields, zerolog.LevelFieldName)
+	delete(fields, zerolog.MessageFieldName)
+	values := make(map[string]any, len(fields))
+	for k, v := range fields {
+		values[k] = v
+	}
+
+	f.c.mu.Lock()
+	waiters := slices.Clone(f.e.waiters)
+	f.c.mu.Unlock()
+
+	for _, w := range waiters {
+		w.log.WithLevel(level).Fields(values).Msg(message)
+	}
+	return len(p), nil
+}
+
+// EntryInfo describes a cache entry for listing.
+type EntryInfo struct {
+	Key       string
+	Filename  string
+	Title     string
+	Ready     bool
+	ExpiresAt time.Time
+}
+
+// List returns the current entries sorted by key. Downloads still in
+// progress are included with Ready false.
+func (c *Cache) List() []EntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	infos := make([]EntryInfo, 0, len(c.entries))
+	for key, e := range c.entries {
+		info := EntryInfo{Key: key}
+		select {
+		case <-e.ready:
+			info.Ready = true
+			info.ExpiresAt = e.expAt
+			if e.result != nil {
+				info.Filename = e.result.Filename
+				info.Title = e.result.Title
+			}
+		default:
+		}
+		infos = append(infos, info)
+	}
+	slices.SortFunc(infos, func(a, b EntryInfo) int { return strings.Compare(a.Key, b.Key) })
+	return infos
+}
+
+// Lookup returns the finished, unexpired result cached under key.
+func (c *Cache) Lookup(key string) (*Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	select {
+	case <-e.ready:
+	default:
+		return nil, false
+	}
+	if e.err != nil || e.result == nil || time.Now().After(e.expAt) {
+		return nil, false
+	}
+	return e.result, true
+}
+
+// Remove evicts a finished entry so the next request downloads it again,
+// deleting its file if cached files are removed on expiry. It reports
+// false if there is no such entry or its download is still running.
+func (c *Cache) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	select {
+	case <-e.ready:
+	default:
+		return false
+	}
+
+	if c.removeFiles && e.result != nil {
+		if err := os.Remove(e.result.FilePath); err != nil && !os.IsNotExist(err) {
+			c.logger.Error().
+				Str("path", e.result.FilePath).
+				Str("error", err.Error()).
+				Msg("failed to remove cached file")
+		}
+	}
+	delete(c.entries, key)
+	return true
 }
 
 func (c *Cache) cleanupLoop() {
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// follower is a caller of GetOrDownload that records the phases and log
+// lines it is told about.
+type follower struct {
+	mu     sync.Mutex
+	phases []string
+	logs   bytes.Buffer
+}
+
+func (f *follower) onPhase(phase string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.phases = append(f.phases, phase)
+}
+
+func (f *follower) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logs.Write(p)
+}
+
+// ctx returns a context whose logger tags lines with id, as the bot's job
+// loggers do.
+func (f *follower) ctx(ctx context.Context, id int64) context.Context {
+	l := zerolog.New(f).With().Int64("download_id", id).Logger()
+	return l.WithContext(ctx)
+}
+
+// lines returns the logged lines with the given message.
+func (f *follower) lines(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var lines []map[string]any
+	for _, raw := range strings.Split(strings.TrimSpace(f.logs.String()), "\n") {
+		var line map[string]any
+		if err := json.Unmarshal([]byte(raw), &line); err != nil {
+			t.Fatalf("invalid log line %q: %v", raw, err)
+		}
+		if line[zerolog.MessageFieldName] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func (f *follower) phaseList() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.phases)
+}
+
+func TestGetOrDownloadSharesProgress(t *testing.T) {
+	c := New(time.Minute, false, zerolog.Nop())
+	defer c.Stop()
+
+	var first, second follower
+	started := make(chan struct{})
+	joined := make(chan struct{})
+	download := func(ctx context.Context) (*Result, error) {
+		SetPhase(ctx, "download")
+		close(started)
+		<-joined
+		SetPhase(ctx, "encode")
+		zerolog.Ctx(ctx).Warn().Str("url", "https://example.com/v").Msg("slow encode")
+		return &Result{Title: "v"}, nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := c.GetOrDownload(first.ctx(context.Background(), 1), "k", first.onPhase, download); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := c.GetOrDownload(second.ctx(context.Background(), 2), "k", second.onPhase, nil); err != nil {
+			t.Error(err)
+		}
+	}()
+	// Let the second caller join before the download moves on.
+	for {
+		c.mu.Lock()
+		n := len(c.entries["k"].waiters)
+		c.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(joined)
+	wg.Wait()
+
+	want := []string{"download", "encode"}
+	for i, f := range []*follower{&first, &second} {
+		if got := f.phaseList(); !slices.Equal(got, want) {
+			t.Errorf("caller %d phases = %q, want %q", i+1, got, want)
+		}
+		lines := f.lines(t, "slow encode")
+		if len(lines) != 1 {
+			t.Fatalf("caller %d got %d lines, want 1", i+1, len(lines))
+		}
+		line := lines[0]
+		if line["download_id"] != float64(i+1) || line["level"] != "warn" || line["url"] != "https://example.com/v" {
+			t.Errorf("caller %d line = %v", i+1, line)
+		}
+	}
+}
+
+func TestGetOrDownloadCancelsWhenAllCallersLeave(t *testing.T) {
+	c := New(time.Minute, false, zerolog.Nop())
+	defer c.Stop()
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	download := func(ctx context.Context) (*Result, error) {
+		close(started)
+		<-ctx.Done()
+		cancelled <- context.Cause(ctx)
+		return nil, context.Cause(ctx)
+	}
+
+	errGone := errors.New("caller gone")
+	ctx1, cancel1 := context.WithCancelCause(context.Background())
+	ctx2, cancel2 := context.WithCancelCause(context.Background())
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := c.GetOrDownload(ctx1, "k", nil, download)
+		done <- err
+	}()
+	<-started
+	go func() {
+		_, err := c.GetOrDownload(ctx2, "k", nil, nil)
+		done <- err
+	}()
+	for {
+		c.mu.Lock()
+		n := len(c.entries["k"].waiters)
+		c.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel1(errGone)
+	<-done
+	select {
+	case err := <-cancelled:
+		t.Fatalf("download cancelled with %v while a caller still waits", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel2(errGone)
+	<-done
+	if err := <-cancelled; !errors.Is(err, errGone) {
+		t.Errorf("download cancelled with %v, want %v", err, errGone)
+	}
+}
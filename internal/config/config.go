@@ -1,20 +1,76 @@
 package config
 
 import (
+	"net"
+	"net/netip"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/baranovskis/go-ytdlp-bot/internal/proclimit"
 	"gopkg.in/yaml.v3"
 )
 
 type Storage struct {
 	Path             string `yaml:"path"`
 	RemoveAfterReply bool   `yaml:"removeAfterReply"`
+	CookiesPath      string `yaml:"cookiesPath"`
+}
+
+// GetCookiesPath returns the directory for uploaded cookie files, defaulting to data/cookies.
+func (s *Storage) GetCookiesPath() string {
+	if s.CookiesPath == "" {
+		return "data/cookies"
+	}
+	return s.CookiesPath
 }
 
 type Bot struct {
-	Token  string      `yaml:"token"`
-	Filter []BotFilter `yaml:"filters"`
+	Token            string      `yaml:"token"`
+	Filter           []BotFilter `yaml:"filters"`
+	Admins           []int64     `yaml:"admins"`
+	DailyQuota       int         `yaml:"dailyQuota"`
+	PlaylistMaxItems int         `yaml:"playlistMaxItems"`
+	Chats            []Chat      `yaml:"chats"`
+}
+
+// Chat holds per-chat overrides.
+type Chat struct {
+	ID          int64    `yaml:"id"`
+	Postprocess []string `yaml:"postprocess"`
+	Profile     string   `yaml:"profile"`
+	Animations  *bool    `yaml:"animations"`
+}
+
+// AnimationsEnabled reports whether short silent clips are sent as
+// animations in this chat, which is the default.
+func (c Chat) AnimationsEnabled() bool {
+	return c.Animations == nil || *c.Animations
+}
+
+// Chat returns the overrides for chatID, or a zero Chat if none are configured.
+func (b *Bot) Chat(chatID int64) Chat {
+	for _, c := range b.Chats {
+		if c.ID == chatID {
+			return c
+		}
+	}
+	return Chat{}
+}
+
+// IsAdmin reports whether the Telegram user may run admin commands.
+func (b *Bot) IsAdmin(userID int64) bool {
+	return slices.Contains(b.Admins, userID)
+}
+
+// GetPlaylistMaxItems returns the most entries a /playlist command queues, defaulting to 25.
+func (b *Bot) GetPlaylistMaxItems() int {
+	if b.PlaylistMaxItems <= 0 {
+		return 25
+	}
+	return b.PlaylistMaxItems
 }
 
 type BotFilter struct {
@@ -22,6 +78,15 @@ type BotFilter struct {
 	Hosts              []string `yaml:"hosts"`
 	PathRegEx          string   `yaml:"pathRegEx"`
 	CookiesFile        string   `yaml:"cookiesFile"`
+	Proxy              string   `yaml:"proxy"`
+	Impersonate        string   `yaml:"impersonate"`
+	UserAgent          string   `yaml:"userAgent"`
+	RateLimit          string   `yaml:"rateLimit"`
+	Format             string   `yaml:"format"`
+	GeoBypassCountry   string   `yaml:"geoBypassCountry"`
+	ExtraArgs          []string `yaml:"extraArgs"`
+	Postprocess        []string `yaml:"postprocess"`
+	Profile            string   `yaml:"profile"`
 }
 
 type Cache struct {
@@ -45,15 +110,187 @@ type Database struct {
 }
 
 type Dashboard struct {
-	Port     int    `yaml:"port"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Bind           string   `yaml:"bind"`
+	Port           int      `yaml:"port"`
+	Username       string   `yaml:"username"`
+	Password       string   `yaml:"password"`
+	TrustedProxies []string `yaml:"trustedProxies"`
+	Auth           Auth     `yaml:"auth"`
+	TLS            TLS      `yaml:"tls"`
+}
+
+// GetPort returns the port the dashboard listens on, defaulting to 8080.
+func (d *Dashboard) GetPort() int {
+	if d.Port <= 0 {
+		return 8080
+	}
+	return d.Port
+}
+
+// GetAddr returns the address the dashboard listens on. An empty Bind
+// listens on all interfaces.
+func (d *Dashboard) GetAddr() string {
+	return net.JoinHostPort(d.Bind, strconv.Itoa(d.GetPort()))
+}
+
+// TLS configures HTTPS for the dashboard, from certificate files or from an
+// ACME certificate authority such as Let's Encrypt. Certificate files take
+// precedence when both are set.
+type TLS struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	ACME         ACME   `yaml:"acme"`
+	RedirectPort int    `yaml:"redirectPort"`
+}
+
+// ACME configures certificates obtained automatically for Domains.
+type ACME struct {
+	Domains      []string `yaml:"domains"`
+	Email        string   `yaml:"email"`
+	CacheDir     string   `yaml:"cacheDir"`
+	DirectoryURL string   `yaml:"directoryURL"`
+}
+
+// Enabled reports whether the dashboard serves HTTPS.
+func (t *TLS) Enabled() bool {
+	return t.UsesFiles() || len(t.ACME.Domains) > 0
+}
+
+// UsesFiles reports whether the certificate comes from CertFile and KeyFile.
+func (t *TLS) UsesFiles() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// GetCacheDir returns the directory ACME account keys and certificates are
+// kept in, defaulting to data/acme.
+func (a *ACME) GetCacheDir() string {
+	if a.CacheDir == "" {
+		return "data/acme"
+	}
+	return a.CacheDir
+}
+
+// Auth selects how people sign in to the dashboard: with local accounts,
+// through a reverse proxy that names the signed-in user in a header, or
+// with an OpenID Connect provider.
+type Auth struct {
+	Mode              string              `yaml:"mode"`
+	ProxyUserHeader   string              `yaml:"proxyUserHeader"`
+	ProxyGroupsHeader string              `yaml:"proxyGroupsHeader"`
+	OIDC              OIDC                `yaml:"oidc"`
+	Roles             map[string][]string `yaml:"roles"`
+	DefaultRole       string              `yaml:"defaultRole"`
+}
+
+// OIDC configures the dashboard as an OpenID Connect client.
+type OIDC struct {
+	Issuer        string   `yaml:"issuer"`
+	ClientID      string   `yaml:"clientID"`
+	ClientSecret  string   `yaml:"clientSecret"`
+	RedirectURL   string   `yaml:"redirectURL"`
+	Scopes        []string `yaml:"scopes"`
+	UsernameClaim string   `yaml:"usernameClaim"`
+	GroupsClaim   string   `yaml:"groupsClaim"`
+}
+
+// GetMode returns local, proxy or oidc, defaulting to local.
+func (a *Auth) GetMode() string {
+	switch a.Mode {
+	case "proxy", "oidc":
+		return a.Mode
+	default:
+		return "local"
+	}
+}
+
+// GetProxyUserHeader returns the header a trusted proxy names the signed-in
+// user in, defaulting to X-Forwarded-User.
+func (a *Auth) GetProxyUserHeader() string {
+	if a.ProxyUserHeader == "" {
+		return "X-Forwarded-User"
+	}
+	return a.ProxyUserHeader
+}
+
+// GetDefaultRole returns the role of accounts whose groups match no entry in
+// Roles, defaulting to none, which turns such accounts away.
+func (a *Auth) GetDefaultRole() string {
+	if a.DefaultRole == "" {
+		return "none"
+	}
+	return a.DefaultRole
+}
+
+// GetScopes returns the scopes to request, defaulting to openid, profile
+// and email. openid is always included.
+func (o *OIDC) GetScopes() []string {
+	if len(o.Scopes) == 0 {
+		return []string{"openid", "profile", "email"}
+	}
+	if !slices.Contains(o.Scopes, "openid") {
+		return append([]string{"openid"}, o.Scopes...)
+	}
+	return o.Scopes
+}
+
+// GetUsernameClaim returns the ID token claim used as the account name,
+// defaulting to preferred_username.
+func (o *OIDC) GetUsernameClaim() string {
+	if o.UsernameClaim == "" {
+		return "preferred_username"
+	}
+	return o.UsernameClaim
+}
+
+// GetGroupsClaim returns the ID token claim matched against Auth.Roles,
+// defaulting to groups.
+func (o *OIDC) GetGroupsClaim() string {
+	if o.GroupsClaim == "" {
+		return "groups"
+	}
+	return o.GroupsClaim
+}
+
+// GetTrustedProxies returns the addresses and CIDR ranges of reverse proxies
+// whose X-Forwarded-For header is believed. Invalid entries are skipped.
+func (d *Dashboard) GetTrustedProxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range d.TrustedProxies {
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
 }
 
 type Video struct {
-	MaxHeight int    `yaml:"maxHeight"`
-	Threads   int    `yaml:"threads"`
-	Encoder   string `yaml:"encoder"`
+	MaxHeight int                `yaml:"maxHeight"`
+	Threads   int                `yaml:"threads"`
+	Encoder   string             `yaml:"encoder"`
+	Profile   string             `yaml:"profile"`
+	Profiles  map[string]Profile `yaml:"profiles"`
+}
+
+// Profile is a named set of encoding settings for the transcode stage.
+// CRF is on the scale of the profile's codec; zero values keep the encoder's
+// defaults and no cap.
+type Profile struct {
+	Codec        string `yaml:"codec"`        // h264 (default), hevc or av1
+	CRF          int    `yaml:"crf"`          // constant quality, lower is better
+	MaxBitrate   int    `yaml:"maxBitrate"`   // video bitrate cap in kbit/s
+	AudioBitrate int    `yaml:"audioBitrate"` // AAC bitrate in kbit/s
+	MaxFPS       int    `yaml:"maxFps"`
+	MaxHeight    int    `yaml:"maxHeight"` // downscale taller video, keeping aspect ratio
+}
+
+// builtinProfiles are available without configuration. Entries in
+// video.profiles with the same name replace them.
+var builtinProfiles = map[string]Profile{
+	"small":    {CRF: 28, MaxBitrate: 1000, AudioBitrate: 96, MaxFPS: 30, MaxHeight: 480},
+	"balanced": {CRF: 23, AudioBitrate: 128},
+	"high":     {CRF: 18, AudioBitrate: 192},
 }
 
 // GetMaxHeight returns the max video height, defaulting to 720.
@@ -75,7 +312,8 @@ func (v *Video) GetThreads() int {
 // GetEncoder returns the video encoder. If set to "auto" or left empty,
 // it detects available hardware: VAAPI (/dev/dri/renderD128) first,
 // then falls back to libx264 (CPU).
-// Supported: auto, libx264, h264_nvenc, h264_vaapi, h264_qsv.
+// Supported: auto, libx264, h264_nvenc, h264_vaapi, h264_qsv. The value
+// selects the backend; HEVC and AV1 profiles use its matching encoder.
 func (v *Video) GetEncoder() string {
 	switch v.Encoder {
 	case "h264_nvenc", "h264_vaapi", "h264_qsv", "libx264":
@@ -89,14 +327,321 @@ func (v *Video) GetEncoder() string {
 	}
 }
 
+// GetProfile returns the default encoding profile name, defaulting to "balanced".
+func (v *Video) GetProfile() string {
+	if v.Profile == "" {
+		return "balanced"
+	}
+	return v.Profile
+}
+
+// LookupProfile returns the configured or built-in profile called name.
+func (v *Video) LookupProfile(name string) (Profile, bool) {
+	if p, ok := v.Profiles[name]; ok {
+		return p, true
+	}
+	p, ok := builtinProfiles[name]
+	return p, ok
+}
+
+// ProfileNames returns the names of all configured and built-in profiles, sorted.
+func (v *Video) ProfileNames() []string {
+	names := make([]string, 0, len(builtinProfiles)+len(v.Profiles))
+	for name := range builtinProfiles {
+		names = append(names, name)
+	}
+	for name := range v.Profiles {
+		if _, ok := builtinProfiles[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+type Postprocess struct {
+	FFmpeg               string   `yaml:"ffmpeg"`
+	Stages               []string `yaml:"stages"`
+	GIFMaxDuration       string   `yaml:"gifMaxDuration"`
+	AnimationMaxDuration string   `yaml:"animationMaxDuration"`
+}
+
+// GetFFmpeg returns the ffmpeg executable, defaulting to "ffmpeg" on PATH.
+func (p *Postprocess) GetFFmpeg() string {
+	if p.FFmpeg == "" {
+		return "ffmpeg"
+	}
+	return p.FFmpeg
+}
+
+// GetStages returns the default post-processing stages, defaulting to a
+// single H.264/AAC transcode.
+func (p *Postprocess) GetStages() []string {
+	if len(p.Stages) == 0 {
+		return []string{"transcode"}
+	}
+	return p.Stages
+}
+
+// GetGIFMaxDuration returns the longest silent clip the gif stage converts, defaulting to 15 seconds.
+func (p *Postprocess) GetGIFMaxDuration() time.Duration {
+	return parseDurationOr(p.GIFMaxDuration, 15*time.Second)
+}
+
+// GetAnimationMaxDuration returns the longest clip without audio that is sent
+// as an animation, defaulting to 30 seconds. "0" disables animations.
+func (p *Postprocess) GetAnimationMaxDuration() time.Duration {
+	if p.AnimationMaxDuration == "" {
+		return 30 * time.Second
+	}
+	d, err := time.ParseDuration(p.AnimationMaxDuration)
+	if err != nil || d < 0 {
+		return 30 * time.Second
+	}
+	return d
+}
+
+type YtDlp struct {
+	UpdateChannel  string `yaml:"updateChannel"`
+	UpdateInterval string `yaml:"updateInterval"`
+	SmokeTestURL   string `yaml:"smokeTestURL"`
+}
+
+// GetUpdateTarget returns the --update-to target, defaulting to "stable".
+// A bare version such as "2025.06.30" is pinned on the stable channel.
+func (y *YtDlp) GetUpdateTarget() string {
+	switch {
+	case y.UpdateChannel == "":
+		return "stable"
+	case y.UpdateChannel == "stable", y.UpdateChannel == "nightly", y.UpdateChannel == "master",
+		strings.Contains(y.UpdateChannel, "@"):
+		return y.UpdateChannel
+	default:
+		return "stable@" + y.UpdateChannel
+	}
+}
+
+// GetUpdateInterval returns how often yt-dlp is updated. Zero disables
+// scheduled updates, which is the default.
+func (y *YtDlp) GetUpdateInterval() time.Duration {
+	d, err := time.ParseDuration(y.UpdateInterval)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+type Timeouts struct {
+	Probe    string `yaml:"probe"`
+	Download string `yaml:"download"`
+	Encode   string `yaml:"encode"`
+	Upload   string `yaml:"upload"`
+}
+
+// GetProbe returns the metadata probe timeout, defaulting to 1 minute.
+func (t *Timeouts) GetProbe() time.Duration {
+	return parseDurationOr(t.Probe, time.Minute)
+}
+
+// GetDownload returns the download timeout, defaulting to 10 minutes.
+func (t *Timeouts) GetDownload() time.Duration {
+	return parseDurationOr(t.Download, 10*time.Minute)
+}
+
+// GetEncode returns the ffmpeg encode timeout, defaulting to 10 minutes.
+func (t *Timeouts) GetEncode() time.Duration {
+	return parseDurationOr(t.Encode, 10*time.Minute)
+}
+
+// GetUpload returns the Telegram upload timeout, defaulting to 5 minutes.
+func (t *Timeouts) GetUpload() time.Duration {
+	return parseDurationOr(t.Upload, 5*time.Minute)
+}
+
+type Limits struct {
+	MaxDuration   string `yaml:"maxDuration"`
+	MaxFileSizeMB int    `yaml:"maxFileSizeMB"`
+}
+
+// GetMaxDuration returns the longest video the bot will download. Zero
+// means no limit, which is the default.
+func (l *Limits) GetMaxDuration() time.Duration {
+	d, err := time.ParseDuration(l.MaxDuration)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// GetMaxFileSize returns the estimated download size ceiling in bytes.
+// Zero means no limit, which is the default.
+func (l *Limits) GetMaxFileSize() int64 {
+	if l.MaxFileSizeMB <= 0 {
+		return 0
+	}
+	return int64(l.MaxFileSizeMB) * 1024 * 1024
+}
+
+type Resources struct {
+	MaxEncodes    int    `yaml:"maxEncodes"`
+	Nice          int    `yaml:"nice"`
+	IOClass       string `yaml:"ioClass"`
+	MemoryLimitMB int    `yaml:"memoryLimitMB"`
+}
+
+// GetMaxEncodes returns how many post-processing pipelines may run at once,
+// defaulting to 1.
+func (r *Resources) GetMaxEncodes() int {
+	if r.MaxEncodes <= 0 {
+		return 1
+	}
+	return r.MaxEncodes
+}
+
+// GetNice returns the niceness for yt-dlp and ffmpeg, clamped to 0-19.
+// Zero, the default, keeps the bot's own priority.
+func (r *Resources) GetNice() int {
+	return min(max(r.Nice, 0), 19)
+}
+
+// GetIOClass returns the I/O scheduling class for yt-dlp and ffmpeg:
+// "best-effort", "idle", or "" (the default) to leave it unchanged.
+func (r *Resources) GetIOClass() string {
+	switch r.IOClass {
+	case "best-effort", "idle":
+		return r.IOClass
+	default:
+		return ""
+	}
+}
+
+// GetMemoryLimit returns the per-process address space cap in bytes.
+// Zero means no limit, which is the default.
+func (r *Resources) GetMemoryLimit() int64 {
+	if r.MemoryLimitMB <= 0 {
+		return 0
+	}
+	return int64(r.MemoryLimitMB) * 1024 * 1024
+}
+
+// ProcessLimits returns the limits applied to yt-dlp and ffmpeg processes.
+func (r *Resources) ProcessLimits() proclimit.Limits {
+	return proclimit.Limits{
+		Nice:        r.GetNice(),
+		IOClass:     r.GetIOClass(),
+		MemoryBytes: r.GetMemoryLimit(),
+	}
+}
+
+type Subscriptions struct {
+	Interval string `yaml:"interval"`
+	Entries  int    `yaml:"entries"`
+}
+
+// GetInterval returns how often subscribed channels are checked, defaulting to 30 minutes.
+func (s *Subscriptions) GetInterval() time.Duration {
+	return parseDurationOr(s.Interval, 30*time.Minute)
+}
+
+// GetEntries returns how many of a channel's latest entries each check lists, defaulting to 10.
+func (s *Subscriptions) GetEntries() int {
+	if s.Entries <= 0 {
+		return 10
+	}
+	return s.Entries
+}
+
+// Retention limits how much log and download history the database keeps.
+// Rules in LogLevels apply to lines at that level, on top of Logs.
+type Retention struct {
+	Interval  string                   `yaml:"interval"`
+	LogLevel  string                   `yaml:"logLevel"`
+	Logs      RetentionRule            `yaml:"logs"`
+	LogLevels map[string]RetentionRule `yaml:"logLevels"`
+	Downloads RetentionRule            `yaml:"downloads"`
+}
+
+// RetentionRule removes rows older than MaxAge and all but the newest
+// MaxRows. Zero values keep everything, which is the default.
+type RetentionRule struct {
+	MaxAge  string `yaml:"maxAge"`
+	MaxRows int    `yaml:"maxRows"`
+}
+
+// GetInterval returns how often old rows are pruned, defaulting to 1 hour.
+func (r *Retention) GetInterval() time.Duration {
+	return parseDurationOr(r.Interval, time.Hour)
+}
+
+// GetLogLevel returns the lowest level of log lines stored in the database:
+// debug (the default), info, warn or error. The console shows every line.
+func (r *Retention) GetLogLevel() string {
+	switch level := strings.ToLower(r.LogLevel); level {
+	case "info", "warn", "error":
+		return level
+	default:
+		return "debug"
+	}
+}
+
+// Enabled reports whether any rule limits the history.
+func (r *Retention) Enabled() bool {
+	if r.Logs.Enabled() || r.Downloads.Enabled() {
+		return true
+	}
+	for _, rule := range r.LogLevels {
+		if rule.Enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMaxAge returns how long rows are kept. Zero keeps them regardless of age.
+func (r RetentionRule) GetMaxAge() time.Duration {
+	d, err := time.ParseDuration(r.MaxAge)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// GetMaxRows returns how many of the newest rows are kept. Zero keeps all.
+func (r RetentionRule) GetMaxRows() int {
+	return max(r.MaxRows, 0)
+}
+
+// Enabled reports whether the rule limits anything.
+func (r RetentionRule) Enabled() bool {
+	return r.GetMaxAge() > 0 || r.GetMaxRows() > 0
+}
+
+func parseDurationOr(s string, def time.Duration) time.Duration {
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
 type Config struct {
-	Verbose   bool      `yaml:"verbose"`
-	Storage   Storage   `yaml:"storage"`
-	Bot       Bot       `yaml:"bot"`
-	Cache     Cache     `yaml:"cache"`
-	Database  Database  `yaml:"database"`
-	Dashboard Dashboard `yaml:"dashboard"`
-	Video     Video     `yaml:"video"`
+	Verbose       bool          `yaml:"verbose"`
+	Storage       Storage       `yaml:"storage"`
+	Bot           Bot           `yaml:"bot"`
+	Cache         Cache         `yaml:"cache"`
+	Database      Database      `yaml:"database"`
+	Dashboard     Dashboard     `yaml:"dashboard"`
+	Video         Video         `yaml:"video"`
+	Postprocess   Postprocess   `yaml:"postprocess"`
+	YtDlp         YtDlp         `yaml:"ytdlp"`
+	Timeouts      Timeouts      `yaml:"timeouts"`
+	Limits        Limits        `yaml:"limits"`
+	Subscriptions Subscriptions `yaml:"subscriptions"`
+	Resources     Resources     `yaml:"resources"`
+	Retention     Retention     `yaml:"retention"`
 }
 
 func GetConfiguration(configPath string) (*Config, error) {
@@ -0,0 +1,178 @@
+package cookies
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cookie is a single entry from a Netscape cookie file. The cookie value is
+// intentionally not kept so it can never leak through the dashboard.
+type Cookie struct {
+	Domain            string
+	IncludeSubdomains bool
+	Path              string
+	Secure            bool
+	HTTPOnly          bool
+	Expires           time.Time // zero for session cookies
+	Name              string
+}
+
+// Summary describes a parsed cookie file.
+type Summary struct {
+	Count   int
+	Domains []string
+	// ExpiresAt is when the jar stops working: the earliest expiry of its
+	// sign-in cookies, or without any, of the cookies that outlive
+	// shortLived. It is zero if every cookie lasts the session.
+	ExpiresAt time.Time
+}
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// shortLived is how soon a cookie may expire and still be ignored as a
+// tracking or consent cookie when the jar has no sign-in cookies.
+const shortLived = 24 * time.Hour
+
+// authCookieNames are the cookies that keep people signed in to common
+// sites; names starting with __Secure- count as well.
+var authCookieNames = []string{
+	"SID", "HSID", "SSID", "SAPISID", "APISID", "LOGIN_INFO", // Google, YouTube
+	"sessionid",    // Instagram, TikTok
+	"auth_token",   // X
+	"c_user", "xs", // Facebook
+}
+
+// isAuthCookie reports whether c keeps someone signed in.
+func isAuthCookie(c Cookie) bool {
+	return strings.HasPrefix(c.Name, "__Secure-") || slices.Contains(authCookieNames, c.Name)
+}
+
+// Parse reads a Netscape (cookies.txt) formatted file as written by browser
+// extensions and yt-dlp's --cookies-from-browser.
+func Parse(r io.Reader) ([]Cookie, error) {
+	var result []Cookie
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := false
+		if strings.HasPrefix(line, httpOnlyPrefix) {
+			httpOnly = true
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNo, len(fields))
+		}
+
+		includeSubdomains, err := parseFlag(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: include subdomains: %w", lineNo, err)
+		}
+		secure, err := parseFlag(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: secure: %w", lineNo, err)
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: expiry: %w", lineNo, err)
+		}
+		if fields[0] == "" || fields[5] == "" {
+			return nil, fmt.Errorf("line %d: domain and name are required", lineNo)
+		}
+
+		c := Cookie{
+			Domain:            fields[0],
+			IncludeSubdomains: includeSubdomains,
+			Path:              fields[2],
+			Secure:            secure,
+			HTTPOnly:          httpOnly,
+			Name:              fields[5],
+		}
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0).UTC()
+		}
+		result = append(result, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no cookies found")
+	}
+
+	return result, nil
+}
+
+// Summarize returns the cookie count, distinct domains and when the jar
+// expires.
+func Summarize(list []Cookie) Summary {
+	return summarize(list, time.Now())
+}
+
+func summarize(list []Cookie, now time.Time) Summary {
+	s := Summary{Count: len(list)}
+	// Earliest expiry of sign-in cookies, of cookies outliving shortLived,
+	// and of any cookie, in order of preference.
+	var auth, lasting, first time.Time
+	for _, c := range list {
+		domain := strings.TrimPrefix(c.Domain, ".")
+		if !slices.Contains(s.Domains, domain) {
+			s.Domains = append(s.Domains, domain)
+		}
+		if c.Expires.IsZero() {
+			continue
+		}
+		if isAuthCookie(c) {
+			auth = earliest(auth, c.Expires)
+		}
+		if c.Expires.After(now.Add(shortLived)) {
+			lasting = earliest(lasting, c.Expires)
+		}
+		first = earliest(first, c.Expires)
+	}
+	slices.Sort(s.Domains)
+
+	switch {
+	case !auth.IsZero():
+		s.ExpiresAt = auth
+	case !lasting.IsZero():
+		s.ExpiresAt = lasting
+	default:
+		s.ExpiresAt = first
+	}
+	return s
+}
+
+// earliest returns the earlier of a and b, treating zero as unset.
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func parseFlag(v string) (bool, error) {
+	switch strings.ToUpper(v) {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected TRUE or FALSE, got %q", v)
+	}
+}
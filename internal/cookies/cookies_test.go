@@ -0,0 +1,174 @@
+package cookies
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Cookie
+	}{
+		{
+			name: "plain",
+			in:   ".youtube.com\tTRUE\t/\tTRUE\t1893456000\tSID\tsecret\n",
+			want: []Cookie{{Domain: ".youtube.com", IncludeSubdomains: true, Path: "/", Secure: true, Expires: time.Unix(1893456000, 0).UTC(), Name: "SID"}},
+		},
+		{
+			name: "comments and blank lines",
+			in: "# Netscape HTTP Cookie File\n" +
+				"# https://curl.se/docs/http-cookies.html\n" +
+				"\n" +
+				"   \n" +
+				"example.com\tFALSE\t/path\tFALSE\t1893456000\tid\tv\n",
+			want: []Cookie{{Domain: "example.com", Path: "/path", Expires: time.Unix(1893456000, 0).UTC(), Name: "id"}},
+		},
+		{
+			name: "HttpOnly prefix",
+			in:   "#HttpOnly_.tiktok.com\tTRUE\t/\tTRUE\t1893456000\tsessionid\tv\n",
+			want: []Cookie{{Domain: ".tiktok.com", IncludeSubdomains: true, Path: "/", Secure: true, HTTPOnly: true, Expires: time.Unix(1893456000, 0).UTC(), Name: "sessionid"}},
+		},
+		{
+			name: "expiry 0 is a session cookie",
+			in:   "example.com\tFALSE\t/\tFALSE\t0\tsession\tv\n",
+			want: []Cookie{{Domain: "example.com", Path: "/", Name: "session"}},
+		},
+		{
+			name: "CRLF line endings and lowercase flags",
+			in:   "example.com\tfalse\t/\ttrue\t0\ta\tv\r\nexample.com\tFALSE\t/\tFALSE\t0\tb\t\r\n",
+			want: []Cookie{
+				{Domain: "example.com", Path: "/", Secure: true, Name: "a"},
+				{Domain: "example.com", Path: "/", Name: "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{"empty", "", "no cookies found"},
+		{"only comments", "# Netscape HTTP Cookie File\n\n", "no cookies found"},
+		{"too few fields", "example.com\tFALSE\t/\tFALSE\t0\tname\n", "line 1: expected 7"},
+		{"too many fields", "example.com\tFALSE\t/\tFALSE\t0\tname\tv\textra\n", "line 1: expected 7"},
+		{"spaces instead of tabs", "example.com FALSE / FALSE 0 name v\n", "line 1: expected 7"},
+		{"bad subdomain flag", "example.com\tYES\t/\tFALSE\t0\tname\tv\n", "line 1: include subdomains"},
+		{"bad secure flag", "example.com\tFALSE\t/\t1\t0\tname\tv\n", "line 1: secure"},
+		{"bad expiry", "example.com\tFALSE\t/\tFALSE\tsoon\tname\tv\n", "line 1: expiry"},
+		{"no domain", "\tFALSE\t/\tFALSE\t0\tname\tv\n", "line 1: domain and name"},
+		{"no name", "example.com\tFALSE\t/\tFALSE\t0\t\tv\n", "line 1: domain and name"},
+		{"line number counts comments", "# header\n\nexample.com\tFALSE\n", "line 3:"},
+		{"HttpOnly line still checked", "#HttpOnly_example.com\tFALSE\t/\n", "line 1: expected 7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return now.Add(d) }
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		name        string
+		cookies     []Cookie
+		wantExpires time.Time
+	}{
+		{
+			name:    "session cookies only",
+			cookies: []Cookie{{Domain: ".example.com", Name: "a"}},
+		},
+		{
+			name: "sign-in cookie wins over earlier long-lived cookie",
+			cookies: []Cookie{
+				{Domain: ".youtube.com", Name: "VISITOR_INFO1_LIVE", Expires: at(30 * day)},
+				{Domain: ".youtube.com", Name: "SID", Expires: at(300 * day)},
+				{Domain: ".youtube.com", Name: "__Secure-3PSID", Expires: at(200 * day)},
+			},
+			wantExpires: at(200 * day),
+		},
+		{
+			name: "short-lived tracking cookie is ignored",
+			cookies: []Cookie{
+				{Domain: ".youtube.com", Name: "SID", Expires: at(300 * day)},
+				{Domain: ".youtube.com", Name: "GPS", Expires: at(30 * time.Minute)},
+				{Domain: ".example.com", Name: "_ga", Expires: at(-time.Hour)},
+			},
+			wantExpires: at(300 * day),
+		},
+		{
+			name: "expired sign-in cookie is reported",
+			cookies: []Cookie{
+				{Domain: ".youtube.com", Name: "SID", Expires: at(-day)},
+				{Domain: ".youtube.com", Name: "PREF", Expires: at(300 * day)},
+			},
+			wantExpires: at(-day),
+		},
+		{
+			name: "without sign-in cookies, earliest lasting cookie",
+			cookies: []Cookie{
+				{Domain: ".example.com", Name: "consent", Expires: at(2 * time.Hour)},
+				{Domain: ".example.com", Name: "token", Expires: at(60 * day)},
+				{Domain: ".example.com", Name: "prefs", Expires: at(90 * day)},
+			},
+			wantExpires: at(60 * day),
+		},
+		{
+			name: "everything short-lived falls back to the earliest",
+			cookies: []Cookie{
+				{Domain: ".example.com", Name: "a", Expires: at(3 * time.Hour)},
+				{Domain: ".example.com", Name: "b", Expires: at(-time.Hour)},
+			},
+			wantExpires: at(-time.Hour),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarize(tt.cookies, now)
+			if !got.ExpiresAt.Equal(tt.wantExpires) {
+				t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, tt.wantExpires)
+			}
+			if got.Count != len(tt.cookies) {
+				t.Errorf("Count = %d, want %d", got.Count, len(tt.cookies))
+			}
+		})
+	}
+}
+
+func TestSummarizeDomains(t *testing.T) {
+	got := Summarize([]Cookie{
+		{Domain: ".youtube.com", Name: "a"},
+		{Domain: "youtube.com", Name: "b"},
+		{Domain: ".google.com", Name: "c"},
+	})
+	if want := []string{"google.com", "youtube.com"}; !reflect.DeepEqual(got.Domains, want) {
+		t.Errorf("Domains = %q, want %q", got.Domains, want)
+	}
+}
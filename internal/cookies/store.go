@@ -0,0 +1,108 @@
+package cookies
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var siteNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// ValidSiteName reports whether name can be used as a cookie jar file name.
+func ValidSiteName(name string) bool {
+	return siteNameRe.MatchString(name)
+}
+
+// Store keeps one cookie file per site in a directory on disk.
+type Store struct {
+	Dir string
+}
+
+// NewStore creates the cookie directory if needed.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create cookies directory: %w", err)
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// Path returns the file path used for the given site.
+func (s *Store) Path(site string) string {
+	return filepath.Join(s.Dir, site+".txt")
+}
+
+// Save validates data and writes it as the site's cookie file. The previous
+// file, if any, is kept as <site>.txt.prev so a bad upload can be rolled back.
+func (s *Store) Save(site string, data []byte) (Summary, error) {
+	if !ValidSiteName(site) {
+		return Summary{}, errors.New("invalid site name")
+	}
+
+	list, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	path := s.Path(site)
+	tmp, err := os.CreateTemp(s.Dir, site+".*.tmp")
+	if err != nil {
+		return Summary{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return Summary{}, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return Summary{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Summary{}, err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".prev"); err != nil {
+			return Summary{}, fmt.Errorf("rotate previous cookies: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Summary{}, err
+	}
+
+	return Summarize(list), nil
+}
+
+// Restore swaps the site's current cookie file with the previous one.
+func (s *Store) Restore(site string) (Summary, error) {
+	path := s.Path(site)
+	data, err := os.ReadFile(path + ".prev")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Summary{}, errors.New("no previous cookie file")
+		}
+		return Summary{}, err
+	}
+	return s.Save(site, data)
+}
+
+// HasPrevious reports whether a rotated cookie file exists for the site.
+func (s *Store) HasPrevious(site string) bool {
+	_, err := os.Stat(s.Path(site) + ".prev")
+	return err == nil
+}
+
+// Remove deletes the site's cookie file and its rotated copy.
+func (s *Store) Remove(site string) error {
+	path := s.Path(site)
+	for _, p := range []string{path, path + ".prev"} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
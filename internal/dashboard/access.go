@@ -1,6 +1,7 @@
 package dashboard
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 )
@@ -43,12 +44,14 @@ func (s *Server) approveGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.groupSnapshot(chatID)
 	if err := s.DB.ApprovePendingGroup(chatID); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed approve group")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "group.approve", fmt.Sprintf("group:%d", chatID), before, s.groupSnapshot(chatID))
 	http.Redirect(w, r, "/access", http.StatusSeeOther)
 }
 
@@ -59,12 +62,14 @@ func (s *Server) rejectGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.groupSnapshot(chatID)
 	if err := s.DB.RejectPendingGroup(chatID); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed reject group")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "group.reject", fmt.Sprintf("group:%d", chatID), before, s.groupSnapshot(chatID))
 	http.Redirect(w, r, "/access", http.StatusSeeOther)
 }
 
@@ -75,12 +80,14 @@ func (s *Server) removeGroupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.groupSnapshot(chatID)
 	if err := s.DB.RemoveAllowedGroup(chatID); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed remove group")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "group.remove", fmt.Sprintf("group:%d", chatID), before, s.groupSnapshot(chatID))
 	http.Redirect(w, r, "/access", http.StatusSeeOther)
 }
 
@@ -91,12 +98,14 @@ func (s *Server) approveUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.userSnapshot(userID)
 	if err := s.DB.ApprovePendingUser(userID); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed approve user")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "telegram_user.approve", fmt.Sprintf("telegram_user:%d", userID), before, s.userSnapshot(userID))
 	http.Redirect(w, r, "/access", http.StatusSeeOther)
 }
 
@@ -107,12 +116,14 @@ func (s *Server) rejectUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.userSnapshot(userID)
 	if err := s.DB.RejectPendingUser(userID); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed reject user")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "telegram_user.reject", fmt.Sprintf("telegram_user:%d", userID), before, s.userSnapshot(userID))
 	http.Redirect(w, r, "/access", http.StatusSeeOther)
 }
 
@@ -123,11 +134,33 @@ func (s *Server) removeUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.userSnapshot(userID)
 	if err := s.DB.RemoveAllowedUser(userID); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed remove user")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "telegram_user.remove", fmt.Sprintf("telegram_user:%d", userID), before, s.userSnapshot(userID))
 	http.Redirect(w, r, "/access", http.StatusSeeOther)
 }
+
+// groupSnapshot returns the group as recorded in the audit log, or nil if
+// it doesn't exist.
+func (s *Server) groupSnapshot(chatID int64) any {
+	g, found, err := s.DB.FindGroup(chatID)
+	if err != nil || !found {
+		return nil
+	}
+	return toAPIGroup(g)
+}
+
+// userSnapshot returns the Telegram user as recorded in the audit log, or
+// nil if they don't exist.
+func (s *Server) userSnapshot(userID int64) any {
+	u, found, err := s.DB.FindUser(userID)
+	if err != nil || !found {
+		return nil
+	}
+	return toAPIUser(u)
+}
@@ -0,0 +1,191 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	apiDefaultLimit = 50
+	apiMaxLimit     = 200
+)
+
+// apiError is the body of every API error response.
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiList wraps collections that are returned in full.
+type apiList[T any] struct {
+	Items []T `json:"items"`
+}
+
+// apiPage wraps one page of a paginated collection. NextCursor is empty on
+// the last page.
+type apiPage[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func (s *Server) registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.yaml", s.openAPIHandler)
+
+	mux.HandleFunc("GET /api/v1/downloads", s.requireAPIAuth("downloads:read", s.apiListDownloads))
+	mux.HandleFunc("GET /api/v1/downloads/{id}", s.requireAPIAuth("downloads:read", s.apiGetDownload))
+	mux.HandleFunc("POST /api/v1/downloads/{id}/retry", s.requireAPIAuth("downloads:write", s.apiRetryDownload))
+	mux.HandleFunc("DELETE /api/v1/downloads/{id}", s.requireAPIAuth("downloads:write", s.apiDeleteDownload))
+	mux.HandleFunc("GET /api/v1/logs", s.requireAPIAuth("logs:read", s.apiListLogs))
+	mux.HandleFunc("GET /api/v1/stats", s.requireAPIAuth("stats:read", s.apiGetStats))
+
+	mux.HandleFunc("GET /api/v1/access/groups", s.requireAPIAuth("access:read", s.apiListGroups))
+	mux.HandleFunc("POST /api/v1/access/groups/{chat_id}/approve", s.requireAPIAuth("access:write", s.apiApproveGroup))
+	mux.HandleFunc("POST /api/v1/access/groups/{chat_id}/reject", s.requireAPIAuth("access:write", s.apiRejectGroup))
+	mux.HandleFunc("DELETE /api/v1/access/groups/{chat_id}", s.requireAPIAuth("access:write", s.apiRemoveGroup))
+	mux.HandleFunc("GET /api/v1/access/users", s.requireAPIAuth("access:read", s.apiListUsers))
+	mux.HandleFunc("POST /api/v1/access/users/{user_id}/approve", s.requireAPIAuth("access:write", s.apiApproveUser))
+	mux.HandleFunc("POST /api/v1/access/users/{user_id}/reject", s.requireAPIAuth("access:write", s.apiRejectUser))
+	mux.HandleFunc("DELETE /api/v1/access/users/{user_id}", s.requireAPIAuth("access:write", s.apiRemoveUser))
+
+	mux.HandleFunc("GET /api/v1/filters", s.requireAPIAuth("filters:read", s.apiListFilters))
+	mux.HandleFunc("POST /api/v1/filters", s.requireAPIAuth("filters:write", s.apiCreateFilter))
+	mux.HandleFunc("GET /api/v1/filters/{id}", s.requireAPIAuth("filters:read", s.apiGetFilter))
+	mux.HandleFunc("PUT /api/v1/filters/{id}", s.requireAPIAuth("filters:write", s.apiUpdateFilter))
+	mux.HandleFunc("DELETE /api/v1/filters/{id}", s.requireAPIAuth("filters:write", s.apiDeleteFilter))
+
+	mux.HandleFunc("GET /api/v1/cache", s.requireAPIAuth("cache:read", s.apiListCache))
+	mux.HandleFunc("DELETE /api/v1/cache", s.requireAPIAuth("cache:write", s.apiDeleteCacheEntry))
+
+	mux.HandleFunc("GET /api/v1/jobs", s.requireAPIAuth("jobs:read", s.apiListJobs))
+	mux.HandleFunc("POST /api/v1/jobs/{id}/cancel", s.requireAPIAuth("jobs:write", s.apiCancelJob))
+
+	// One catch-all per method: a method-less "/api/v1/" would conflict with
+	// the dashboard's "GET /".
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		mux.HandleFunc(method+" /api/v1/", func(w http.ResponseWriter, r *http.Request) {
+			writeAPIError(w, http.StatusNotFound, "not_found", "No such endpoint")
+		})
+	}
+}
+
+func (s *Server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPISpec)
+}
+
+// requireAPIAuth is requireAuth for the API: it answers with a JSON 401
+// instead of redirecting to the login page. Requests may authenticate with a
+// dashboard session or an "Authorization: Bearer" API token. Tokens must
+// carry scope; sessions must have a role that grants it.
+func (s *Server) requireAPIAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			user, ok := s.currentUser(w, r)
+			if !ok {
+				writeAPIError(w, http.StatusUnauthorized, "unauthorized", "Authentication required")
+				return
+			}
+			if !roleGrantsScope(user.Role, scope) {
+				writeAPIError(w, http.StatusForbidden, "forbidden", "The "+user.Role+" role cannot use this endpoint")
+				return
+			}
+			next(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, user)))
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized", "Authorization header must be a Bearer token")
+			return
+		}
+		t, found, err := s.authenticateAPIToken(token)
+		if err != nil {
+			s.apiInternalError(w, "failed authenticate API token", err)
+			return
+		}
+		if !found {
+			writeAPIError(w, http.StatusUnauthorized, "unauthorized", "Invalid or expired API token")
+			return
+		}
+		if !hasScope(t.Scopes, scope) {
+			writeAPIError(w, http.StatusForbidden, "forbidden", "Token lacks the "+scope+" scope")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), tokenCtxKey{}, t)))
+	}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return strings.TrimSpace(token), ok
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, apiError{Error: apiErrorBody{Code: code, Message: message}})
+}
+
+// apiInternalError logs err and writes a generic 500 response.
+func (s *Server) apiInternalError(w http.ResponseWriter, msg string, err error) {
+	s.Logger.Error().Str("reason", err.Error()).Msg(msg)
+	writeAPIError(w, http.StatusInternalServerError, "internal", "Internal server error")
+}
+
+// pathID parses the named path parameter as a non-zero integer ID, writing
+// a 400 response if it isn't one.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, _ := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if id == 0 {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
+
+// pageParams reads the limit and cursor query parameters. Cursors are the
+// ID of the last item on the previous page; clients treat them as opaque.
+func pageParams(w http.ResponseWriter, r *http.Request) (limit int, before int64, ok bool) {
+	limit = apiDefaultLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > apiMaxLimit {
+			writeAPIError(w, http.StatusBadRequest, "bad_request", "limit must be between 1 and "+strconv.Itoa(apiMaxLimit))
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "bad_request", "Invalid cursor")
+			return 0, 0, false
+		}
+		before = n
+	}
+	return limit, before, true
+}
+
+// nextCursor trims a page fetched with one extra item and returns the cursor
+// for the following page, or "" if this is the last one.
+func nextCursor[T any](items []T, limit int, id func(T) int64) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	return items, strconv.FormatInt(id(items[limit-1]), 10)
+}
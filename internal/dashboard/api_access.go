@@ -0,0 +1,204 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+var accessStatuses = []string{"pending", "approved", "rejected"}
+
+type apiGroup struct {
+	ChatID  int64     `json:"chat_id"`
+	Title   string    `json:"title"`
+	Status  string    `json:"status"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type apiUser struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	Status   string    `json:"status"`
+	AddedAt  time.Time `json:"added_at"`
+}
+
+func toAPIGroup(g database.AllowedGroup) apiGroup {
+	return apiGroup{ChatID: g.ChatID, Title: g.Title, Status: g.Status, AddedAt: g.AddedAt}
+}
+
+func toAPIUser(u database.AllowedUser) apiUser {
+	return apiUser{UserID: u.UserID, Username: u.Username, Status: u.Status, AddedAt: u.AddedAt}
+}
+
+// accessStatusParam returns the statuses selected by the status query
+// parameter, all of them if it is empty.
+func accessStatusParam(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		return accessStatuses, true
+	}
+	if !slices.Contains(accessStatuses, status) {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "status must be pending, approved or rejected")
+		return nil, false
+	}
+	return []string{status}, true
+}
+
+func (s *Server) apiListGroups(w http.ResponseWriter, r *http.Request) {
+	statuses, ok := accessStatusParam(w, r)
+	if !ok {
+		return
+	}
+
+	items := []apiGroup{}
+	for _, status := range statuses {
+		groups, err := s.DB.ListGroupsByStatus(status)
+		if err != nil {
+			s.apiInternalError(w, "failed list groups", err)
+			return
+		}
+		for _, g := range groups {
+			items = append(items, toAPIGroup(g))
+		}
+	}
+	writeJSON(w, http.StatusOK, apiList[apiGroup]{Items: items})
+}
+
+func (s *Server) apiApproveGroup(w http.ResponseWriter, r *http.Request) {
+	s.apiUpdateGroup(w, r, s.DB.ApprovePendingGroup, "group.approve", "failed approve group")
+}
+
+func (s *Server) apiRejectGroup(w http.ResponseWriter, r *http.Request) {
+	s.apiUpdateGroup(w, r, s.DB.RejectPendingGroup, "group.reject", "failed reject group")
+}
+
+func (s *Server) apiRemoveGroup(w http.ResponseWriter, r *http.Request) {
+	g, ok := s.apiFindGroup(w, r)
+	if !ok {
+		return
+	}
+	if err := s.DB.RemoveAllowedGroup(g.ChatID); err != nil {
+		s.apiInternalError(w, "failed remove group", err)
+		return
+	}
+	s.audit(r, "group.remove", fmt.Sprintf("group:%d", g.ChatID), toAPIGroup(g), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiUpdateGroup applies update to the group named in the path, records it
+// in the audit log as action and responds with its new state.
+func (s *Server) apiUpdateGroup(w http.ResponseWriter, r *http.Request, update func(int64) error, action, msg string) {
+	before, ok := s.apiFindGroup(w, r)
+	if !ok {
+		return
+	}
+	if err := update(before.ChatID); err != nil {
+		s.apiInternalError(w, msg, err)
+		return
+	}
+	g, _, err := s.DB.FindGroup(before.ChatID)
+	if err != nil {
+		s.apiInternalError(w, "failed load group", err)
+		return
+	}
+	s.audit(r, action, fmt.Sprintf("group:%d", g.ChatID), toAPIGroup(before), toAPIGroup(g))
+	writeJSON(w, http.StatusOK, toAPIGroup(g))
+}
+
+func (s *Server) apiFindGroup(w http.ResponseWriter, r *http.Request) (database.AllowedGroup, bool) {
+	chatID, ok := pathID(w, r, "chat_id")
+	if !ok {
+		return database.AllowedGroup{}, false
+	}
+	g, found, err := s.DB.FindGroup(chatID)
+	if err != nil {
+		s.apiInternalError(w, "failed load group", err)
+		return database.AllowedGroup{}, false
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "not_found", "Group not found")
+		return database.AllowedGroup{}, false
+	}
+	return g, true
+}
+
+func (s *Server) apiListUsers(w http.ResponseWriter, r *http.Request) {
+	statuses, ok := accessStatusParam(w, r)
+	if !ok {
+		return
+	}
+
+	items := []apiUser{}
+	for _, status := range statuses {
+		users, err := s.DB.ListUsersByStatus(status)
+		if err != nil {
+			s.apiInternalError(w, "failed list users", err)
+			return
+		}
+		for _, u := range users {
+			items = append(items, toAPIUser(u))
+		}
+	}
+	writeJSON(w, http.StatusOK, apiList[apiUser]{Items: items})
+}
+
+func (s *Server) apiApproveUser(w http.ResponseWriter, r *http.Request) {
+	s.apiUpdateUser(w, r, s.DB.ApprovePendingUser, "telegram_user.approve", "failed approve user")
+}
+
+func (s *Server) apiRejectUser(w http.ResponseWriter, r *http.Request) {
+	s.apiUpdateUser(w, r, s.DB.RejectPendingUser, "telegram_user.reject", "failed reject user")
+}
+
+func (s *Server) apiRemoveUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := s.apiFindUser(w, r)
+	if !ok {
+		return
+	}
+	if err := s.DB.RemoveAllowedUser(u.UserID); err != nil {
+		s.apiInternalError(w, "failed remove user", err)
+		return
+	}
+	s.audit(r, "telegram_user.remove", fmt.Sprintf("telegram_user:%d", u.UserID), toAPIUser(u), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiUpdateUser applies update to the user named in the path, records it
+// in the audit log as action and responds with their new state.
+func (s *Server) apiUpdateUser(w http.ResponseWriter, r *http.Request, update func(int64) error, action, msg string) {
+	before, ok := s.apiFindUser(w, r)
+	if !ok {
+		return
+	}
+	if err := update(before.UserID); err != nil {
+		s.apiInternalError(w, msg, err)
+		return
+	}
+	u, _, err := s.DB.FindUser(before.UserID)
+	if err != nil {
+		s.apiInternalError(w, "failed load user", err)
+		return
+	}
+	s.audit(r, action, fmt.Sprintf("telegram_user:%d", u.UserID), toAPIUser(before), toAPIUser(u))
+	writeJSON(w, http.StatusOK, toAPIUser(u))
+}
+
+func (s *Server) apiFindUser(w http.ResponseWriter, r *http.Request) (database.AllowedUser, bool) {
+	userID, ok := pathID(w, r, "user_id")
+	if !ok {
+		return database.AllowedUser{}, false
+	}
+	u, found, err := s.DB.FindUser(userID)
+	if err != nil {
+		s.apiInternalError(w, "failed load user", err)
+		return database.AllowedUser{}, false
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "not_found", "User not found")
+		return database.AllowedUser{}, false
+	}
+	return u, true
+}
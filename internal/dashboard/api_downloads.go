@@ -0,0 +1,262 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+type apiDownload struct {
+	ID         int64              `json:"id"`
+	URL        string             `json:"url"`
+	UserID     int64              `json:"user_id"`
+	Username   string             `json:"username"`
+	ChatID     int64              `json:"chat_id"`
+	Status     string             `json:"status"`
+	Filename   string             `json:"filename"`
+	Error      string             `json:"error"`
+	ErrorKind  string             `json:"error_kind"`
+	Title      string             `json:"title"`
+	Extractor  string             `json:"extractor"`
+	Duration   float64            `json:"duration"`
+	Format     string             `json:"format"`
+	FileSize   int64              `json:"file_size"`
+	Attempt    int                `json:"attempt"`
+	RetryOf    int64              `json:"retry_of"`
+	TimingsMS  apiDownloadTimings `json:"timings_ms"`
+	CreatedAt  time.Time          `json:"created_at"`
+	FinishedAt *time.Time         `json:"finished_at"`
+}
+
+type apiDownloadTimings struct {
+	Probe      int64 `json:"probe"`
+	Download   int64 `json:"download"`
+	EncodeWait int64 `json:"encode_wait"`
+	Encode     int64 `json:"encode"`
+	Upload     int64 `json:"upload"`
+}
+
+func toAPIDownload(d database.Download) apiDownload {
+	out := apiDownload{
+		ID:        d.ID,
+		URL:       d.URL,
+		UserID:    d.TelegramUserID,
+		Username:  d.TelegramUsername,
+		ChatID:    d.ChatID,
+		Status:    d.Status,
+		Filename:  d.Filename,
+		Error:     d.ErrorMessage,
+		ErrorKind: d.ErrorKind,
+		Title:     d.Title,
+		Extractor: d.Extractor,
+		Duration:  d.Duration,
+		Format:    d.Format,
+		FileSize:  d.FileSize,
+		Attempt:   d.Attempt,
+		RetryOf:   d.RetryOf,
+		TimingsMS: apiDownloadTimings{
+			Probe:      d.Timings.Probe.Milliseconds(),
+			Download:   d.Timings.Download.Milliseconds(),
+			EncodeWait: d.Timings.EncodeWait.Milliseconds(),
+			Encode:     d.Timings.Encode.Milliseconds(),
+			Upload:     d.Timings.Upload.Milliseconds(),
+		},
+		CreatedAt: d.CreatedAt,
+	}
+	if !d.FinishedAt.IsZero() {
+		out.FinishedAt = &d.FinishedAt
+	}
+	return out
+}
+
+type apiLogEntry struct {
+	ID         int64           `json:"id"`
+	Level      string          `json:"level"`
+	Message    string          `json:"message"`
+	Fields     json.RawMessage `json:"fields"`
+	DownloadID int64           `json:"download_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type apiStats struct {
+	TotalDownloads int              `json:"total_downloads"`
+	Succeeded      int              `json:"succeeded"`
+	Failed         int              `json:"failed"`
+	ActiveUsers    int              `json:"active_users"`
+	DailyCounts    []apiDailyCount  `json:"daily_counts"`
+	TopDomains     []apiDomainCount `json:"top_domains"`
+}
+
+type apiDailyCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type apiDomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+func (s *Server) apiListDownloads(w http.ResponseWriter, r *http.Request) {
+	limit, before, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	// Cursors follow IDs, so the API always lists newest first.
+	filter, bad := downloadFilter(r.URL.Query())
+	if bad != "" {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Invalid "+bad)
+		return
+	}
+	filter.Sort, filter.Asc = "", false
+	filter.Before = before
+	filter.Limit = limit + 1
+
+	downloads, total, err := s.DB.ListDownloads(filter)
+	if err != nil {
+		s.apiInternalError(w, "failed list downloads", err)
+		return
+	}
+
+	downloads, cursor := nextCursor(downloads, limit, func(d database.Download) int64 { return d.ID })
+	items := make([]apiDownload, 0, len(downloads))
+	for _, d := range downloads {
+		items = append(items, toAPIDownload(d))
+	}
+	writeJSON(w, http.StatusOK, apiPage[apiDownload]{Items: items, Total: total, NextCursor: cursor})
+}
+
+func (s *Server) apiGetDownload(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.apiFindDownload(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIDownload(d))
+}
+
+func (s *Server) apiRetryDownload(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.apiFindDownload(w, r)
+	if !ok {
+		return
+	}
+	if d.Status == "pending" {
+		writeAPIError(w, http.StatusConflict, "conflict", "Download is still in progress")
+		return
+	}
+
+	id, err := s.Bot.Retry(r.Context(), d)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed retry download")
+		writeAPIError(w, http.StatusServiceUnavailable, "unavailable", err.Error())
+		return
+	}
+
+	retried, _, err := s.DB.FindDownload(id)
+	if err != nil {
+		s.apiInternalError(w, "failed load download", err)
+		return
+	}
+	s.audit(r, "download.retry", fmt.Sprintf("download:%d", d.ID), toAPIDownload(d), toAPIDownload(retried))
+	writeJSON(w, http.StatusAccepted, toAPIDownload(retried))
+}
+
+func (s *Server) apiDeleteDownload(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.apiFindDownload(w, r)
+	if !ok {
+		return
+	}
+	if err := s.DB.DeleteDownload(d.ID); err != nil {
+		s.apiInternalError(w, "failed delete download", err)
+		return
+	}
+	s.audit(r, "download.delete", fmt.Sprintf("download:%d", d.ID), toAPIDownload(d), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiFindDownload loads the download named by the id path parameter, writing
+// an error response if there is none.
+func (s *Server) apiFindDownload(w http.ResponseWriter, r *http.Request) (database.Download, bool) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return database.Download{}, false
+	}
+	d, found, err := s.DB.FindDownload(id)
+	if err != nil {
+		s.apiInternalError(w, "failed load download", err)
+		return database.Download{}, false
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "not_found", "Download not found")
+		return database.Download{}, false
+	}
+	return d, true
+}
+
+func (s *Server) apiListLogs(w http.ResponseWriter, r *http.Request) {
+	limit, before, ok := pageParams(w, r)
+	if !ok {
+		return
+	}
+
+	q, err := logQuery(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Invalid search: "+err.Error())
+		return
+	}
+
+	logs, total, err := s.DB.ListLogs(database.LogFilter{
+		Query:  q,
+		Before: before,
+		Limit:  limit + 1,
+	})
+	if err != nil {
+		s.apiInternalError(w, "failed list logs", err)
+		return
+	}
+
+	logs, cursor := nextCursor(logs, limit, func(l database.LogEntry) int64 { return l.ID })
+	items := make([]apiLogEntry, 0, len(logs))
+	for _, l := range logs {
+		fields := json.RawMessage(l.Fields)
+		if !json.Valid(fields) {
+			fields = json.RawMessage("{}")
+		}
+		items = append(items, apiLogEntry{
+			ID:         l.ID,
+			Level:      l.Level,
+			Message:    l.Message,
+			Fields:     fields,
+			DownloadID: l.DownloadID,
+			CreatedAt:  l.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, apiPage[apiLogEntry]{Items: items, Total: total, NextCursor: cursor})
+}
+
+func (s *Server) apiGetStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := s.DB.GetStats()
+	if err != nil {
+		s.apiInternalError(w, "failed get stats", err)
+		return
+	}
+
+	resp := apiStats{
+		TotalDownloads: stats.TotalDownloads,
+		Succeeded:      stats.Succeeded,
+		Failed:         stats.Failed,
+		ActiveUsers:    stats.ActiveUsers,
+		DailyCounts:    make([]apiDailyCount, 0, len(stats.DailyCounts)),
+		TopDomains:     make([]apiDomainCount, 0, len(stats.TopDomains)),
+	}
+	for _, d := range stats.DailyCounts {
+		resp.DailyCounts = append(resp.DailyCounts, apiDailyCount{Date: d.Date, Count: d.Count})
+	}
+	for _, d := range stats.TopDomains {
+		resp.TopDomains = append(resp.TopDomains, apiDomainCount{Domain: d.Domain, Count: d.Count})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
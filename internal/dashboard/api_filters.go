@@ -0,0 +1,195 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+type apiFilter struct {
+	ID                 int64     `json:"id"`
+	Hosts              []string  `json:"hosts"`
+	ExcludeQueryParams bool      `json:"exclude_query_params"`
+	PathRegex          string    `json:"path_regex"`
+	CookiesFile        string    `json:"cookies_file"`
+	Proxy              string    `json:"proxy"`
+	Impersonate        string    `json:"impersonate"`
+	UserAgent          string    `json:"user_agent"`
+	RateLimit          string    `json:"rate_limit"`
+	Format             string    `json:"format"`
+	GeoBypassCountry   string    `json:"geo_bypass_country"`
+	ExtraArgs          []string  `json:"extra_args"`
+	Postprocess        []string  `json:"postprocess"`
+	Profile            string    `json:"profile"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+func toAPIFilter(f database.URLFilter) apiFilter {
+	return apiFilter{
+		ID:                 f.ID,
+		Hosts:              nonNil(f.Hosts),
+		ExcludeQueryParams: f.ExcludeQueryParams,
+		PathRegex:          f.PathRegex,
+		CookiesFile:        f.CookiesFile,
+		Proxy:              f.Proxy,
+		Impersonate:        f.Impersonate,
+		UserAgent:          f.UserAgent,
+		RateLimit:          f.RateLimit,
+		Format:             f.Format,
+		GeoBypassCountry:   f.GeoBypassCountry,
+		ExtraArgs:          nonNil(f.ExtraArgs),
+		Postprocess:        nonNil(f.Postprocess),
+		Profile:            f.Profile,
+		CreatedAt:          f.CreatedAt,
+	}
+}
+
+func (s *Server) apiListFilters(w http.ResponseWriter, r *http.Request) {
+	filters, err := s.DB.ListFilters()
+	if err != nil {
+		s.apiInternalError(w, "failed list filters", err)
+		return
+	}
+
+	items := make([]apiFilter, 0, len(filters))
+	for _, f := range filters {
+		items = append(items, toAPIFilter(f))
+	}
+	writeJSON(w, http.StatusOK, apiList[apiFilter]{Items: items})
+}
+
+func (s *Server) apiGetFilter(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.apiFindFilter(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIFilter(f))
+}
+
+func (s *Server) apiCreateFilter(w http.ResponseWriter, r *http.Request) {
+	filter, ok := s.decodeFilter(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := s.DB.InsertFilter(filter)
+	if err != nil {
+		s.apiInternalError(w, "failed add filter", err)
+		return
+	}
+	s.audit(r, "filter.create", fmt.Sprintf("filter:%d", id), nil, s.filterSnapshot(id))
+	s.apiRespondFilter(w, id, http.StatusCreated)
+}
+
+func (s *Server) apiUpdateFilter(w http.ResponseWriter, r *http.Request) {
+	existing, ok := s.apiFindFilter(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := s.decodeFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.ID = existing.ID
+
+	if err := s.DB.UpdateFilter(filter); err != nil {
+		s.apiInternalError(w, "failed update filter", err)
+		return
+	}
+	s.audit(r, "filter.update", fmt.Sprintf("filter:%d", filter.ID), toAPIFilter(existing), s.filterSnapshot(filter.ID))
+	s.apiRespondFilter(w, filter.ID, http.StatusOK)
+}
+
+func (s *Server) apiDeleteFilter(w http.ResponseWriter, r *http.Request) {
+	f, ok := s.apiFindFilter(w, r)
+	if !ok {
+		return
+	}
+	if err := s.DB.DeleteFilter(f.ID); err != nil {
+		s.apiInternalError(w, "failed delete filter", err)
+		return
+	}
+	s.audit(r, "filter.delete", fmt.Sprintf("filter:%d", f.ID), toAPIFilter(f), nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeFilter reads and validates a filter from the JSON request body. The
+// whole filter is replaced, so omitted fields are cleared.
+func (s *Server) decodeFilter(w http.ResponseWriter, r *http.Request) (database.URLFilter, bool) {
+	var in apiFilter
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&in); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "Invalid JSON body: "+err.Error())
+		return database.URLFilter{}, false
+	}
+
+	filter := database.URLFilter{
+		Hosts:              trimAll(in.Hosts),
+		ExcludeQueryParams: in.ExcludeQueryParams,
+		PathRegex:          strings.TrimSpace(in.PathRegex),
+		CookiesFile:        strings.TrimSpace(in.CookiesFile),
+		Proxy:              strings.TrimSpace(in.Proxy),
+		Impersonate:        strings.TrimSpace(in.Impersonate),
+		UserAgent:          strings.TrimSpace(in.UserAgent),
+		RateLimit:          strings.TrimSpace(in.RateLimit),
+		Format:             strings.TrimSpace(in.Format),
+		GeoBypassCountry:   strings.ToUpper(strings.TrimSpace(in.GeoBypassCountry)),
+		ExtraArgs:          trimAll(in.ExtraArgs),
+		Postprocess:        trimAll(in.Postprocess),
+		Profile:            strings.TrimSpace(in.Profile),
+	}
+	if err := s.validateFilter(filter); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return database.URLFilter{}, false
+	}
+	return filter, true
+}
+
+func (s *Server) apiFindFilter(w http.ResponseWriter, r *http.Request) (database.URLFilter, bool) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return database.URLFilter{}, false
+	}
+	f, found, err := s.DB.FindFilter(id)
+	if err != nil {
+		s.apiInternalError(w, "failed load filter", err)
+		return database.URLFilter{}, false
+	}
+	if !found {
+		writeAPIError(w, http.StatusNotFound, "not_found", "Filter not found")
+		return database.URLFilter{}, false
+	}
+	return f, true
+}
+
+func (s *Server) apiRespondFilter(w http.ResponseWriter, id int64, status int) {
+	f, _, err := s.DB.FindFilter(id)
+	if err != nil {
+		s.apiInternalError(w, "failed load filter", err)
+		return
+	}
+	writeJSON(w, status, toAPIFilter(f))
+}
+
+// trimAll trims each value and drops empty ones.
+func trimAll(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,65 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
+)
+
+type apiCacheEntry struct {
+	Key       string     `json:"key"`
+	Filename  string     `json:"filename"`
+	Title     string     `json:"title"`
+	Ready     bool       `json:"ready"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (s *Server) apiListCache(w http.ResponseWriter, r *http.Request) {
+	entries := s.Cache.List()
+	items := make([]apiCacheEntry, 0, len(entries))
+	for _, e := range entries {
+		item := apiCacheEntry{Key: e.Key, Filename: e.Filename, Title: e.Title, Ready: e.Ready}
+		if e.Ready {
+			item.ExpiresAt = &e.ExpiresAt
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, apiList[apiCacheEntry]{Items: items})
+}
+
+// apiDeleteCacheEntry evicts the entry named by the key query parameter.
+// Keys can contain slashes, so they aren't part of the path.
+func (s *Server) apiDeleteCacheEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		writeAPIError(w, http.StatusBadRequest, "bad_request", "key is required")
+		return
+	}
+	if !s.Cache.Remove(key) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "No finished cache entry with that key")
+		return
+	}
+	s.audit(r, "cache.delete", "cache:"+key, nil, nil)
+	s.Logger.Info().Str("key", key).Msg("cache entry removed from dashboard")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) apiListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, apiList[jobs.Job]{Items: s.Jobs.List()})
+}
+
+func (s *Server) apiCancelJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	if !s.Jobs.Cancel(id) {
+		writeAPIError(w, http.StatusNotFound, "not_found", "Job not found")
+		return
+	}
+	s.audit(r, "job.cancel", fmt.Sprintf("job:%d", id), nil, nil)
+	s.Logger.Info().Int64("job_id", id).Msg("download cancelled from dashboard")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,153 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+const auditPerPage = 50
+
+var auditSources = []string{database.AuditSourceDashboard, database.AuditSourceAPI, database.AuditSourceTelegram}
+
+// apiAuditEntry is an audit log entry in the JSON export, with its
+// snapshots embedded as JSON rather than strings.
+type apiAuditEntry struct {
+	ID        int64           `json:"id"`
+	Source    string          `json:"source"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	IP        string          `json:"ip"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func toAPIAuditEntry(e database.AuditEntry) apiAuditEntry {
+	snapshot := func(s string) json.RawMessage {
+		if s == "" {
+			return json.RawMessage("null")
+		}
+		return json.RawMessage(s)
+	}
+	return apiAuditEntry{
+		ID:        e.ID,
+		Source:    e.Source,
+		Actor:     e.Actor,
+		Action:    e.Action,
+		Target:    e.Target,
+		Before:    snapshot(e.Before),
+		After:     snapshot(e.After),
+		IP:        e.IP,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// audit records an administrative action by the request's account or API
+// token. before and after are snapshots of the changed object, nil where it
+// didn't exist, and must not hold secrets.
+func (s *Server) audit(r *http.Request, action, target string, before, after any) {
+	actor := userFrom(r.Context()).Username
+	if t, ok := r.Context().Value(tokenCtxKey{}).(database.APIToken); ok {
+		actor = "token:" + t.Name
+	}
+	s.auditAs(r, actor, action, target, before, after)
+}
+
+// auditAs is audit for requests made before anyone is signed in, such as
+// logins.
+func (s *Server) auditAs(r *http.Request, actor, action, target string, before, after any) {
+	source := database.AuditSourceDashboard
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		source = database.AuditSourceAPI
+	}
+
+	err := s.DB.InsertAuditEntry(database.AuditEntry{
+		Source: source,
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Before: database.AuditJSON(before),
+		After:  database.AuditJSON(after),
+		IP:     s.clientIP(r),
+	})
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Str("action", action).Msg("failed write audit log")
+	}
+}
+
+// auditFilter reads the audit page's filters from the query string.
+func auditFilter(r *http.Request) database.AuditFilter {
+	q := r.URL.Query()
+	return database.AuditFilter{
+		Source: q.Get("source"),
+		Action: q.Get("action"),
+		Search: q.Get("search"),
+	}
+}
+
+func (s *Server) auditPage(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	filter := auditFilter(r)
+	filter.Limit = auditPerPage
+	filter.Offset = (page - 1) * auditPerPage
+
+	entries, total, err := s.DB.ListAuditEntries(filter)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list audit log")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	actions, err := s.DB.ListAuditActions()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list audit actions")
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(auditPerPage)))
+
+	data := map[string]any{
+		"Entries":    entries,
+		"Total":      total,
+		"Page":       page,
+		"TotalPages": totalPages,
+		"Sources":    auditSources,
+		"Actions":    actions,
+		"Source":     filter.Source,
+		"Action":     filter.Action,
+		"Search":     filter.Search,
+	}
+
+	tmplMap["audit.html"].ExecuteTemplate(w, "layout", data)
+}
+
+// auditExportHandler downloads every audit log entry matching the page's
+// filters as a JSON array.
+func (s *Server) auditExportHandler(w http.ResponseWriter, r *http.Request) {
+	filter := auditFilter(r)
+	filter.Limit = -1
+
+	entries, _, err := s.DB.ListAuditEntries(filter)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list audit log")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]apiAuditEntry, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, toAPIAuditEntry(e))
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="audit-log-`+time.Now().Format("20060102-150405")+`.json"`)
+	writeJSON(w, http.StatusOK, items)
+}
@@ -1,21 +1,79 @@
 package dashboard
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"net"
 	"net/http"
-	"sync"
+	"net/netip"
+	"slices"
+	"strings"
 	"time"
-)
 
-var (
-	sessions   = make(map[string]time.Time)
-	sessionsMu sync.RWMutex
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const sessionCookieName = "session_token"
 const sessionDuration = 24 * time.Hour
 
+// sessionRenewInterval limits how often an active session's expiry and
+// last-seen time are written back.
+const sessionRenewInterval = time.Minute
+
+// sessionCleanupInterval is how often expired sessions are purged.
+const sessionCleanupInterval = time.Hour
+
+// Dashboard roles, from least to most privileged. Moderators can manage
+// access on top of what viewers can see; admins can change everything.
+const (
+	roleViewer    = "viewer"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+var roles = []string{roleViewer, roleModerator, roleAdmin}
+
+// minPasswordLength is the shortest password accepted; bcrypt caps the
+// longest at 72 bytes.
+const minPasswordLength = 8
+
+// dummyPasswordHash is compared against when a login names an unknown user,
+// so both cases take as long.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+type ctxKey struct{}
+
+// roleAtLeast reports whether role has at least the privileges of min.
+func roleAtLeast(role, min string) bool {
+	return slices.Index(roles, role) >= slices.Index(roles, min)
+}
+
+// roleGrantsScope reports whether a session with role may use an API
+// endpoint that needs scope: everyone can read, moderators can also write
+// access, and admins can write everything.
+func roleGrantsScope(role, scope string) bool {
+	switch {
+	case strings.HasSuffix(scope, ":read"):
+		return true
+	case scope == "access:write":
+		return roleAtLeast(role, roleModerator)
+	default:
+		return roleAtLeast(role, roleAdmin)
+	}
+}
+
+// userFrom returns the signed-in account stored by requireAuth.
+func userFrom(ctx context.Context) database.DashboardUser {
+	u, _ := ctx.Value(ctxKey{}).(database.DashboardUser)
+	return u
+}
+
 func generateToken() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -24,85 +82,346 @@ func generateToken() (string, error) {
 	return hex.EncodeToString(b), nil
 }
 
+// hashToken hashes a session or API token for storage, so a leaked database
+// doesn't leak usable credentials.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// validatePassword returns a user-facing message if password is unusable.
+func validatePassword(password string) string {
+	if len(password) < minPasswordLength {
+		return "Password must be at least 8 characters"
+	}
+	if len(password) > 72 {
+		return "Password must be at most 72 bytes"
+	}
+	return ""
+}
+
+// SeedAdmin creates an admin account from the dashboard config when no
+// accounts exist yet. After that, accounts are managed in the dashboard and
+// the configured credentials are ignored.
+func SeedAdmin(db *database.DB, cfg config.Dashboard, log zerolog.Logger) error {
+	count, err := db.CountDashboardUsers("")
+	if err != nil || count > 0 {
+		return err
+	}
+	if cfg.Username == "" || cfg.Password == "" {
+		log.Warn().Msg("no dashboard accounts exist; set dashboard.username and dashboard.password to create the first admin")
+		return nil
+	}
+
+	hash, err := hashPassword(cfg.Password)
+	if err != nil {
+		return err
+	}
+	if _, _, err := db.InsertDashboardUser(cfg.Username, hash, roleAdmin); err != nil {
+		return err
+	}
+	log.Info().Str("username", cfg.Username).Msg("dashboard admin account created from config")
+	return nil
+}
+
 func (s *Server) loginPage(w http.ResponseWriter, r *http.Request) {
-	if s.isAuthenticated(r) {
+	if _, ok := s.currentUser(w, r); ok {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
-	tmplMap["login.html"].ExecuteTemplate(w, "login.html", nil)
+
+	switch mode := s.Config.Auth.GetMode(); mode {
+	case authModeProxy:
+		s.proxyLogin(w, r)
+	case authModeOIDC:
+		renderLogin(w, http.StatusOK, map[string]any{"Mode": mode})
+	default:
+		tmplMap["login.html"].ExecuteTemplate(w, "login.html", nil)
+	}
 }
 
 func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
-	username := r.FormValue("username")
+	if s.Config.Auth.GetMode() != authModeLocal {
+		http.NotFound(w, r)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
 	password := r.FormValue("password")
+	ip := s.clientIP(r)
+	keys := loginKeys(ip, username)
+
+	if wait := s.logins.lockout(keys...); wait > 0 {
+		s.Logger.Warn().Str("username", username).Str("ip", ip).Msg("dashboard login blocked by rate limit")
+		renderLogin(w, http.StatusTooManyRequests, map[string]any{"Error": lockoutMessage(wait)})
+		return
+	}
 
-	if username != s.Config.Username || password != s.Config.Password {
-		tmplMap["login.html"].ExecuteTemplate(w, "login.html", map[string]string{"Error": "Invalid credentials"})
+	user, found, err := s.DB.FindDashboardUserByUsername(username)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	hash := dummyPasswordHash
+	if found {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !found {
+		s.logins.fail(keys...)
+		s.Logger.Warn().Str("username", username).Str("ip", ip).Msg("dashboard login failed: invalid credentials")
+		s.auditAs(r, username, "login.failed", "", nil, nil)
+		renderLogin(w, http.StatusUnauthorized, map[string]any{"Error": "Invalid credentials"})
 		return
 	}
 
+	if user.TOTPEnabled() {
+		s.startChallenge(w, r, user)
+		return
+	}
+
+	s.logins.reset(keys...)
+	s.startSession(w, r, user)
+}
+
+// startSession signs user in and sends them to the home page.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user database.DashboardUser) {
 	token, err := generateToken()
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	sessionsMu.Lock()
-	sessions[token] = time.Now().Add(sessionDuration)
-	sessionsMu.Unlock()
+	err = s.DB.InsertSession(database.Session{
+		Token:     hashToken(token),
+		UserID:    user.ID,
+		IP:        s.clientIP(r),
+		UserAgent: r.UserAgent(),
+		ExpiresAt: time.Now().Add(sessionDuration),
+	})
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed create session")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.setSessionCookie(w, r, token, int(sessionDuration.Seconds()))
+
+	s.auditAs(r, user.Username, "login", fmt.Sprintf("account:%d", user.ID), nil, nil)
+	s.Logger.Info().Str("username", user.Username).Str("ip", s.clientIP(r)).Msg("dashboard login")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func renderLogin(w http.ResponseWriter, status int, data map[string]any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	tmplMap["login.html"].ExecuteTemplate(w, "login.html", data)
+}
+
+// loginKeys returns the rate limiter keys for a login attempt: one for the
+// client and one for the account, whether or not it exists.
+func loginKeys(ip, username string) []string {
+	return []string{"ip:" + ip, "user:" + strings.ToLower(username)}
+}
+
+func lockoutMessage(wait time.Duration) string {
+	return "Too many failed attempts. Try again in " + (wait + time.Second - 1).Truncate(time.Second).String() + "."
+}
+
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err == nil {
+		if err := s.DB.DeleteSession(hashToken(cookie.Value)); err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed delete session")
+		}
+	}
+
+	s.setSessionCookie(w, r, "", -1)
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
 
+// setSessionCookie sets the session cookie and its CSRF token cookie, or
+// clears both if maxAge is negative. The cookies are marked Secure when the
+// request came over TLS. The CSRF cookie is readable by scripts so
+// layout.html can copy it into forms.
+func (s *Server) setSessionCookie(w http.ResponseWriter, r *http.Request, token string, maxAge int) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     sessionCookieName,
 		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   s.isTLS(r),
 		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(sessionDuration.Seconds()),
+		MaxAge:   maxAge,
 	})
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	csrf := ""
+	if maxAge >= 0 {
+		csrf = csrfToken(token)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrf,
+		Path:     "/",
+		Secure:   s.isTLS(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   maxAge,
+	})
 }
 
-func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+// clientIP returns the address the request came from, without the port.
+// Behind a trusted reverse proxy, that is the nearest untrusted address in
+// X-Forwarded-For.
+func (s *Server) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+
+	if !s.trustedProxy(addr) {
+		return addr.String()
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !s.trustedProxy(hop) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+// fromTrustedProxy reports whether the request came directly from one of
+// the configured reverse proxies.
+func (s *Server) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && s.trustedProxy(addr)
+}
+
+// trustedProxy reports whether addr is one of the configured reverse proxies.
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	return slices.ContainsFunc(s.Config.GetTrustedProxies(), func(p netip.Prefix) bool { return p.Contains(addr.Unmap()) })
+}
+
+// currentSession returns the session and account signed in with the
+// request's cookie. The account is reloaded on every request so role
+// changes and deletions apply immediately. Behind a single sign-on proxy,
+// the session only counts while the proxy still names the same user.
+func (s *Server) currentSession(r *http.Request) (database.Session, database.DashboardUser, bool) {
 	cookie, err := r.Cookie(sessionCookieName)
-	if err == nil {
-		sessionsMu.Lock()
-		delete(sessions, cookie.Value)
-		sessionsMu.Unlock()
+	if err != nil {
+		return database.Session{}, database.DashboardUser{}, false
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:   sessionCookieName,
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
-	})
+	sess, found, err := s.DB.FindSession(hashToken(cookie.Value))
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load session")
+		return database.Session{}, database.DashboardUser{}, false
+	}
+	if !found {
+		return database.Session{}, database.DashboardUser{}, false
+	}
 
-	http.Redirect(w, r, "/login", http.StatusSeeOther)
+	user, found, err := s.DB.FindDashboardUser(sess.UserID)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		return database.Session{}, database.DashboardUser{}, false
+	}
+	if found && s.Config.Auth.GetMode() == authModeProxy {
+		username, _, ok := s.proxyIdentity(r)
+		found = ok && strings.EqualFold(username, user.Username)
+	}
+	return sess, user, found
 }
 
-func (s *Server) isAuthenticated(r *http.Request) bool {
+// sessionToken returns the stored form of the request's session cookie, or
+// "" if there is none.
+func sessionToken(r *http.Request) string {
 	cookie, err := r.Cookie(sessionCookieName)
 	if err != nil {
-		return false
+		return ""
 	}
+	return hashToken(cookie.Value)
+}
 
-	sessionsMu.RLock()
-	expiry, ok := sessions[cookie.Value]
-	sessionsMu.RUnlock()
+// currentUser is currentSession for handlers that may respond: an active
+// session has its expiry pushed out, at most once per sessionRenewInterval.
+func (s *Server) currentUser(w http.ResponseWriter, r *http.Request) (database.DashboardUser, bool) {
+	sess, user, ok := s.currentSession(r)
+	if !ok {
+		return user, false
+	}
 
-	if !ok || time.Now().After(expiry) {
-		return false
+	if time.Since(sess.LastSeenAt) >= sessionRenewInterval {
+		cookie, _ := r.Cookie(sessionCookieName)
+		if err := s.DB.RenewSession(sess.Token, s.clientIP(r), time.Now().Add(sessionDuration)); err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed renew session")
+		} else {
+			s.setSessionCookie(w, r, cookie.Value, int(sessionDuration.Seconds()))
+		}
+	}
+	return user, true
+}
+
+// cleanupSessions purges expired sessions, login failures, login
+// challenges and abandoned single sign-on logins until ctx is done.
+func (s *Server) cleanupSessions(ctx context.Context) {
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.DB.DeleteExpiredSessions()
+		if err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed delete expired sessions")
+		} else if n > 0 {
+			s.Logger.Debug().Int64("count", n).Msg("expired sessions deleted")
+		}
+		s.logins.prune()
+		s.challenges.prune()
+		s.oidc.prune()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
-	return true
 }
 
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.requireRole(roleViewer, next)
+}
+
+// requireRole is requireAuth for handlers that need at least role min.
+func (s *Server) requireRole(min string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !s.isAuthenticated(r) {
+		user, ok := s.currentUser(w, r)
+		if !ok {
 			http.Redirect(w, r, "/login", http.StatusSeeOther)
 			return
 		}
-		next(w, r)
+		if !roleAtLeast(user.Role, min) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, user)))
 	}
 }
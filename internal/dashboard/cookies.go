@@ -0,0 +1,209 @@
+package dashboard
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/cookies"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+const (
+	maxCookieFileSize = 1 << 20 // 1 MB
+	cookieExpiryWarn  = 7 * 24 * time.Hour
+)
+
+// auditCookieJar is a cookie jar as recorded in the audit log: its metadata
+// only, never the cookies.
+type auditCookieJar struct {
+	Site        string    `json:"site"`
+	Domains     []string  `json:"domains"`
+	CookieCount int       `json:"cookie_count"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type cookieJarView struct {
+	database.CookieJar
+	Expired      bool
+	ExpiringSoon bool
+	HasPrevious  bool
+}
+
+func (s *Server) cookiesPage(w http.ResponseWriter, r *http.Request) {
+	jars, err := s.DB.ListCookieJars()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list cookie jars")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	views := make([]cookieJarView, 0, len(jars))
+	for _, j := range jars {
+		views = append(views, cookieJarView{
+			CookieJar:    j,
+			Expired:      !j.ExpiresAt.IsZero() && j.ExpiresAt.Before(now),
+			ExpiringSoon: !j.ExpiresAt.IsZero() && j.ExpiresAt.Before(now.Add(cookieExpiryWarn)),
+			HasPrevious:  s.Cookies.HasPrevious(j.Site),
+		})
+	}
+
+	tmplMap["cookies.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Jars":  views,
+		"Error": r.URL.Query().Get("error"),
+	})
+}
+
+func (s *Server) uploadCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxCookieFileSize+64*1024)
+	if err := r.ParseMultipartForm(maxCookieFileSize); err != nil {
+		redirectCookiesError(w, r, "Upload too large or malformed")
+		return
+	}
+
+	site := strings.ToLower(strings.TrimSpace(r.FormValue("site")))
+	if !cookies.ValidSiteName(site) {
+		redirectCookiesError(w, r, "Site name may only contain a-z, 0-9, '.', '-' and '_'")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		redirectCookiesError(w, r, "Cookie file is required")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxCookieFileSize {
+		redirectCookiesError(w, r, "Upload too large or malformed")
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxCookieFileSize))
+	if err != nil {
+		redirectCookiesError(w, r, "Failed to read cookie file")
+		return
+	}
+
+	summary, err := s.Cookies.Save(site, data)
+	if err != nil {
+		redirectCookiesError(w, r, "Invalid cookie file: "+err.Error())
+		return
+	}
+
+	before := s.cookieJarSnapshot(site)
+	if err := s.DB.UpsertCookieJar(site, s.Cookies.Path(site), summary.Domains, summary.Count, summary.ExpiresAt); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed save cookie jar")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "cookies.upload", "cookies:"+site, before, s.cookieJarSnapshot(site))
+	s.Logger.Info().
+		Str("site", site).
+		Int("cookies", summary.Count).
+		Msg("cookie jar uploaded")
+
+	http.Redirect(w, r, "/cookies", http.StatusSeeOther)
+}
+
+func (s *Server) restoreCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.FormValue("site")
+	if !cookies.ValidSiteName(site) {
+		http.Error(w, "Invalid site", http.StatusBadRequest)
+		return
+	}
+
+	before := s.cookieJarSnapshot(site)
+	summary, err := s.Cookies.Restore(site)
+	if err != nil {
+		redirectCookiesError(w, r, "Failed to restore previous cookies: "+err.Error())
+		return
+	}
+
+	if err := s.DB.UpsertCookieJar(site, s.Cookies.Path(site), summary.Domains, summary.Count, summary.ExpiresAt); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed save cookie jar")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "cookies.restore", "cookies:"+site, before, s.cookieJarSnapshot(site))
+	http.Redirect(w, r, "/cookies", http.StatusSeeOther)
+}
+
+func (s *Server) deleteCookiesHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.FormValue("site")
+	if !cookies.ValidSiteName(site) {
+		http.Error(w, "Invalid site", http.StatusBadRequest)
+		return
+	}
+
+	before := s.cookieJarSnapshot(site)
+	if err := s.Cookies.Remove(site); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed remove cookie file")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.DB.DeleteCookieJar(site); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete cookie jar")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "cookies.delete", "cookies:"+site, before, nil)
+
+	http.Redirect(w, r, "/cookies", http.StatusSeeOther)
+}
+
+// cookieJarSnapshot returns the site's cookie jar as recorded in the audit
+// log, or nil if there is none.
+func (s *Server) cookieJarSnapshot(site string) any {
+	jars, err := s.DB.ListCookieJars()
+	if err != nil {
+		return nil
+	}
+	for _, j := range jars {
+		if j.Site == site {
+			return auditCookieJar{
+				Site:        j.Site,
+				Domains:     nonNil(j.Domains),
+				CookieCount: j.CookieCount,
+				ExpiresAt:   j.ExpiresAt,
+			}
+		}
+	}
+	return nil
+}
+
+// cookieWarnings returns human-readable warnings for jars that are expiring
+// or whose downloads have started failing with login-required errors.
+func (s *Server) cookieWarnings() []string {
+	jars, err := s.DB.ListCookieJars()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list cookie jars")
+		return nil
+	}
+
+	now := time.Now()
+	var warnings []string
+	for _, j := range jars {
+		switch {
+		case !j.ExpiresAt.IsZero() && j.ExpiresAt.Before(now):
+			warnings = append(warnings, fmt.Sprintf("Cookies for %s have expired.", j.Site))
+		case !j.ExpiresAt.IsZero() && j.ExpiresAt.Before(now.Add(cookieExpiryWarn)):
+			warnings = append(warnings, fmt.Sprintf("Cookies for %s expire on %s.", j.Site, j.ExpiresAt.Format("Jan 02, 2006")))
+		}
+		if j.LoginFailures > 0 {
+			warnings = append(warnings, fmt.Sprintf("Downloads using cookies for %s failed with login required %d time(s) since upload.", j.Site, j.LoginFailures))
+		}
+	}
+	return warnings
+}
+
+func redirectCookiesError(w http.ResponseWriter, r *http.Request, msg string) {
+	http.Redirect(w, r, "/cookies?error="+url.QueryEscape(msg), http.StatusSeeOther)
+}
@@ -1,46 +1,417 @@
 package dashboard
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
 	"math"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/baranovskis/go-ytdlp-bot/internal/database"
 )
 
 const downloadsPerPage = 50
 
+// downloadSortColumns are the downloads table's sortable columns.
+var downloadSortColumns = []string{"id", "url", "user", "chat", "status", "filename", "time"}
+
+// downloadFilter reads download filters and sorting from the query string,
+// shared by the downloads page, its export and the API. On an invalid value
+// it returns the name of the offending parameter.
+func downloadFilter(q url.Values) (database.DownloadFilter, string) {
+	f := database.DownloadFilter{
+		Status:    q.Get("status"),
+		Domain:    q.Get("domain"),
+		Extractor: q.Get("extractor"),
+		ErrorKind: q.Get("error_kind"),
+		Search:    q.Get("search"),
+		Sort:      q.Get("sort"),
+		Asc:       q.Get("dir") == "asc",
+	}
+
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"user_id", &f.UserID}, {"chat_id", &f.ChatID}} {
+		if raw := q.Get(p.name); raw != "" {
+			v, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return f, p.name
+			}
+			*p.dst = v
+		}
+	}
+
+	// Dates are whole UTC days; "to" includes its day.
+	if raw := q.Get("from"); raw != "" {
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return f, "from"
+		}
+		f.Since = t
+	}
+	if raw := q.Get("to"); raw != "" {
+		t, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return f, "to"
+		}
+		f.Until = t.AddDate(0, 0, 1)
+	}
+
+	if f.Sort != "" && !database.ValidDownloadSort(f.Sort) {
+		return f, "sort"
+	}
+	return f, ""
+}
+
 func (s *Server) downloadsPage(w http.ResponseWriter, r *http.Request) {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
 		page = 1
 	}
 
-	status := r.URL.Query().Get("status")
-	userID, _ := strconv.ParseInt(r.URL.Query().Get("user_id"), 10, 64)
+	filter, bad := downloadFilter(r.URL.Query())
+	if bad != "" {
+		http.Error(w, "Invalid "+bad, http.StatusBadRequest)
+		return
+	}
+	filter.Limit = downloadsPerPage
+	filter.Offset = (page - 1) * downloadsPerPage
 
-	downloads, total, err := s.DB.ListDownloads(database.DownloadFilter{
-		Status: status,
-		UserID: userID,
-		Limit:  downloadsPerPage,
-		Offset: (page - 1) * downloadsPerPage,
-	})
+	downloads, total, err := s.DB.ListDownloads(filter)
 	if err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed list downloads")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	extractors, err := s.DB.ListDownloadExtractors()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list download extractors")
+	}
+	errorKinds, err := s.DB.ListDownloadErrorKinds()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list download error kinds")
+	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(downloadsPerPage)))
 
+	// Links keep the current filters; sort links flip the direction of the
+	// column already sorted by.
+	q := r.URL.Query()
+	q.Del("page")
+	sortLinks := make(map[string]template.URL, len(downloadSortColumns))
+	for _, col := range downloadSortColumns {
+		sq := url.Values{}
+		for k, v := range q {
+			sq[k] = v
+		}
+		sq.Set("sort", col)
+		sq.Del("dir")
+		if filter.Sort == col && !filter.Asc {
+			sq.Set("dir", "asc")
+		}
+		sortLinks[col] = template.URL("/downloads?" + sq.Encode())
+	}
+
 	data := map[string]any{
 		"Downloads":  downloads,
 		"Total":      total,
 		"Page":       page,
 		"TotalPages": totalPages,
-		"Status":     status,
-		"UserID":     userID,
+		"Filter":     filter,
+		"From":       q.Get("from"),
+		"To":         q.Get("to"),
+		"ChatID":     q.Get("chat_id"),
+		"UserID":     q.Get("user_id"),
+		"Extractors": extractors,
+		"ErrorKinds": errorKinds,
+		"Query":      template.URL(q.Encode()),
+		"SortLinks":  sortLinks,
 	}
 
 	tmplMap["downloads.html"].ExecuteTemplate(w, "layout", data)
 }
+
+// downloadsExportHandler streams every download matching the page's filters
+// as CSV or, with format=ndjson, one JSON object per line.
+func (s *Server) downloadsExportHandler(w http.ResponseWriter, r *http.Request) {
+	filter, bad := downloadFilter(r.URL.Query())
+	if bad != "" {
+		http.Error(w, "Invalid "+bad, http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	name := "downloads-" + time.Now().Format("20060102-150405")
+
+	var write func(database.Download) error
+	var finish func() error
+	switch format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		cw := csv.NewWriter(w)
+		cw.Write(downloadCSVHeader)
+		write = func(d database.Download) error { return cw.Write(downloadCSVRecord(d)) }
+		finish = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.ndjson"`)
+		enc := json.NewEncoder(w)
+		write = func(d database.Download) error { return enc.Encode(toAPIDownload(d)) }
+		finish = func() error { return nil }
+	default:
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	err := s.DB.EachDownload(filter, write)
+	if err == nil {
+		err = finish()
+	}
+	if err != nil {
+		// The response has started, so the export is cut short.
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed export downloads")
+	}
+}
+
+var downloadCSVHeader = []string{
+	"id", "created_at", "finished_at", "status", "error_kind", "error_message", "url", "title", "extractor",
+	"telegram_user_id", "telegram_username", "chat_id", "filename", "file_size", "duration", "format",
+	"attempt", "retry_of", "total_ms",
+}
+
+// downloadCSVRecord returns d as a CSV row. Its text cells go through
+// csvText, since most of them come from users or uploaders.
+func downloadCSVRecord(d database.Download) []string {
+	finished := ""
+	if !d.FinishedAt.IsZero() {
+		finished = d.FinishedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatInt(d.ID, 10),
+		d.CreatedAt.UTC().Format(time.RFC3339),
+		finished,
+		csvText(d.Status),
+		csvText(d.ErrorKind),
+		csvText(d.ErrorMessage),
+		csvText(d.URL),
+		csvText(d.Title),
+		csvText(d.Extractor),
+		strconv.FormatInt(d.TelegramUserID, 10),
+		csvText(d.TelegramUsername),
+		strconv.FormatInt(d.ChatID, 10),
+		csvText(d.Filename),
+		strconv.FormatInt(d.FileSize, 10),
+		strconv.FormatFloat(d.Duration, 'f', -1, 64),
+		csvText(d.Format),
+		strconv.Itoa(d.Attempt),
+		strconv.FormatInt(d.RetryOf, 10),
+		strconv.FormatInt(d.Timings.Total().Milliseconds(), 10),
+	}
+}
+
+// csvText keeps a spreadsheet from running text as a formula, by quoting it
+// with a leading ' when it starts with a character that begins one.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// downloadPage shows one download with its metadata, retries, correlated
+// log lines and, while the file is cached, a player.
+func (s *Server) downloadPage(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.findDownload(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	logs, err := s.DB.ListDownloadLogs(d.ID)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list download logs")
+	}
+	retries, err := s.DB.ListRetries(d.ID)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list download retries")
+	}
+	_, cached := s.cachedFile(d)
+
+	tmplMap["download.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Download": d,
+		"Logs":     logs,
+		"Retries":  retries,
+		"Cached":   cached,
+		"Playable": cached && playableExts[filepath.Ext(d.Filename)],
+		"Notice":   downloadNotices[r.URL.Query().Get("notice")],
+		"Error":    r.URL.Query().Get("error"),
+	})
+}
+
+// downloadNotices are the confirmations the download page shows after a
+// redirect, keyed by the notice query parameter.
+var downloadNotices = map[string]string{
+	"resent":   "File sent to the chat again.",
+	"uncached": "Cached file deleted.",
+}
+
+// playableExts are the file types browsers can play inline.
+var playableExts = map[string]bool{".mp4": true, ".webm": true, ".gif": true, ".mp3": true, ".m4a": true}
+
+// downloadFileHandler serves the download's cached file for the page's
+// player, with range requests for seeking.
+func (s *Server) downloadFileHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.findDownload(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	path, ok := s.cachedFile(d)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, d.Filename, fi.ModTime(), f)
+}
+
+func (s *Server) retryDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.findDownload(w, r, r.FormValue("id"))
+	if !ok {
+		return
+	}
+	if d.Status == "pending" {
+		redirectDownloadError(w, r, d.ID, "Download is still in progress")
+		return
+	}
+
+	id, err := s.Bot.Retry(r.Context(), d)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed retry download")
+		redirectDownloadError(w, r, d.ID, "Retry failed: "+err.Error())
+		return
+	}
+
+	s.audit(r, "download.retry", fmt.Sprintf("download:%d", d.ID), toAPIDownload(d), map[string]int64{"retry_id": id})
+	http.Redirect(w, r, fmt.Sprintf("/downloads/%d", id), http.StatusSeeOther)
+}
+
+func (s *Server) resendDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.findDownload(w, r, r.FormValue("id"))
+	if !ok {
+		return
+	}
+
+	if err := s.Bot.Resend(r.Context(), d); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed resend download")
+		redirectDownloadError(w, r, d.ID, "Resend failed: "+err.Error())
+		return
+	}
+
+	s.audit(r, "download.resend", fmt.Sprintf("download:%d", d.ID), nil, nil)
+	http.Redirect(w, r, fmt.Sprintf("/downloads/%d?notice=resent", d.ID), http.StatusSeeOther)
+}
+
+// uncacheDownloadHandler evicts the download's file from the cache, so the
+// next request for it downloads it again.
+func (s *Server) uncacheDownloadHandler(w http.ResponseWriter, r *http.Request) {
+	d, ok := s.findDownload(w, r, r.FormValue("id"))
+	if !ok {
+		return
+	}
+
+	if d.CacheKey == "" || !s.Cache.Remove(d.CacheKey) {
+		redirectDownloadError(w, r, d.ID, "The file is not cached")
+		return
+	}
+
+	s.audit(r, "cache.delete", "cache:"+d.CacheKey, nil, nil)
+	s.Logger.Info().Str("key", d.CacheKey).Msg("cache entry removed from dashboard")
+	http.Redirect(w, r, fmt.Sprintf("/downloads/%d?notice=uncached", d.ID), http.StatusSeeOther)
+}
+
+// findDownload loads the download with the given ID, writing an error
+// response if there is none.
+func (s *Server) findDownload(w http.ResponseWriter, r *http.Request, rawID string) (database.Download, bool) {
+	id, _ := strconv.ParseInt(rawID, 10, 64)
+	if id == 0 {
+		http.Error(w, "Invalid download ID", http.StatusBadRequest)
+		return database.Download{}, false
+	}
+	d, found, err := s.DB.FindDownload(id)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load download")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return database.Download{}, false
+	}
+	if !found {
+		http.NotFound(w, r)
+		return database.Download{}, false
+	}
+	return d, true
+}
+
+// cachedFile returns the path of the download's file if it is still in the
+// download cache.
+func (s *Server) cachedFile(d database.Download) (string, bool) {
+	if d.CacheKey == "" || s.Cache == nil {
+		return "", false
+	}
+	result, ok := s.Cache.Lookup(d.CacheKey)
+	if !ok {
+		return "", false
+	}
+	return result.FilePath, true
+}
+
+func redirectDownloadError(w http.ResponseWriter, r *http.Request, id int64, msg string) {
+	http.Redirect(w, r, fmt.Sprintf("/downloads/%d?error=%s", id, url.QueryEscape(msg)), http.StatusSeeOther)
+}
+
+// formatBytes renders a size in bytes with a binary unit, such as "4.2 MB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration renders a duration rounded for display: to the millisecond
+// under a second, and to the tenth of a second under a minute.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
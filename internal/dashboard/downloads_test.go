@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Cat video", "Cat video"},
+		{"https://example.com/v", "https://example.com/v"},
+		{"a=b", "a=b"},
+		{`=HYPERLINK("https://evil.example","x")`, `'=HYPERLINK("https://evil.example","x")`},
+		{"+1+1", "'+1+1"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+	}
+
+	for _, tt := range tests {
+		if got := csvText(tt.in); got != tt.want {
+			t.Errorf("csvText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDownloadCSVRecordEscapesFormulas(t *testing.T) {
+	d := database.Download{
+		ID:               7,
+		ChatID:           -1001234,
+		URL:              "https://example.com/v",
+		Title:            "=cmd|' /C calc'!A0",
+		TelegramUsername: "@alice",
+		ErrorMessage:     "-boom",
+	}
+
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	cw.Write(downloadCSVHeader)
+	cw.Write(downloadCSVRecord(d))
+	cw.Flush()
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := map[string]string{}
+	for i, name := range downloadCSVHeader {
+		row[name] = rows[1][i]
+	}
+
+	want := map[string]string{
+		"title":             "'=cmd|' /C calc'!A0",
+		"telegram_username": "'@alice",
+		"error_message":     "'-boom",
+		"url":               "https://example.com/v",
+		// Numbers are not text and stay as they are.
+		"chat_id": "-1001234",
+	}
+	for name, v := range want {
+		if row[name] != v {
+			t.Errorf("%s = %q, want %q", name, row[name], v)
+		}
+	}
+	if len(rows[1]) != len(downloadCSVHeader) || !slices.Equal(rows[0], downloadCSVHeader) {
+		t.Errorf("rows = %q, want a header and a record of %d cells", rows, len(downloadCSVHeader))
+	}
+}
@@ -1,9 +1,16 @@
 package dashboard
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/postprocess"
+	"github.com/baranovskis/go-ytdlp-bot/internal/ytdlp"
 )
 
 func (s *Server) filtersPage(w http.ResponseWriter, r *http.Request) {
@@ -14,29 +21,35 @@ func (s *Server) filtersPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	jars, err := s.DB.ListCookieJars()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list cookie jars")
+	}
+
 	tmplMap["filters.html"].ExecuteTemplate(w, "layout", map[string]any{
-		"Filters": filters,
+		"Filters":           filters,
+		"CookieJars":        jars,
+		"AllowedExtraArgs":  ytdlp.AllowedExtraArgs(),
+		"PostprocessStages": postprocess.Names,
+		"Profiles":          s.Profiles,
 	})
 }
 
 func (s *Server) addFilterHandler(w http.ResponseWriter, r *http.Request) {
-	hostsRaw := r.FormValue("hosts")
-	hosts := parseHostsInput(hostsRaw)
-	if len(hosts) == 0 {
-		http.Error(w, "At least one host is required", http.StatusBadRequest)
+	filter, ok := s.parseFilterForm(w, r)
+	if !ok {
 		return
 	}
 
-	excludeQP := r.FormValue("exclude_query_params") == "on"
-	pathRegex := strings.TrimSpace(r.FormValue("path_regex"))
-	cookiesFile := strings.TrimSpace(r.FormValue("cookies_file"))
-
-	if _, err := s.DB.InsertFilter(hosts, excludeQP, pathRegex, cookiesFile); err != nil {
+	id, err := s.DB.InsertFilter(filter)
+	if err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed add filter")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "filter.create", fmt.Sprintf("filter:%d", id), nil, s.filterSnapshot(id))
+
 	http.Redirect(w, r, "/filters", http.StatusSeeOther)
 }
 
@@ -47,23 +60,21 @@ func (s *Server) updateFilterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hostsRaw := r.FormValue("hosts")
-	hosts := parseHostsInput(hostsRaw)
-	if len(hosts) == 0 {
-		http.Error(w, "At least one host is required", http.StatusBadRequest)
+	filter, ok := s.parseFilterForm(w, r)
+	if !ok {
 		return
 	}
+	filter.ID = id
 
-	excludeQP := r.FormValue("exclude_query_params") == "on"
-	pathRegex := strings.TrimSpace(r.FormValue("path_regex"))
-	cookiesFile := strings.TrimSpace(r.FormValue("cookies_file"))
-
-	if err := s.DB.UpdateFilter(id, hosts, excludeQP, pathRegex, cookiesFile); err != nil {
+	before := s.filterSnapshot(id)
+	if err := s.DB.UpdateFilter(filter); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed update filter")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "filter.update", fmt.Sprintf("filter:%d", id), before, s.filterSnapshot(id))
+
 	http.Redirect(w, r, "/filters", http.StatusSeeOther)
 }
 
@@ -74,15 +85,82 @@ func (s *Server) deleteFilterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := s.filterSnapshot(id)
 	if err := s.DB.DeleteFilter(id); err != nil {
 		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete filter")
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
+	s.audit(r, "filter.delete", fmt.Sprintf("filter:%d", id), before, nil)
+
 	http.Redirect(w, r, "/filters", http.StatusSeeOther)
 }
 
+// filterSnapshot returns the filter as recorded in the audit log, or nil if
+// it doesn't exist.
+func (s *Server) filterSnapshot(id int64) any {
+	f, found, err := s.DB.FindFilter(id)
+	if err != nil || !found {
+		return nil
+	}
+	return toAPIFilter(f)
+}
+
+// parseFilterForm reads the filter fields shared by the add and update forms.
+// It writes a 400 response and returns false when the input is invalid.
+func (s *Server) parseFilterForm(w http.ResponseWriter, r *http.Request) (database.URLFilter, bool) {
+	filter := database.URLFilter{
+		Hosts:              parseHostsInput(r.FormValue("hosts")),
+		ExcludeQueryParams: r.FormValue("exclude_query_params") == "on",
+		PathRegex:          strings.TrimSpace(r.FormValue("path_regex")),
+		CookiesFile:        strings.TrimSpace(r.FormValue("cookies_file")),
+		Proxy:              strings.TrimSpace(r.FormValue("proxy")),
+		Impersonate:        strings.TrimSpace(r.FormValue("impersonate")),
+		UserAgent:          strings.TrimSpace(r.FormValue("user_agent")),
+		RateLimit:          strings.TrimSpace(r.FormValue("rate_limit")),
+		Format:             strings.TrimSpace(r.FormValue("format")),
+		GeoBypassCountry:   strings.ToUpper(strings.TrimSpace(r.FormValue("geo_bypass_country"))),
+		ExtraArgs:          parseLinesInput(r.FormValue("extra_args")),
+		Postprocess:        parseLinesInput(r.FormValue("postprocess")),
+		Profile:            strings.TrimSpace(r.FormValue("profile")),
+	}
+
+	if err := s.validateFilter(filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return database.URLFilter{}, false
+	}
+	return filter, true
+}
+
+// validateFilter checks the fields of a filter from the dashboard or API.
+func (s *Server) validateFilter(f database.URLFilter) error {
+	if len(f.Hosts) == 0 {
+		return errors.New("At least one host is required")
+	}
+	if err := ytdlp.ValidateExtraArgs(f.ExtraArgs); err != nil {
+		return fmt.Errorf("Invalid extra arguments: %w", err)
+	}
+	if err := postprocess.Validate(f.Postprocess); err != nil {
+		return fmt.Errorf("Invalid post-processing stages: %w", err)
+	}
+	if f.Profile != "" && !slices.Contains(s.Profiles, f.Profile) {
+		return fmt.Errorf("Unknown encoding profile: %s", f.Profile)
+	}
+	return nil
+}
+
+func parseLinesInput(raw string) []string {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
 func parseHostsInput(raw string) []string {
 	var hosts []string
 	for _, line := range strings.Split(raw, "\n") {
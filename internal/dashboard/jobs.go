@@ -0,0 +1,62 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (s *Server) jobsPage(w http.ResponseWriter, r *http.Request) {
+	tmplMap["jobs.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Jobs": s.Jobs.List(),
+	})
+}
+
+func (s *Server) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id == 0 {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return
+	}
+
+	if s.Jobs.Cancel(id) {
+		s.audit(r, "job.cancel", fmt.Sprintf("job:%d", id), nil, nil)
+		s.Logger.Info().Int64("job_id", id).Msg("download cancelled from dashboard")
+	}
+
+	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+}
+
+func (s *Server) jobsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	s.sendJobs(w, flusher)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			s.sendJobs(w, flusher)
+		}
+	}
+}
+
+func (s *Server) sendJobs(w http.ResponseWriter, flusher http.Flusher) {
+	data, _ := json.Marshal(s.Jobs.List())
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flusher.Flush()
+}
@@ -0,0 +1,97 @@
+package dashboard
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// loginFreeAttempts is how many failed logins are allowed before each
+	// further failure locks the key out.
+	loginFreeAttempts = 5
+	// loginBaseLockout is the first lockout; each further failure doubles it.
+	loginBaseLockout = 30 * time.Second
+	// loginMaxLockout caps the lockout.
+	loginMaxLockout = time.Hour
+	// loginFailureTTL is how long a key's failures are remembered after its
+	// last one.
+	loginFailureTTL = 24 * time.Hour
+)
+
+// loginLimiter counts failed logins per client IP and per account name and
+// locks them out for exponentially longer after loginFreeAttempts.
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{failures: make(map[string]*loginFailures)}
+}
+
+// lockout returns how long the longest-locked of keys remains locked out,
+// or zero if none is.
+func (l *loginLimiter) lockout(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var longest time.Duration
+	for _, key := range keys {
+		if f, ok := l.failures[key]; ok {
+			longest = max(longest, time.Until(f.lockedUntil))
+		}
+	}
+	return longest
+}
+
+// fail records a failed login for each key.
+func (l *loginLimiter) fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		f, ok := l.failures[key]
+		if !ok || now.Sub(f.last) > loginFailureTTL {
+			f = &loginFailures{}
+			l.failures[key] = f
+		}
+		f.count++
+		f.last = now
+		if over := f.count - loginFreeAttempts; over > 0 {
+			lockout := loginMaxLockout
+			if over <= 10 {
+				lockout = min(loginBaseLockout<<(over-1), loginMaxLockout)
+			}
+			f.lockedUntil = now.Add(lockout)
+		}
+	}
+}
+
+// reset forgets the failures of keys after a successful login.
+func (l *loginLimiter) reset(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, key := range keys {
+		delete(l.failures, key)
+	}
+}
+
+// prune drops keys whose failures have expired.
+func (l *loginLimiter) prune() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, f := range l.failures {
+		if time.Since(f.last) > loginFailureTTL {
+			delete(l.failures, key)
+		}
+	}
+}
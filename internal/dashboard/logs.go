@@ -2,16 +2,40 @@ package dashboard
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/logquery"
 )
 
 const logsPerPage = 100
 
+// logQuery reads a log search from the query string: the search parameter
+// in logquery syntax, narrowed by the level and download_id parameters.
+func logQuery(r *http.Request) (logquery.Query, error) {
+	v := r.URL.Query()
+	q, err := logquery.Parse(v.Get("search"), time.Now())
+	if err != nil {
+		return logquery.Query{}, err
+	}
+	if level := v.Get("level"); level != "" {
+		q.Level = level
+	}
+	if raw := v.Get("download_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return logquery.Query{}, errors.New("download_id: not a number")
+		}
+		q.DownloadID = id
+	}
+	return q, nil
+}
+
 func (s *Server) logsPage(w http.ResponseWriter, r *http.Request) {
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	if page < 1 {
@@ -21,16 +45,21 @@ func (s *Server) logsPage(w http.ResponseWriter, r *http.Request) {
 	level := r.URL.Query().Get("level")
 	search := r.URL.Query().Get("search")
 
-	logs, total, err := s.DB.ListLogs(database.LogFilter{
-		Level:  level,
-		Search: search,
-		Limit:  logsPerPage,
-		Offset: (page - 1) * logsPerPage,
-	})
-	if err != nil {
-		s.Logger.Error().Str("reason", err.Error()).Msg("failed list logs")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+	var logs []database.LogEntry
+	var total int
+	q, queryErr := logQuery(r)
+	if queryErr == nil {
+		var err error
+		logs, total, err = s.DB.ListLogs(database.LogFilter{
+			Query:  q,
+			Limit:  logsPerPage,
+			Offset: (page - 1) * logsPerPage,
+		})
+		if err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed list logs")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(logsPerPage)))
@@ -42,6 +71,10 @@ func (s *Server) logsPage(w http.ResponseWriter, r *http.Request) {
 		"TotalPages": totalPages,
 		"Level":      level,
 		"Search":     search,
+		"DownloadID": r.URL.Query().Get("download_id"),
+	}
+	if queryErr != nil {
+		data["Error"] = "Invalid search: " + queryErr.Error()
 	}
 
 	tmplMap["logs.html"].ExecuteTemplate(w, "layout", data)
@@ -54,6 +87,13 @@ func (s *Server) logsStreamHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Live lines are filtered like the page they are shown on.
+	q, err := logQuery(r)
+	if err != nil {
+		http.Error(w, "Invalid search: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -69,6 +109,15 @@ func (s *Server) logsStreamHandler(w http.ResponseWriter, r *http.Request) {
 			if !ok {
 				return
 			}
+			if !q.Match(logquery.Entry{
+				Level:      event.Level,
+				Message:    event.Message,
+				Fields:     event.Fields,
+				DownloadID: event.DownloadID,
+				Time:       time.Now(),
+			}) {
+				continue
+			}
 			data, _ := json.Marshal(event)
 			fmt.Fprintf(w, "data: %s\n\n", data)
 			flusher.Flush()
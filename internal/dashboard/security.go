@@ -0,0 +1,136 @@
+package dashboard
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	csrfCookieName = "csrf_token"
+	csrfFormField  = "csrf_token"
+	csrfHeader     = "X-CSRF-Token"
+)
+
+// maxFormSize caps dashboard form bodies, which are read here to find the
+// CSRF token. The largest form is the 1 MB cookie upload.
+const maxFormSize = 2 << 20
+
+// contentSecurityPolicy allows the Tailwind CDN and the dashboard's own
+// inline scripts and styles, and nothing from anywhere else.
+const contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' https://cdn.tailwindcss.com; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// csrfToken derives the CSRF token for a session from its cookie value, so
+// it needs no storage and can't be guessed without the session.
+func csrfToken(sessionCookie string) string {
+	sum := sha256.Sum256([]byte("csrf:" + sessionCookie))
+	return hex.EncodeToString(sum[:])
+}
+
+// isTLS reports whether the request reached the dashboard over HTTPS,
+// directly or through a trusted proxy. X-Forwarded-Proto from anyone else
+// is ignored.
+func (s *Server) isTLS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return r.Header.Get("X-Forwarded-Proto") == "https" && s.fromTrustedProxy(r)
+}
+
+// secure wraps the dashboard with security headers and CSRF checks.
+//
+// State-changing requests made with a session cookie must carry the
+// session's CSRF token, in the X-CSRF-Token header or the csrf_token form
+// field. layout.html copies it from the csrf_token cookie into every form
+// on submit. API requests authenticated with a valid bearer token carry no
+// ambient credentials and are exempt.
+func (s *Server) secure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "same-origin")
+		if s.isTLS(r) {
+			h.Set("Strict-Transport-Security", "max-age=31536000")
+		}
+
+		if !s.checkCSRF(w, r) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkCSRF validates the CSRF token of state-changing requests, writing a
+// 403 response if it is missing or wrong.
+func (s *Server) checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	// The second login step is bound to its SameSite challenge cookie.
+	if r.URL.Path == "/login" || r.URL.Path == "/login/totp" {
+		return true
+	}
+	api := strings.HasPrefix(r.URL.Path, "/api/")
+	if api && s.tokenAuthenticated(r) {
+		return true
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		// Not signed in; requireAuth turns the request away.
+		return true
+	}
+
+	token := r.Header.Get(csrfHeader)
+	if token == "" && !api {
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormSize)
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(maxFormSize); err != nil {
+				http.Error(w, "Request too large or malformed", http.StatusBadRequest)
+				return false
+			}
+		}
+		token = r.PostFormValue(csrfFormField)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(csrfToken(cookie.Value))) == 1 {
+		return true
+	}
+
+	s.Logger.Warn().
+		Str("path", r.URL.Path).
+		Str("ip", s.clientIP(r)).
+		Msg("request rejected: missing or invalid CSRF token")
+	if api {
+		writeAPIError(w, http.StatusForbidden, "forbidden", "Missing or invalid X-CSRF-Token header")
+	} else {
+		http.Error(w, "Invalid or expired form, reload the page and try again", http.StatusForbidden)
+	}
+	return false
+}
+
+// tokenAuthenticated reports whether r carries a valid API token, which
+// requireAPIAuth then uses in place of the session cookie.
+func (s *Server) tokenAuthenticated(r *http.Request) bool {
+	token, ok := bearerToken(r)
+	if !ok {
+		return false
+	}
+	_, found, err := s.lookupAPIToken(token)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed look up API token")
+	}
+	return found
+}
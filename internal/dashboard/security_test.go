@@ -0,0 +1,44 @@
+package dashboard
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+)
+
+func TestIsTLS(t *testing.T) {
+	s := &Server{Config: config.Dashboard{TrustedProxies: []string{"10.0.0.0/8"}}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		proto      string
+		want       bool
+	}{
+		{"plain", "192.0.2.1:1234", false, "", false},
+		{"direct TLS", "192.0.2.1:1234", true, "", true},
+		{"trusted proxy over HTTPS", "10.0.0.5:1234", false, "https", true},
+		{"trusted proxy over HTTP", "10.0.0.5:1234", false, "http", false},
+		{"untrusted client claiming HTTPS", "192.0.2.1:1234", false, "https", false},
+		{"unparseable address", "somewhere", false, "https", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if tt.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			if got := s.isTLS(r); got != tt.want {
+				t.Errorf("isTLS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,15 +3,19 @@ package dashboard
 import (
 	"context"
 	"embed"
-	"fmt"
 	"html/template"
 	"io/fs"
 	"net/http"
 	"time"
 
+	"github.com/baranovskis/go-ytdlp-bot/internal/cache"
 	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/cookies"
 	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/jobs"
 	"github.com/baranovskis/go-ytdlp-bot/internal/logger"
+	"github.com/baranovskis/go-ytdlp-bot/internal/retention"
+	"github.com/baranovskis/go-ytdlp-bot/internal/updater"
 	"github.com/rs/zerolog"
 )
 
@@ -21,6 +25,9 @@ var templateFS embed.FS
 //go:embed static/*
 var staticFS embed.FS
 
+//go:embed openapi.yaml
+var openAPISpec []byte
+
 var tmplMap map[string]*template.Template
 
 type Server struct {
@@ -28,15 +35,47 @@ type Server struct {
 	DB        *database.DB
 	Logger    zerolog.Logger
 	LogWriter *logger.DBWriter
+	Cookies   *cookies.Store
+	Updater   *updater.Updater
+	Jobs      *jobs.Registry
+	Cache     *cache.Cache
+	Bot       Downloader
+	Retention *retention.Pruner
+	Profiles  []string
 	srv       *http.Server
+
+	logins     *loginLimiter
+	challenges *challengeStore
+	oidc       *oidcClient
+}
+
+// Downloader starts downloads on behalf of the dashboard.
+type Downloader interface {
+	// Retry downloads d's URL again as a new download and returns its ID.
+	// The download runs until it finishes or the bot shuts down, whatever
+	// happens to ctx.
+	Retry(ctx context.Context, d database.Download) (int64, error)
+	// Resend posts d's cached file to d's chat again.
+	Resend(ctx context.Context, d database.Download) error
 }
 
-func NewServer(cfg config.Dashboard, db *database.DB, log zerolog.Logger, logWriter *logger.DBWriter) *Server {
+func NewServer(cfg config.Dashboard, db *database.DB, log zerolog.Logger, logWriter *logger.DBWriter, cookieStore *cookies.Store, ytdlpUpdater *updater.Updater, jobRegistry *jobs.Registry, downloadCache *cache.Cache, downloader Downloader, pruner *retention.Pruner, profiles []string) *Server {
 	return &Server{
 		Config:    cfg,
 		DB:        db,
 		Logger:    log,
 		LogWriter: logWriter,
+		Cookies:   cookieStore,
+		Updater:   ytdlpUpdater,
+		Jobs:      jobRegistry,
+		Cache:     downloadCache,
+		Bot:       downloader,
+		Retention: pruner,
+		Profiles:  profiles,
+
+		logins:     newLoginLimiter(),
+		challenges: newChallengeStore(),
+		oidc:       newOIDCClient(),
 	}
 }
 
@@ -44,9 +83,13 @@ func (s *Server) Run(ctx context.Context) {
 	funcMap := template.FuncMap{
 		"add":      func(a, b int) int { return a + b },
 		"subtract": func(a, b int) int { return a - b },
+		"bytes":    formatBytes,
+		"duration": formatDuration,
+		"age":      formatAge,
+		"seconds":  func(s float64) string { return formatDuration(time.Duration(s * float64(time.Second))) },
 	}
 
-	pages := []string{"home.html", "downloads.html", "logs.html", "stats.html", "access.html", "filters.html", "login.html"}
+	pages := []string{"home.html", "downloads.html", "download.html", "logs.html", "stats.html", "access.html", "filters.html", "cookies.html", "subscriptions.html", "jobs.html", "tokens.html", "users.html", "account.html", "sessions.html", "audit.html", "storage.html", "login.html"}
 	tmplMap = make(map[string]*template.Template, len(pages))
 	for _, page := range pages {
 		t, err := template.New("").Funcs(funcMap).ParseFS(templateFS, "templates/layout.html", "templates/"+page)
@@ -63,35 +106,79 @@ func (s *Server) Run(ctx context.Context) {
 
 	mux.HandleFunc("GET /login", s.loginPage)
 	mux.HandleFunc("POST /login", s.loginHandler)
+	mux.HandleFunc("POST /login/totp", s.loginTOTPHandler)
+	mux.HandleFunc("GET /login/oidc", s.oidcLoginHandler)
+	mux.HandleFunc("GET /login/oidc/callback", s.oidcCallbackHandler)
 	mux.HandleFunc("POST /logout", s.requireAuth(s.logoutHandler))
 
 	mux.HandleFunc("GET /downloads", s.requireAuth(s.downloadsPage))
+	mux.HandleFunc("GET /downloads/export", s.requireAuth(s.downloadsExportHandler))
+	mux.HandleFunc("GET /downloads/{id}", s.requireAuth(s.downloadPage))
+	mux.HandleFunc("GET /downloads/{id}/file", s.requireAuth(s.downloadFileHandler))
+	mux.HandleFunc("POST /downloads/retry", s.requireRole(roleAdmin, s.retryDownloadHandler))
+	mux.HandleFunc("POST /downloads/resend", s.requireRole(roleAdmin, s.resendDownloadHandler))
+	mux.HandleFunc("POST /downloads/uncache", s.requireRole(roleAdmin, s.uncacheDownloadHandler))
 	mux.HandleFunc("GET /logs", s.requireAuth(s.logsPage))
 	mux.HandleFunc("GET /api/logs/stream", s.requireAuth(s.logsStreamHandler))
+	mux.HandleFunc("GET /jobs", s.requireAuth(s.jobsPage))
+	mux.HandleFunc("GET /api/jobs/stream", s.requireAuth(s.jobsStreamHandler))
+	mux.HandleFunc("POST /jobs/cancel", s.requireRole(roleAdmin, s.cancelJobHandler))
 	mux.HandleFunc("GET /stats", s.requireAuth(s.statsPage))
 	mux.HandleFunc("GET /api/stats/stream", s.requireAuth(s.statsStreamHandler))
 	mux.HandleFunc("GET /access", s.requireAuth(s.accessPage))
-	mux.HandleFunc("POST /access/groups/approve", s.requireAuth(s.approveGroupHandler))
-	mux.HandleFunc("POST /access/groups/reject", s.requireAuth(s.rejectGroupHandler))
-	mux.HandleFunc("POST /access/groups/remove", s.requireAuth(s.removeGroupHandler))
-	mux.HandleFunc("POST /access/users/approve", s.requireAuth(s.approveUserHandler))
-	mux.HandleFunc("POST /access/users/reject", s.requireAuth(s.rejectUserHandler))
-	mux.HandleFunc("POST /access/users/remove", s.requireAuth(s.removeUserHandler))
+	mux.HandleFunc("POST /access/groups/approve", s.requireRole(roleModerator, s.approveGroupHandler))
+	mux.HandleFunc("POST /access/groups/reject", s.requireRole(roleModerator, s.rejectGroupHandler))
+	mux.HandleFunc("POST /access/groups/remove", s.requireRole(roleModerator, s.removeGroupHandler))
+	mux.HandleFunc("POST /access/users/approve", s.requireRole(roleModerator, s.approveUserHandler))
+	mux.HandleFunc("POST /access/users/reject", s.requireRole(roleModerator, s.rejectUserHandler))
+	mux.HandleFunc("POST /access/users/remove", s.requireRole(roleModerator, s.removeUserHandler))
 	mux.HandleFunc("GET /filters", s.requireAuth(s.filtersPage))
-	mux.HandleFunc("POST /filters/add", s.requireAuth(s.addFilterHandler))
-	mux.HandleFunc("POST /filters/update", s.requireAuth(s.updateFilterHandler))
-	mux.HandleFunc("POST /filters/delete", s.requireAuth(s.deleteFilterHandler))
+	mux.HandleFunc("POST /filters/add", s.requireRole(roleAdmin, s.addFilterHandler))
+	mux.HandleFunc("POST /filters/update", s.requireRole(roleAdmin, s.updateFilterHandler))
+	mux.HandleFunc("POST /filters/delete", s.requireRole(roleAdmin, s.deleteFilterHandler))
+	mux.HandleFunc("GET /cookies", s.requireAuth(s.cookiesPage))
+	mux.HandleFunc("POST /cookies/upload", s.requireRole(roleAdmin, s.uploadCookiesHandler))
+	mux.HandleFunc("POST /cookies/restore", s.requireRole(roleAdmin, s.restoreCookiesHandler))
+	mux.HandleFunc("POST /cookies/delete", s.requireRole(roleAdmin, s.deleteCookiesHandler))
+	mux.HandleFunc("GET /subscriptions", s.requireAuth(s.subscriptionsPage))
+	mux.HandleFunc("POST /subscriptions/add", s.requireRole(roleAdmin, s.addSubscriptionHandler))
+	mux.HandleFunc("POST /subscriptions/delete", s.requireRole(roleAdmin, s.deleteSubscriptionHandler))
+	mux.HandleFunc("GET /tokens", s.requireRole(roleAdmin, s.tokensPage))
+	mux.HandleFunc("POST /tokens/create", s.requireRole(roleAdmin, s.createTokenHandler))
+	mux.HandleFunc("POST /tokens/delete", s.requireRole(roleAdmin, s.deleteTokenHandler))
+	mux.HandleFunc("GET /users", s.requireRole(roleAdmin, s.usersPage))
+	mux.HandleFunc("POST /users/add", s.requireRole(roleAdmin, s.addUserHandler))
+	mux.HandleFunc("POST /users/update", s.requireRole(roleAdmin, s.updateUserHandler))
+	mux.HandleFunc("POST /users/delete", s.requireRole(roleAdmin, s.deleteUserHandler))
+	mux.HandleFunc("GET /account", s.requireAuth(s.accountPage))
+	mux.HandleFunc("POST /account/password", s.requireAuth(s.changePasswordHandler))
+	mux.HandleFunc("POST /account/totp/setup", s.requireAuth(s.setupTOTPHandler))
+	mux.HandleFunc("POST /account/totp/enable", s.requireAuth(s.enableTOTPHandler))
+	mux.HandleFunc("POST /account/totp/disable", s.requireAuth(s.disableTOTPHandler))
+	mux.HandleFunc("POST /account/totp/recovery", s.requireAuth(s.regenerateRecoveryCodesHandler))
+	mux.HandleFunc("GET /sessions", s.requireAuth(s.sessionsPage))
+	mux.HandleFunc("POST /sessions/revoke", s.requireAuth(s.revokeSessionHandler))
+	mux.HandleFunc("POST /sessions/revoke-others", s.requireAuth(s.revokeOtherSessionsHandler))
+	mux.HandleFunc("POST /ytdlp/update", s.requireRole(roleAdmin, s.updateYtdlpHandler))
+	mux.HandleFunc("GET /audit", s.requireRole(roleAdmin, s.auditPage))
+	mux.HandleFunc("GET /audit/export", s.requireRole(roleAdmin, s.auditExportHandler))
+	mux.HandleFunc("GET /storage", s.requireRole(roleAdmin, s.storagePage))
+	mux.HandleFunc("POST /storage/prune", s.requireRole(roleAdmin, s.pruneHandler))
+	mux.HandleFunc("POST /storage/vacuum", s.requireRole(roleAdmin, s.vacuumHandler))
+
+	s.registerAPI(mux)
 
 	mux.HandleFunc("GET /", s.requireAuth(s.homePage))
 
-	port := s.Config.Port
-	if port == 0 {
-		port = 8080
+	tlsCfg, redirect, err := s.tlsConfig(ctx)
+	if err != nil {
+		s.Logger.Fatal().Str("reason", err.Error()).Msg("failed load dashboard certificate")
 	}
 
 	s.srv = &http.Server{
-		Addr:        fmt.Sprintf(":%d", port),
-		Handler:     mux,
+		Addr:        s.Config.GetAddr(),
+		Handler:     s.secure(mux),
+		TLSConfig:   tlsCfg,
 		ReadTimeout: 10 * time.Second,
 		IdleTimeout: 60 * time.Second,
 	}
@@ -103,9 +190,20 @@ func (s *Server) Run(ctx context.Context) {
 		s.srv.Shutdown(shutdownCtx)
 	}()
 
-	s.Logger.Info().Int("port", port).Msg("dashboard server started")
+	go s.cleanupSessions(ctx)
+
+	if tlsCfg != nil && s.Config.TLS.RedirectPort > 0 {
+		go s.serveRedirect(ctx, redirect)
+	}
 
-	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	s.Logger.Info().Str("addr", s.srv.Addr).Bool("tls", tlsCfg != nil).Msg("dashboard server started")
+
+	if tlsCfg != nil {
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		s.Logger.Error().Str("reason", err.Error()).Msg("dashboard server error")
 	}
 }
@@ -118,6 +216,17 @@ func (s *Server) homePage(w http.ResponseWriter, r *http.Request) {
 
 	stats, _ := s.DB.GetStats()
 	tmplMap["home.html"].ExecuteTemplate(w, "layout", map[string]any{
-		"Stats": stats,
+		"Stats":          stats,
+		"CookieWarnings": s.cookieWarnings(),
+		"Ytdlp":          s.Updater.Status(),
 	})
 }
+
+func (s *Server) updateYtdlpHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.Updater.Trigger() {
+		s.Logger.Warn().Msg("yt-dlp update already in progress")
+	} else {
+		s.audit(r, "ytdlp.update", "ytdlp", nil, nil)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
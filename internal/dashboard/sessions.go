@@ -0,0 +1,104 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// auditSession is a session as recorded in the audit log, without its
+// token.
+type auditSession struct {
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// sessionsPage lists the signed-in account's sessions, or everyone's for
+// admins.
+func (s *Server) sessionsPage(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	var userID int64
+	if user.Role != roleAdmin {
+		userID = user.ID
+	}
+
+	sessions, err := s.DB.ListSessions(userID)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list sessions")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tmplMap["sessions.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Sessions":      sessions,
+		"Current":       sessionToken(r),
+		"CurrentUserID": user.ID,
+		"AllUsers":      userID == 0,
+	})
+}
+
+// revokeSessionHandler signs a session out. Admins can revoke anyone's
+// session; everyone else only their own.
+func (s *Server) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	if token == "" {
+		http.Error(w, "Invalid session", http.StatusBadRequest)
+		return
+	}
+
+	sess, found, err := s.DB.FindSession(token)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load session")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+		return
+	}
+	user := userFrom(r.Context())
+	if sess.UserID != user.ID && user.Role != roleAdmin {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	if err := s.DB.DeleteSession(token); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete session")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "session.revoke", fmt.Sprintf("account:%d", sess.UserID), auditSession{
+		Username:  sess.Username,
+		IP:        sess.IP,
+		UserAgent: sess.UserAgent,
+		CreatedAt: sess.CreatedAt,
+	}, nil)
+	s.Logger.Info().
+		Str("username", sess.Username).
+		Str("ip", sess.IP).
+		Str("by", user.Username).
+		Msg("dashboard session revoked")
+
+	if token == sessionToken(r) {
+		s.setSessionCookie(w, r, "", -1)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+}
+
+func (s *Server) revokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	if err := s.DB.DeleteUserSessions(user.ID, sessionToken(r)); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete sessions")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "session.revoke_others", fmt.Sprintf("account:%d", user.ID), nil, nil)
+	s.Logger.Info().Str("username", user.Username).Msg("other dashboard sessions revoked")
+	http.Redirect(w, r, "/sessions", http.StatusSeeOther)
+}
@@ -0,0 +1,435 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	authModeLocal = "local"
+	authModeProxy = "proxy"
+	authModeOIDC  = "oidc"
+
+	// proxyIssuer is the issuer recorded for accounts the reverse proxy
+	// signs in, which are identified by their username alone.
+	proxyIssuer = "proxy"
+
+	oidcStateCookieName = "oidc_state"
+	// oidcLoginDuration is how long a round trip to the identity provider
+	// may take.
+	oidcLoginDuration = 10 * time.Minute
+)
+
+var (
+	// errNoRole is returned for people whose groups map to no dashboard role.
+	errNoRole = errors.New("no dashboard role for this account")
+	// errUsernameTaken is returned on the first sign-in of someone whose
+	// username belongs to a local account or to another identity.
+	errUsernameTaken = errors.New("username belongs to another dashboard account")
+)
+
+// ssoIdentity is someone signed in by the proxy or identity provider.
+type ssoIdentity struct {
+	Issuer   string
+	Subject  string // stable ID at the issuer
+	Username string
+}
+
+// pendingOIDCLogin is a sign-in sent to the identity provider, keyed by its
+// state parameter.
+type pendingOIDCLogin struct {
+	verifier string
+	nonce    string
+	expires  time.Time
+}
+
+// oidcClient discovers the provider on first use, so the dashboard starts
+// even while the provider is unreachable.
+type oidcClient struct {
+	mu       sync.Mutex
+	provider *oidc.Provider
+	pending  map[string]pendingOIDCLogin
+}
+
+func newOIDCClient() *oidcClient {
+	return &oidcClient{pending: make(map[string]pendingOIDCLogin)}
+}
+
+func (c *oidcClient) add(state string, login pendingOIDCLogin) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[state] = login
+}
+
+// take removes and returns the unexpired login for state.
+func (c *oidcClient) take(state string) (pendingOIDCLogin, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	login, ok := c.pending[state]
+	delete(c.pending, state)
+	return login, ok && time.Now().Before(login.expires)
+}
+
+// prune drops logins that never came back.
+func (c *oidcClient) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for state, login := range c.pending {
+		if time.Now().After(login.expires) {
+			delete(c.pending, state)
+		}
+	}
+}
+
+// oidcConfig returns the OAuth2 client for the configured provider and a
+// verifier for its ID tokens.
+func (s *Server) oidcConfig(ctx context.Context, r *http.Request) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	cfg := s.Config.Auth.OIDC
+
+	s.oidc.mu.Lock()
+	provider := s.oidc.provider
+	s.oidc.mu.Unlock()
+	if provider == nil {
+		p, err := oidc.NewProvider(ctx, cfg.Issuer)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.oidc.mu.Lock()
+		s.oidc.provider = p
+		s.oidc.mu.Unlock()
+		provider = p
+	}
+
+	redirectURL := cfg.RedirectURL
+	if redirectURL == "" {
+		scheme := "http"
+		if s.isTLS(r) {
+			scheme = "https"
+		}
+		redirectURL = scheme + "://" + r.Host + "/login/oidc/callback"
+	}
+
+	oauth := &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  redirectURL,
+		Scopes:       cfg.GetScopes(),
+	}
+	return oauth, provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}), nil
+}
+
+// oidcLoginHandler sends the browser to the identity provider.
+func (s *Server) oidcLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Config.Auth.GetMode() != authModeOIDC {
+		http.NotFound(w, r)
+		return
+	}
+
+	oauth, _, err := s.oidcConfig(r.Context(), r)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed discover OpenID Connect provider")
+		renderLogin(w, http.StatusBadGateway, map[string]any{"Mode": authModeOIDC, "Error": "The identity provider is unavailable"})
+		return
+	}
+
+	state, err := generateToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := generateToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+	s.oidc.add(state, pendingOIDCLogin{verifier: verifier, nonce: nonce, expires: time.Now().Add(oidcLoginDuration)})
+
+	// Lax, not Strict: the provider sends the browser back with a
+	// cross-site redirect.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    state,
+		Path:     "/login/oidc",
+		HttpOnly: true,
+		Secure:   s.isTLS(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcLoginDuration.Seconds()),
+	})
+	http.Redirect(w, r, oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusSeeOther)
+}
+
+// oidcCallbackHandler finishes a sign-in when the identity provider sends
+// the browser back.
+func (s *Server) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Config.Auth.GetMode() != authModeOIDC {
+		http.NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	fail := func(status int, msg string) {
+		renderLogin(w, status, map[string]any{"Mode": authModeOIDC, "Error": msg})
+	}
+
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil || q.Get("state") == "" || cookie.Value != q.Get("state") {
+		fail(http.StatusBadRequest, "Sign-in expired, try again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Value: "", Path: "/login/oidc", MaxAge: -1})
+	login, ok := s.oidc.take(cookie.Value)
+	if !ok {
+		fail(http.StatusBadRequest, "Sign-in expired, try again")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		s.Logger.Warn().Str("error", e).Str("description", q.Get("error_description")).Str("ip", s.clientIP(r)).Msg("dashboard login failed: identity provider refused")
+		fail(http.StatusUnauthorized, "The identity provider refused the sign-in")
+		return
+	}
+
+	oauth, verifier, err := s.oidcConfig(r.Context(), r)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed discover OpenID Connect provider")
+		fail(http.StatusBadGateway, "The identity provider is unavailable")
+		return
+	}
+
+	token, err := oauth.Exchange(r.Context(), q.Get("code"), oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		s.Logger.Warn().Str("reason", err.Error()).Str("ip", s.clientIP(r)).Msg("dashboard login failed: code exchange")
+		fail(http.StatusUnauthorized, "Sign-in failed, try again")
+		return
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	idToken, err := verifier.Verify(r.Context(), rawIDToken)
+	if err != nil || idToken.Nonce != login.nonce {
+		reason := "nonce mismatch"
+		if err != nil {
+			reason = err.Error()
+		}
+		s.Logger.Warn().Str("reason", reason).Str("ip", s.clientIP(r)).Msg("dashboard login failed: invalid ID token")
+		fail(http.StatusUnauthorized, "Sign-in failed, try again")
+		return
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		s.Logger.Warn().Str("reason", err.Error()).Msg("dashboard login failed: invalid ID token claims")
+		fail(http.StatusUnauthorized, "Sign-in failed, try again")
+		return
+	}
+	usernameClaim := s.Config.Auth.OIDC.GetUsernameClaim()
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		s.Logger.Warn().Str("claim", usernameClaim).Str("subject", idToken.Subject).Msg("dashboard login failed: ID token has no username claim")
+		fail(http.StatusUnauthorized, "Your account has no username")
+		return
+	}
+
+	identity := ssoIdentity{Issuer: idToken.Issuer, Subject: idToken.Subject, Username: username}
+	user, err := s.externalAccount(identity, claimStrings(claims[s.Config.Auth.OIDC.GetGroupsClaim()]))
+	if msg, refused := s.externalLoginRefused(r, username, err); refused {
+		fail(http.StatusForbidden, msg)
+		return
+	}
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.startSession(w, r, user)
+}
+
+// proxyLogin signs in the user a trusted reverse proxy names in its user
+// header.
+func (s *Server) proxyLogin(w http.ResponseWriter, r *http.Request) {
+	username, groups, ok := s.proxyIdentity(r)
+	if !ok {
+		s.Logger.Warn().Str("ip", s.clientIP(r)).Msg("dashboard login failed: no user from a trusted proxy")
+		renderLogin(w, http.StatusUnauthorized, map[string]any{"Mode": authModeProxy, "Error": "Sign in through the single sign-on proxy"})
+		return
+	}
+
+	identity := ssoIdentity{Issuer: proxyIssuer, Subject: username, Username: username}
+	user, err := s.externalAccount(identity, groups)
+	if msg, refused := s.externalLoginRefused(r, username, err); refused {
+		renderLogin(w, http.StatusForbidden, map[string]any{"Mode": authModeProxy, "Error": msg})
+		return
+	}
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.startSession(w, r, user)
+}
+
+// proxyIdentity returns the user and groups named by the reverse proxy the
+// request came through. Headers from anyone but a trusted proxy are
+// ignored.
+func (s *Server) proxyIdentity(r *http.Request) (string, []string, bool) {
+	if !s.fromTrustedProxy(r) {
+		return "", nil, false
+	}
+
+	username := strings.TrimSpace(r.Header.Get(s.Config.Auth.GetProxyUserHeader()))
+	if username == "" {
+		return "", nil, false
+	}
+
+	var groups []string
+	if header := s.Config.Auth.ProxyGroupsHeader; header != "" {
+		for group := range strings.SplitSeq(r.Header.Get(header), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return username, groups, true
+}
+
+// externalLoginRefused logs and audits a single sign-on that externalAccount
+// turned away, returning the message to show. It returns false for other
+// errors.
+func (s *Server) externalLoginRefused(r *http.Request, username string, err error) (string, bool) {
+	var msg string
+	switch {
+	case errors.Is(err, errNoRole):
+		s.Logger.Warn().Str("username", username).Str("ip", s.clientIP(r)).Msg("dashboard login failed: no role for account")
+		msg = "Your account has no access to this dashboard"
+	case errors.Is(err, errUsernameTaken):
+		s.Logger.Warn().Str("username", username).Str("ip", s.clientIP(r)).Msg("dashboard login failed: username belongs to another account")
+		msg = "Another dashboard account already uses your username, ask an administrator"
+	default:
+		return "", false
+	}
+	s.auditAs(r, username, "login.failed", "", nil, nil)
+	return msg, true
+}
+
+// externalAccount returns the account for someone the proxy or identity
+// provider signed in, creating it on first sign-in. Accounts are found by
+// their identity at the issuer, never by username alone, so a sign-in can't
+// take over a local account that happens to share the name. When roles are
+// mapped from groups, the account's role follows its groups on every
+// sign-in; otherwise new accounts get the default role and admins manage it
+// from there. Either way, people who are given no role are turned away.
+func (s *Server) externalAccount(identity ssoIdentity, groups []string) (database.DashboardUser, error) {
+	role, ok := s.externalRole(groups)
+	if !ok {
+		return database.DashboardUser{}, errNoRole
+	}
+
+	user, found, err := s.DB.FindDashboardUserBySSO(identity.Issuer, identity.Subject)
+	if err != nil {
+		return database.DashboardUser{}, err
+	}
+	if !found {
+		user, found, err = s.claimExternalAccount(identity)
+		if err != nil {
+			return database.DashboardUser{}, err
+		}
+		if !found {
+			return s.createExternalAccount(identity, role)
+		}
+	}
+
+	if len(s.Config.Auth.Roles) == 0 {
+		return user, nil
+	}
+	if role != user.Role {
+		if err := s.DB.UpdateDashboardUserRole(user.ID, role); err != nil {
+			return database.DashboardUser{}, err
+		}
+		s.Logger.Info().Str("username", user.Username).Str("role", role).Msg("dashboard account role updated from single sign-on")
+		user.Role = role
+	}
+	return user, nil
+}
+
+// claimExternalAccount links identity to the account of the same name that
+// single sign-on created before identities were stored, if there is one.
+// Accounts with a local password or another identity are never claimed.
+func (s *Server) claimExternalAccount(identity ssoIdentity) (database.DashboardUser, bool, error) {
+	user, found, err := s.DB.FindDashboardUserByUsername(identity.Username)
+	if err != nil || !found {
+		return database.DashboardUser{}, false, err
+	}
+	if user.PasswordHash != "" || user.SSOSubject != "" {
+		return database.DashboardUser{}, false, errUsernameTaken
+	}
+	if err := s.DB.LinkDashboardUserSSO(user.ID, identity.Issuer, identity.Subject); err != nil {
+		return database.DashboardUser{}, false, err
+	}
+	s.Logger.Info().Str("username", user.Username).Str("issuer", identity.Issuer).Msg("dashboard account linked to single sign-on identity")
+	user.SSOIssuer, user.SSOSubject = identity.Issuer, identity.Subject
+	return user, true, nil
+}
+
+// createExternalAccount creates the account for the first sign-in of
+// identity. Accounts from the proxy or identity provider have no local
+// password.
+func (s *Server) createExternalAccount(identity ssoIdentity, role string) (database.DashboardUser, error) {
+	id, created, err := s.DB.InsertSSODashboardUser(identity.Username, role, identity.Issuer, identity.Subject)
+	if err != nil {
+		return database.DashboardUser{}, err
+	}
+	if !created {
+		// Someone else took the name since the lookup.
+		return database.DashboardUser{}, errUsernameTaken
+	}
+	s.Logger.Info().Str("username", identity.Username).Str("role", role).Msg("dashboard account created from single sign-on")
+
+	user, found, err := s.DB.FindDashboardUser(id)
+	if err != nil {
+		return database.DashboardUser{}, err
+	}
+	if !found {
+		return database.DashboardUser{}, errors.New("dashboard account vanished after creation")
+	}
+	return user, nil
+}
+
+// externalRole returns the most privileged role whose configured groups
+// include one of groups, or the default role. It returns false if neither
+// applies.
+func (s *Server) externalRole(groups []string) (string, bool) {
+	for _, role := range slices.Backward(roles) {
+		if slices.ContainsFunc(s.Config.Auth.Roles[role], func(g string) bool { return slices.Contains(groups, g) }) {
+			return role, true
+		}
+	}
+	role := s.Config.Auth.GetDefaultRole()
+	return role, slices.Contains(roles, role)
+}
+
+// claimStrings reads a claim that providers send either as one string or
+// as a list of strings.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,196 @@
+package dashboard
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/rs/zerolog"
+)
+
+func newSSOTestServer(t *testing.T, auth config.Auth) *Server {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return &Server{Config: config.Dashboard{Auth: auth}, DB: db, Logger: zerolog.Nop()}
+}
+
+var testRoles = map[string][]string{
+	roleAdmin:     {"admins"},
+	roleModerator: {"mods"},
+}
+
+func TestExternalRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		roles       map[string][]string
+		defaultRole string
+		groups      []string
+		want        string
+		wantOK      bool
+	}{
+		{"no mapping, no default", nil, "", []string{"admins"}, "", false},
+		{"no mapping, viewer default", nil, roleViewer, nil, roleViewer, true},
+		{"mapped group", testRoles, "", []string{"mods"}, roleModerator, true},
+		{"most privileged wins", testRoles, "", []string{"mods", "admins"}, roleAdmin, true},
+		{"unmapped group, no default", testRoles, "", []string{"staff"}, "", false},
+		{"unmapped group, viewer default", testRoles, roleViewer, []string{"staff"}, roleViewer, true},
+		{"explicit none", testRoles, "none", []string{"staff"}, "", false},
+		{"unknown default", testRoles, "owner", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{Config: config.Dashboard{Auth: config.Auth{Roles: tt.roles, DefaultRole: tt.defaultRole}}}
+			got, ok := s.externalRole(tt.groups)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("externalRole(%v) = %q, %v, want %q, %v", tt.groups, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestExternalAccount(t *testing.T) {
+	type signIn struct {
+		identity ssoIdentity
+		groups   []string
+		wantRole string
+		wantErr  error
+	}
+	alice := ssoIdentity{Issuer: "https://sso.example.com", Subject: "1", Username: "alice"}
+
+	tests := []struct {
+		name    string
+		auth    config.Auth
+		signIns []signIn
+	}{
+		{
+			name: "role follows groups",
+			auth: config.Auth{Roles: testRoles},
+			signIns: []signIn{
+				{alice, []string{"admins"}, roleAdmin, nil},
+				{alice, []string{"mods"}, roleModerator, nil},
+				{alice, []string{"staff"}, "", errNoRole},
+			},
+		},
+		{
+			name: "default role without mapping",
+			auth: config.Auth{DefaultRole: roleViewer},
+			signIns: []signIn{
+				{alice, nil, roleViewer, nil},
+			},
+		},
+		{
+			name: "nobody gets in by default",
+			auth: config.Auth{},
+			signIns: []signIn{
+				{alice, []string{"admins"}, "", errNoRole},
+			},
+		},
+		{
+			name: "local account is not taken over",
+			auth: config.Auth{DefaultRole: roleViewer},
+			signIns: []signIn{
+				{ssoIdentity{Issuer: "https://sso.example.com", Subject: "2", Username: "admin"}, nil, "", errUsernameTaken},
+				{ssoIdentity{Issuer: proxyIssuer, Subject: "admin", Username: "admin"}, nil, "", errUsernameTaken},
+			},
+		},
+		{
+			name: "name of another identity is not taken over",
+			auth: config.Auth{DefaultRole: roleViewer},
+			signIns: []signIn{
+				{alice, nil, roleViewer, nil},
+				{ssoIdentity{Issuer: "https://other.example.com", Subject: "1", Username: "alice"}, nil, "", errUsernameTaken},
+				{ssoIdentity{Issuer: proxyIssuer, Subject: "alice", Username: "alice"}, nil, "", errUsernameTaken},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSSOTestServer(t, tt.auth)
+			if _, _, err := s.DB.InsertDashboardUser("admin", "$2a$10$hash", roleAdmin); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, in := range tt.signIns {
+				user, err := s.externalAccount(in.identity, in.groups)
+				if !errors.Is(err, in.wantErr) {
+					t.Fatalf("sign-in %d: error = %v, want %v", i, err, in.wantErr)
+				}
+				if err != nil {
+					continue
+				}
+				if user.Role != in.wantRole || user.Username != in.identity.Username {
+					t.Errorf("sign-in %d: got %s as %s, want %s as %s", i, user.Username, user.Role, in.identity.Username, in.wantRole)
+				}
+				if user.SSOIssuer != in.identity.Issuer || user.SSOSubject != in.identity.Subject {
+					t.Errorf("sign-in %d: identity = %s %s, want %s %s", i, user.SSOIssuer, user.SSOSubject, in.identity.Issuer, in.identity.Subject)
+				}
+			}
+
+			admin, _, err := s.DB.FindDashboardUserByUsername("admin")
+			if err != nil || admin.Role != roleAdmin || admin.SSOSubject != "" {
+				t.Errorf("local admin = %+v, %v, want it unchanged", admin, err)
+			}
+		})
+	}
+}
+
+func TestExternalAccountKeepsManagedRole(t *testing.T) {
+	s := newSSOTestServer(t, config.Auth{DefaultRole: roleViewer})
+	alice := ssoIdentity{Issuer: proxyIssuer, Subject: "alice", Username: "alice"}
+
+	user, err := s.externalAccount(alice, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.UpdateDashboardUserRole(user.ID, roleModerator); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a group mapping, the role an admin gave the account sticks.
+	user, err = s.externalAccount(alice, nil)
+	if err != nil || user.Role != roleModerator {
+		t.Errorf("externalAccount() = %s, %v, want %s", user.Role, err, roleModerator)
+	}
+
+	// Switching the default to none turns existing accounts away too.
+	s.Config.Auth.DefaultRole = "none"
+	if _, err := s.externalAccount(alice, nil); !errors.Is(err, errNoRole) {
+		t.Errorf("externalAccount() error = %v, want %v", err, errNoRole)
+	}
+}
+
+func TestExternalAccountClaimsLegacyAccount(t *testing.T) {
+	s := newSSOTestServer(t, config.Auth{Roles: testRoles})
+	// Accounts single sign-on created before identities were stored have
+	// no password and no identity.
+	id, _, err := s.DB.InsertDashboardUser("bob", "", roleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob := ssoIdentity{Issuer: "https://sso.example.com", Subject: "42", Username: "bob"}
+	user, err := s.externalAccount(bob, []string{"mods"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != id || user.Role != roleModerator || user.SSOSubject != "42" {
+		t.Errorf("externalAccount() = %+v, want account %d claimed as %s", user, id, roleModerator)
+	}
+
+	// Once claimed, the name can't be claimed by another identity.
+	other := ssoIdentity{Issuer: "https://sso.example.com", Subject: "43", Username: "bob"}
+	if _, err := s.externalAccount(other, []string{"mods"}); !errors.Is(err, errUsernameTaken) {
+		t.Errorf("externalAccount() error = %v, want %v", err, errUsernameTaken)
+	}
+}
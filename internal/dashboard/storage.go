@@ -0,0 +1,90 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+// storageNotices are the confirmations the storage page shows after a
+// redirect, keyed by the notice query parameter.
+var storageNotices = map[string]string{
+	"pruning":  "Pruning started. Reload the page to see the result.",
+	"running":  "Pruning is already running.",
+	"vacuumed": "The database file was compacted.",
+}
+
+func (s *Server) storagePage(w http.ResponseWriter, r *http.Request) {
+	tables, err := s.DB.TableSizes()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed get table sizes")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	size, err := s.DB.Size()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed get database size")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	cfg := s.Retention.Config()
+	tmplMap["storage.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Tables":   tables,
+		"Size":     size,
+		"Rules":    s.Retention.Rules(),
+		"Status":   s.Retention.Status(),
+		"LogLevel": cfg.GetLogLevel(),
+		"Interval": cfg.GetInterval(),
+		"Error":    r.URL.Query().Get("error"),
+		"Notice":   storageNotices[r.URL.Query().Get("notice")],
+	})
+}
+
+func (s *Server) pruneHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.Retention.Trigger() {
+		http.Redirect(w, r, "/storage?notice=running", http.StatusSeeOther)
+		return
+	}
+	s.audit(r, "storage.prune", "database", nil, nil)
+	http.Redirect(w, r, "/storage?notice=pruning", http.StatusSeeOther)
+}
+
+// vacuumHandler compacts the database file. Writers wait while it runs.
+func (s *Server) vacuumHandler(w http.ResponseWriter, r *http.Request) {
+	before, _ := s.DB.Size()
+	if err := s.DB.Vacuum(); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed vacuum database")
+		http.Redirect(w, r, "/storage?error="+url.QueryEscape("Vacuum failed: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+	after, _ := s.DB.Size()
+
+	s.audit(r, "storage.vacuum", "database", storageSize(before), storageSize(after))
+	s.Logger.Info().Int64("before", before.Bytes).Int64("after", after.Bytes).Msg("database vacuumed from dashboard")
+	http.Redirect(w, r, "/storage?notice=vacuumed", http.StatusSeeOther)
+}
+
+// storageSize is a database size in the audit log.
+func storageSize(size database.DatabaseSize) map[string]int64 {
+	return map[string]int64{"bytes": size.Bytes, "free_bytes": size.FreeBytes}
+}
+
+// formatAge renders a retention age in its largest whole unit, such as
+// "30d" for 720 hours.
+func formatAge(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d >= day && d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
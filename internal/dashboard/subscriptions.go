@@ -0,0 +1,117 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+// auditSubscription is a subscription as recorded in the audit log.
+type auditSubscription struct {
+	ID     int64  `json:"id"`
+	ChatID int64  `json:"chat_id"`
+	URL    string `json:"url"`
+	Title  string `json:"title"`
+}
+
+func toAuditSubscription(sub database.Subscription) auditSubscription {
+	return auditSubscription{ID: sub.ID, ChatID: sub.ChatID, URL: sub.URL, Title: sub.Title}
+}
+
+func (s *Server) subscriptionsPage(w http.ResponseWriter, r *http.Request) {
+	subs, err := s.DB.ListSubscriptions(0)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list subscriptions")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tmplMap["subscriptions.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Subscriptions": subs,
+		"Error":         r.URL.Query().Get("error"),
+	})
+}
+
+func (s *Server) addSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(strings.TrimSpace(r.FormValue("chat_id")), 10, 64)
+	if err != nil || chatID == 0 {
+		redirectSubscriptionsError(w, r, "Invalid chat ID")
+		return
+	}
+
+	u, err := url.Parse(strings.TrimSpace(r.FormValue("url")))
+	if err != nil || u.Host == "" {
+		redirectSubscriptionsError(w, r, "Invalid channel URL")
+		return
+	}
+
+	groupAllowed, _ := s.DB.IsGroupAllowed(chatID)
+	userAllowed, _ := s.DB.IsUserAllowed(chatID)
+	if !groupAllowed && !userAllowed {
+		redirectSubscriptionsError(w, r, "Chat is not an approved group or user")
+		return
+	}
+
+	created, err := s.DB.InsertSubscription(chatID, u.String(), "", 0, "dashboard")
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed insert subscription")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		redirectSubscriptionsError(w, r, "That chat is already subscribed to this channel")
+		return
+	}
+
+	if sub, found, err := s.DB.FindSubscription(chatID, u.String()); err == nil && found {
+		s.audit(r, "subscription.create", fmt.Sprintf("subscription:%d", sub.ID), nil, toAuditSubscription(sub))
+	}
+	s.Logger.Info().
+		Int64("chat_id", chatID).
+		Str("url", u.String()).
+		Msg("subscription added from dashboard")
+
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+func (s *Server) deleteSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id == 0 {
+		http.Error(w, "Invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	before := s.subscriptionSnapshot(id)
+	if err := s.DB.DeleteSubscription(id); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete subscription")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "subscription.delete", fmt.Sprintf("subscription:%d", id), before, nil)
+
+	http.Redirect(w, r, "/subscriptions", http.StatusSeeOther)
+}
+
+// subscriptionSnapshot returns the subscription as recorded in the audit
+// log, or nil if it doesn't exist.
+func (s *Server) subscriptionSnapshot(id int64) any {
+	subs, err := s.DB.ListSubscriptions(0)
+	if err != nil {
+		return nil
+	}
+	for _, sub := range subs {
+		if sub.ID == id {
+			return toAuditSubscription(sub)
+		}
+	}
+	return nil
+}
+
+func redirectSubscriptionsError(w http.ResponseWriter, r *http.Request, msg string) {
+	http.Redirect(w, r, "/subscriptions?error="+url.QueryEscape(msg), http.StatusSeeOther)
+}
@@ -0,0 +1,179 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certReloadInterval is how often certificate files are checked for
+// changes.
+const certReloadInterval = 30 * time.Second
+
+// certReloader serves a certificate from files, reloading it when either
+// file changes so renewed certificates are picked up without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// reload loads the files if they changed since the last load, and reports
+// whether it did.
+func (c *certReloader) reload() (bool, error) {
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return false, err
+	}
+	c.mu.RLock()
+	unchanged := modTime.Equal(c.modTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	c.cert = &cert
+	c.modTime = modTime
+	c.mu.Unlock()
+	return true, nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// watchCertificate reloads the certificate as its files change until ctx
+// is done. A certificate that fails to load is logged and the previous one
+// kept.
+func (s *Server) watchCertificate(ctx context.Context, c *certReloader) {
+	ticker := time.NewTicker(certReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := c.reload()
+		if err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed reload dashboard certificate")
+		} else if reloaded {
+			s.Logger.Info().Str("cert", c.certFile).Msg("dashboard certificate reloaded")
+		}
+	}
+}
+
+// tlsConfig returns the TLS configuration for the dashboard, and the
+// handler for the plain HTTP port, or nil if TLS is off. With ACME, the
+// plain HTTP handler also answers HTTP-01 challenges.
+func (s *Server) tlsConfig(ctx context.Context) (*tls.Config, http.Handler, error) {
+	cfg := s.Config.TLS
+	if !cfg.Enabled() {
+		return nil, nil, nil
+	}
+
+	if cfg.UsesFiles() {
+		certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		go s.watchCertificate(ctx, certs)
+		tlsCfg := &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+		}
+		return tlsCfg, http.HandlerFunc(s.redirectToHTTPS), nil
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.ACME.GetCacheDir()),
+		HostPolicy: autocert.HostWhitelist(cfg.ACME.Domains...),
+		Email:      cfg.ACME.Email,
+	}
+	if cfg.ACME.DirectoryURL != "" {
+		m.Client = &acme.Client{DirectoryURL: cfg.ACME.DirectoryURL}
+	}
+	tlsCfg := m.TLSConfig()
+	tlsCfg.MinVersion = tls.VersionTLS12
+	return tlsCfg, m.HTTPHandler(http.HandlerFunc(s.redirectToHTTPS)), nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL on the
+// dashboard's HTTPS port.
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if port := s.Config.GetPort(); port != 443 {
+		host = net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// serveRedirect runs the plain HTTP listener on the redirect port until ctx
+// is done.
+func (s *Server) serveRedirect(ctx context.Context, handler http.Handler) {
+	port := s.Config.TLS.RedirectPort
+	srv := &http.Server{
+		Addr:        net.JoinHostPort(s.Config.Bind, strconv.Itoa(port)),
+		Handler:     handler,
+		ReadTimeout: 10 * time.Second,
+		IdleTimeout: 60 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	s.Logger.Info().Int("port", port).Msg("dashboard HTTPS redirect started")
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		s.Logger.Error().Str("reason", err.Error()).Msg("dashboard HTTPS redirect error")
+	}
+}
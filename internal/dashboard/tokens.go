@@ -0,0 +1,202 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+// apiTokenPrefix marks API tokens so they are recognisable in config files
+// and secret scanners.
+const apiTokenPrefix = "ytb_"
+
+// apiScopes lists every scope a token can be granted. A write scope also
+// grants the matching read scope.
+var apiScopes = []string{
+	"downloads:read", "downloads:write",
+	"logs:read",
+	"stats:read",
+	"access:read", "access:write",
+	"filters:read", "filters:write",
+	"cache:read", "cache:write",
+	"jobs:read", "jobs:write",
+}
+
+// tokenCtxKey stores the API token a request authenticated with.
+type tokenCtxKey struct{}
+
+// auditAPIToken is an API token as recorded in the audit log. The token
+// itself is never recorded.
+type auditAPIToken struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// tokenExpiries are the lifetimes offered when creating a token. Zero means
+// the token never expires.
+var tokenExpiries = []struct {
+	Label string
+	Days  int
+}{
+	{"30 days", 30},
+	{"90 days", 90},
+	{"1 year", 365},
+	{"Never", 0},
+}
+
+// hasScope reports whether scopes grant scope, directly or through the
+// matching write scope.
+func hasScope(scopes []string, scope string) bool {
+	if slices.Contains(scopes, scope) {
+		return true
+	}
+	resource, access, _ := strings.Cut(scope, ":")
+	return access == "read" && slices.Contains(scopes, resource+":write")
+}
+
+// authenticateAPIToken resolves a bearer token to its database record and
+// records its use. Expired and unknown tokens are both reported as not found.
+func (s *Server) authenticateAPIToken(token string) (database.APIToken, bool, error) {
+	t, found, err := s.lookupAPIToken(token)
+	if err != nil || !found {
+		return database.APIToken{}, false, err
+	}
+	if err := s.DB.TouchAPIToken(t.ID); err != nil {
+		s.Logger.Warn().Str("reason", err.Error()).Msg("failed record API token use")
+	}
+	return t, true, nil
+}
+
+// lookupAPIToken is authenticateAPIToken without recording the use.
+func (s *Server) lookupAPIToken(token string) (database.APIToken, bool, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return database.APIToken{}, false, nil
+	}
+	t, found, err := s.DB.FindAPITokenByHash(hashToken(token))
+	if err != nil || !found || t.Expired() {
+		return database.APIToken{}, false, err
+	}
+	return t, true, nil
+}
+
+func (s *Server) tokensPage(w http.ResponseWriter, r *http.Request) {
+	s.renderTokens(w, r, "", r.URL.Query().Get("error"))
+}
+
+// renderTokens renders the tokens page. newToken is set only right after
+// creation, the one time the plaintext token is shown.
+func (s *Server) renderTokens(w http.ResponseWriter, r *http.Request, newToken, errMsg string) {
+	tokens, err := s.DB.ListAPITokens()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list API tokens")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tmplMap["tokens.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Tokens":   tokens,
+		"Scopes":   apiScopes,
+		"Expiries": tokenExpiries,
+		"NewToken": newToken,
+		"Error":    errMsg,
+	})
+}
+
+func (s *Server) createTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		s.renderTokens(w, r, "", "Name is required")
+		return
+	}
+
+	scopes := r.Form["scopes"]
+	if len(scopes) == 0 {
+		s.renderTokens(w, r, "", "Select at least one scope")
+		return
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(apiScopes, scope) {
+			s.renderTokens(w, r, "", "Unknown scope: "+scope)
+			return
+		}
+	}
+
+	days, err := strconv.Atoi(r.FormValue("expires_days"))
+	if err != nil || days < 0 {
+		s.renderTokens(w, r, "", "Invalid expiry")
+		return
+	}
+	var expiresAt time.Time
+	if days > 0 {
+		expiresAt = time.Now().AddDate(0, 0, days)
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	token := apiTokenPrefix + secret
+
+	id, err := s.DB.InsertAPIToken(name, hashToken(token), token[:len(apiTokenPrefix)+8], scopes, expiresAt)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed insert API token")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "api_token.create", fmt.Sprintf("api_token:%d", id), nil, auditAPIToken{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+
+	s.Logger.Info().
+		Str("name", name).
+		Strs("scopes", scopes).
+		Msg("API token created from dashboard")
+
+	// Rendered directly rather than redirected so the token never ends up in
+	// a URL or browser history.
+	s.renderTokens(w, r, token, "")
+}
+
+func (s *Server) deleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id == 0 {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	var before any
+	if tokens, err := s.DB.ListAPITokens(); err == nil {
+		for _, t := range tokens {
+			if t.ID == id {
+				before = auditAPIToken{ID: t.ID, Name: t.Name, Scopes: t.Scopes, ExpiresAt: t.ExpiresAt}
+			}
+		}
+	}
+	if err := s.DB.DeleteAPIToken(id); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete API token")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "api_token.delete", fmt.Sprintf("api_token:%d", id), before, nil)
+
+	s.Logger.Info().Int64("token_id", id).Msg("API token revoked from dashboard")
+	http.Redirect(w, r, "/tokens", http.StatusSeeOther)
+}
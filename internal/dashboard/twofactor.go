@@ -0,0 +1,339 @@
+package dashboard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/totp"
+	"golang.org/x/crypto/bcrypt"
+	"rsc.io/qr"
+)
+
+const (
+	totpIssuer = "YT-DLP Bot"
+
+	challengeCookieName = "login_challenge"
+	// challengeDuration is how long the second login step may take.
+	challengeDuration = 5 * time.Minute
+	// challengeMaxAttempts is how many wrong codes end a challenge.
+	challengeMaxAttempts = 5
+
+	// ssoTOTPMessage refuses two-factor settings to accounts that don't sign
+	// in with a password.
+	ssoTOTPMessage = "Two-factor authentication is managed by your single sign-on provider"
+
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused.
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// loginChallenge is a password-verified login waiting for its one-time code.
+type loginChallenge struct {
+	userID   int64
+	expires  time.Time
+	attempts int
+}
+
+// challengeStore holds pending second login steps, keyed by the hash of the
+// challenge cookie.
+type challengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]*loginChallenge
+}
+
+func newChallengeStore() *challengeStore {
+	return &challengeStore{challenges: make(map[string]*loginChallenge)}
+}
+
+func (c *challengeStore) add(token string, userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.challenges[hashToken(token)] = &loginChallenge{userID: userID, expires: time.Now().Add(challengeDuration)}
+}
+
+// get returns the unexpired challenge for token.
+func (c *challengeStore) get(token string) (loginChallenge, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.challenges[hashToken(token)]
+	if !ok || time.Now().After(ch.expires) {
+		return loginChallenge{}, false
+	}
+	return *ch, true
+}
+
+// fail counts a wrong code and reports whether the challenge is still open.
+func (c *challengeStore) fail(token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.challenges[hashToken(token)]
+	if !ok {
+		return false
+	}
+	ch.attempts++
+	if ch.attempts >= challengeMaxAttempts {
+		delete(c.challenges, hashToken(token))
+		return false
+	}
+	return true
+}
+
+func (c *challengeStore) remove(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.challenges, hashToken(token))
+}
+
+// prune drops expired challenges.
+func (c *challengeStore) prune() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, ch := range c.challenges {
+		if time.Now().After(ch.expires) {
+			delete(c.challenges, key)
+		}
+	}
+}
+
+// startChallenge asks a user whose password checked out for their one-time
+// code.
+func (s *Server) startChallenge(w http.ResponseWriter, r *http.Request, user database.DashboardUser) {
+	token, err := generateToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.challenges.add(token, user.ID)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     challengeCookieName,
+		Value:    token,
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   s.isTLS(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(challengeDuration.Seconds()),
+	})
+	renderLogin(w, http.StatusOK, map[string]any{"TOTP": true})
+}
+
+func (s *Server) loginTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(challengeCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	challenge, ok := s.challenges.get(cookie.Value)
+	if !ok {
+		renderLogin(w, http.StatusUnauthorized, map[string]any{"Error": "Login timed out, sign in again"})
+		return
+	}
+	user, found, err := s.DB.FindDashboardUser(challenge.userID)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		s.challenges.remove(cookie.Value)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	ip := s.clientIP(r)
+	keys := loginKeys(ip, user.Username)
+	if wait := s.logins.lockout(keys...); wait > 0 {
+		s.Logger.Warn().Str("username", user.Username).Str("ip", ip).Msg("dashboard login blocked by rate limit")
+		renderLogin(w, http.StatusTooManyRequests, map[string]any{"TOTP": true, "Error": lockoutMessage(wait)})
+		return
+	}
+
+	ok, err = s.verifySecondFactor(user, r.FormValue("code"))
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed verify one-time code")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		s.logins.fail(keys...)
+		s.Logger.Warn().Str("username", user.Username).Str("ip", ip).Msg("dashboard login failed: invalid one-time code")
+		s.auditAs(r, user.Username, "login.failed", fmt.Sprintf("account:%d", user.ID), nil, nil)
+		if !s.challenges.fail(cookie.Value) {
+			renderLogin(w, http.StatusUnauthorized, map[string]any{"Error": "Too many invalid codes, sign in again"})
+			return
+		}
+		renderLogin(w, http.StatusUnauthorized, map[string]any{"TOTP": true, "Error": "Invalid code"})
+		return
+	}
+
+	s.challenges.remove(cookie.Value)
+	http.SetCookie(w, &http.Cookie{Name: challengeCookieName, Value: "", Path: "/login", MaxAge: -1})
+	s.logins.reset(keys...)
+	s.startSession(w, r, user)
+}
+
+// verifySecondFactor checks a code from the user's authenticator app, or
+// one of their recovery codes, which is used up.
+func (s *Server) verifySecondFactor(user database.DashboardUser, code string) (bool, error) {
+	if counter, ok := totp.Validate(user.TOTPSecret, code, time.Now()); ok {
+		// Each code works once, even within its time window.
+		return s.DB.UseTOTPCounter(user.ID, counter)
+	}
+
+	used, err := s.DB.UseRecoveryCode(user.ID, hashToken(normalizeRecoveryCode(code)))
+	if used {
+		s.Logger.Warn().Str("username", user.Username).Msg("dashboard recovery code used")
+	}
+	return used, err
+}
+
+// generateRecoveryCodes returns new recovery codes and the hashes to store.
+func generateRecoveryCodes() (codes, hashes []string, err error) {
+	for range recoveryCodeCount {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		for i := range b {
+			b[i] = recoveryCodeAlphabet[int(b[i])%len(recoveryCodeAlphabet)]
+		}
+		code := string(b[:4]) + "-" + string(b[4:])
+		codes = append(codes, code)
+		hashes = append(hashes, hashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code))
+}
+
+// qrDataURL renders text as a QR code PNG embedded in a data URL.
+func qrDataURL(text string) (template.URL, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return "", err
+	}
+	code.Scale = 5
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG())), nil
+}
+
+// setupTOTPHandler shows a new secret to add to an authenticator app. It is
+// only stored once enableTOTPHandler confirms a code generated from it.
+func (s *Server) setupTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	if !s.passwordSignIn(user) {
+		redirectAccountError(w, r, ssoTOTPMessage)
+		return
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.renderTOTPSetup(w, r, user, secret, "")
+}
+
+func (s *Server) renderTOTPSetup(w http.ResponseWriter, r *http.Request, user database.DashboardUser, secret, errMsg string) {
+	qrCode, err := qrDataURL(totp.URI(totpIssuer, user.Username, secret))
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed render QR code")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	s.renderAccount(w, r, map[string]any{
+		"Setup": map[string]any{"Secret": secret, "QR": qrCode},
+		"Error": errMsg,
+	})
+}
+
+func (s *Server) enableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	if !s.passwordSignIn(user) {
+		redirectAccountError(w, r, ssoTOTPMessage)
+		return
+	}
+	secret := r.FormValue("secret")
+
+	counter, ok := totp.Validate(secret, r.FormValue("code"), time.Now())
+	if !ok {
+		s.renderTOTPSetup(w, r, user, secret, "That code didn't match, check your device's clock and try again")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.EnableTOTP(user.ID, secret, counter, hashes); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed enable two-factor authentication")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "account.totp_enable", fmt.Sprintf("account:%d", user.ID), nil, nil)
+	s.Logger.Info().Str("username", user.Username).Msg("dashboard two-factor authentication enabled")
+	s.renderAccount(w, r, map[string]any{"RecoveryCodes": codes})
+}
+
+func (s *Server) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	if !s.passwordSignIn(user) {
+		redirectAccountError(w, r, ssoTOTPMessage)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("password"))) != nil {
+		redirectAccountError(w, r, "Password is incorrect")
+		return
+	}
+
+	if err := s.DB.DisableTOTP(user.ID); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed disable two-factor authentication")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "account.totp_disable", fmt.Sprintf("account:%d", user.ID), nil, nil)
+	s.Logger.Info().Str("username", user.Username).Msg("dashboard two-factor authentication disabled")
+	http.Redirect(w, r, "/account?notice=totp-disabled", http.StatusSeeOther)
+}
+
+func (s *Server) regenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	if !s.passwordSignIn(user) {
+		redirectAccountError(w, r, ssoTOTPMessage)
+		return
+	}
+	if !user.TOTPEnabled() {
+		redirectAccountError(w, r, "Two-factor authentication is not enabled")
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("password"))) != nil {
+		redirectAccountError(w, r, "Password is incorrect")
+		return
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed replace recovery codes")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	s.audit(r, "account.recovery_codes", fmt.Sprintf("account:%d", user.ID), nil, nil)
+	s.Logger.Info().Str("username", user.Username).Msg("dashboard recovery codes regenerated")
+	s.renderAccount(w, r, map[string]any{"RecoveryCodes": codes})
+}
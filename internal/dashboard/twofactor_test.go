@@ -0,0 +1,85 @@
+package dashboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/baranovskis/go-ytdlp-bot/internal/totp"
+)
+
+func TestTOTPRefusedWithoutPassword(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := totp.Code(secret, totp.Counter(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []string{authModeLocal, authModeOIDC} {
+		s := newSSOTestServer(t, config.Auth{Mode: mode, DefaultRole: roleViewer})
+		id, _, err := s.DB.InsertSSODashboardUser("alice", roleViewer, "https://idp.example.com", "alice-sub")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handlers := map[string]http.HandlerFunc{
+			"setup":    s.setupTOTPHandler,
+			"enable":   s.enableTOTPHandler,
+			"disable":  s.disableTOTPHandler,
+			"recovery": s.regenerateRecoveryCodesHandler,
+		}
+		for name, handler := range handlers {
+			user, _, err := s.DB.FindDashboardUser(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			form := url.Values{"secret": {secret}, "code": {code}, "password": {""}}
+			r := httptest.NewRequest("POST", "/account/totp/"+name, strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, user))
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != http.StatusSeeOther || !strings.HasPrefix(w.Header().Get("Location"), "/account?error=") {
+				t.Errorf("%s mode, %s: status %d, location %q, want a redirect with an error", mode, name, w.Code, w.Header().Get("Location"))
+			}
+		}
+
+		user, _, err := s.DB.FindDashboardUser(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.TOTPEnabled() {
+			t.Errorf("%s mode: two-factor authentication enabled for a single sign-on account", mode)
+		}
+	}
+}
+
+func TestPasswordSignIn(t *testing.T) {
+	tests := []struct {
+		mode string
+		user database.DashboardUser
+		want bool
+	}{
+		{authModeLocal, database.DashboardUser{PasswordHash: "hash"}, true},
+		{authModeLocal, database.DashboardUser{}, false},
+		{authModeProxy, database.DashboardUser{PasswordHash: "hash"}, false},
+		{authModeOIDC, database.DashboardUser{}, false},
+	}
+
+	for _, tt := range tests {
+		s := &Server{Config: config.Dashboard{Auth: config.Auth{Mode: tt.mode}}}
+		if got := s.passwordSignIn(tt.user); got != tt.want {
+			t.Errorf("passwordSignIn() in %s mode with hash %q = %v, want %v", tt.mode, tt.user.PasswordHash, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package dashboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+)
+
+// auditAccount is a dashboard account as recorded in the audit log. Password
+// hashes and TOTP secrets are never recorded.
+type auditAccount struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	TOTP     bool   `json:"totp"`
+	// PasswordReset marks an update that set a new password.
+	PasswordReset bool `json:"password_reset,omitempty"`
+}
+
+func toAuditAccount(u database.DashboardUser) auditAccount {
+	return auditAccount{ID: u.ID, Username: u.Username, Role: u.Role, TOTP: u.TOTPEnabled()}
+}
+
+// accountSnapshot returns the account as recorded in the audit log, or nil
+// if it doesn't exist.
+func (s *Server) accountSnapshot(id int64) any {
+	u, found, err := s.DB.FindDashboardUser(id)
+	if err != nil || !found {
+		return nil
+	}
+	return toAuditAccount(u)
+}
+
+func (s *Server) usersPage(w http.ResponseWriter, r *http.Request) {
+	users, err := s.DB.ListDashboardUsers()
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed list dashboard users")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tmplMap["users.html"].ExecuteTemplate(w, "layout", map[string]any{
+		"Users":         users,
+		"Roles":         roles,
+		"CurrentUserID": userFrom(r.Context()).ID,
+		"Error":         r.URL.Query().Get("error"),
+	})
+}
+
+func (s *Server) addUserHandler(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	role := r.FormValue("role")
+
+	if username == "" {
+		redirectUsersError(w, r, "Username is required")
+		return
+	}
+	if msg := validatePassword(password); msg != "" {
+		redirectUsersError(w, r, msg)
+		return
+	}
+	if !slices.Contains(roles, role) {
+		redirectUsersError(w, r, "Invalid role")
+		return
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed hash password")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	id, created, err := s.DB.InsertDashboardUser(username, hash, role)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed insert dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !created {
+		redirectUsersError(w, r, "That username is already taken")
+		return
+	}
+
+	s.audit(r, "account.create", fmt.Sprintf("account:%d", id), nil, s.accountSnapshot(id))
+	s.Logger.Info().
+		Str("username", username).
+		Str("role", role).
+		Str("by", userFrom(r.Context()).Username).
+		Msg("dashboard account created")
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+// updateUserHandler changes an account's role and, if a new password is
+// given, resets it and signs the account's other sessions out.
+func (s *Server) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id == 0 {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	user, found, err := s.DB.FindDashboardUser(id)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Account not found", http.StatusNotFound)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !slices.Contains(roles, role) {
+		redirectUsersError(w, r, "Invalid role")
+		return
+	}
+	password := r.FormValue("password")
+	if password != "" {
+		if msg := validatePassword(password); msg != "" {
+			redirectUsersError(w, r, msg)
+			return
+		}
+	}
+
+	if role != user.Role {
+		if user.Role == roleAdmin {
+			if !s.checkNotLastAdmin(w, r) {
+				return
+			}
+		}
+		if err := s.DB.UpdateDashboardUserRole(id, role); err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed update dashboard user")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed hash password")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := s.DB.UpdateDashboardUserPassword(id, hash); err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed update dashboard user")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := s.DB.DeleteUserSessions(id, sessionToken(r)); err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed delete sessions")
+		}
+	}
+
+	resetTOTP := r.FormValue("reset_totp") != "" && user.TOTPEnabled()
+	if resetTOTP {
+		if err := s.DB.DisableTOTP(id); err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed disable two-factor authentication")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	after := toAuditAccount(user)
+	after.Role = role
+	after.TOTP = after.TOTP && !resetTOTP
+	after.PasswordReset = password != ""
+	s.audit(r, "account.update", fmt.Sprintf("account:%d", id), toAuditAccount(user), after)
+	s.Logger.Info().
+		Str("username", user.Username).
+		Str("role", role).
+		Bool("password_reset", password != "").
+		Bool("totp_reset", resetTOTP).
+		Str("by", userFrom(r.Context()).Username).
+		Msg("dashboard account updated")
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+func (s *Server) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.FormValue("id"), 10, 64)
+	if id == 0 {
+		http.Error(w, "Invalid account ID", http.StatusBadRequest)
+		return
+	}
+	if id == userFrom(r.Context()).ID {
+		redirectUsersError(w, r, "You can't delete your own account")
+		return
+	}
+
+	user, found, err := s.DB.FindDashboardUser(id)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed load dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Redirect(w, r, "/users", http.StatusSeeOther)
+		return
+	}
+	if user.Role == roleAdmin {
+		if !s.checkNotLastAdmin(w, r) {
+			return
+		}
+	}
+
+	if err := s.DB.DeleteDashboardUser(id); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.DeleteUserSessions(id, ""); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete sessions")
+	}
+
+	s.audit(r, "account.delete", fmt.Sprintf("account:%d", id), toAuditAccount(user), nil)
+	s.Logger.Info().
+		Str("username", user.Username).
+		Str("by", userFrom(r.Context()).Username).
+		Msg("dashboard account deleted")
+
+	http.Redirect(w, r, "/users", http.StatusSeeOther)
+}
+
+// checkNotLastAdmin redirects with an error if there is only one admin left,
+// so the dashboard can't be locked out of user management.
+func (s *Server) checkNotLastAdmin(w http.ResponseWriter, r *http.Request) bool {
+	admins, err := s.DB.CountDashboardUsers(roleAdmin)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed count dashboard admins")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if admins <= 1 {
+		redirectUsersError(w, r, "At least one admin account must remain")
+		return false
+	}
+	return true
+}
+
+// accountNotices are the confirmations the account page shows after a
+// redirect, keyed by the notice query parameter.
+var accountNotices = map[string]string{
+	"password":      "Password changed.",
+	"totp-disabled": "Two-factor authentication disabled.",
+}
+
+func (s *Server) accountPage(w http.ResponseWriter, r *http.Request) {
+	s.renderAccount(w, r, map[string]any{
+		"Notice": accountNotices[r.URL.Query().Get("notice")],
+		"Error":  r.URL.Query().Get("error"),
+	})
+}
+
+// renderAccount renders the account page with data added to the signed-in
+// user and their two-factor status.
+func (s *Server) renderAccount(w http.ResponseWriter, r *http.Request, data map[string]any) {
+	user := userFrom(r.Context())
+	data["User"] = user
+	data["LocalAuth"] = s.passwordSignIn(user)
+	if user.TOTPEnabled() {
+		n, err := s.DB.CountRecoveryCodes(user.ID)
+		if err != nil {
+			s.Logger.Error().Str("reason", err.Error()).Msg("failed count recovery codes")
+		}
+		data["RecoveryCodesLeft"] = n
+	}
+	tmplMap["account.html"].ExecuteTemplate(w, "layout", data)
+}
+
+// passwordSignIn reports whether user signs in with a password. Only such
+// accounts have a password to change, and two-factor authentication only
+// guards password sign-ins; single sign-on accounts leave both to their
+// provider.
+func (s *Server) passwordSignIn(user database.DashboardUser) bool {
+	return s.Config.Auth.GetMode() == authModeLocal && user.PasswordHash != ""
+}
+
+func (s *Server) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user := userFrom(r.Context())
+	current := r.FormValue("current_password")
+	password := r.FormValue("new_password")
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		redirectAccountError(w, r, "Current password is incorrect")
+		return
+	}
+	if password != r.FormValue("confirm_password") {
+		redirectAccountError(w, r, "New passwords don't match")
+		return
+	}
+	if msg := validatePassword(password); msg != "" {
+		redirectAccountError(w, r, msg)
+		return
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed hash password")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.DB.UpdateDashboardUserPassword(user.ID, hash); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed update dashboard user")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.DB.DeleteUserSessions(user.ID, sessionToken(r)); err != nil {
+		s.Logger.Error().Str("reason", err.Error()).Msg("failed delete sessions")
+	}
+
+	s.audit(r, "account.password_change", fmt.Sprintf("account:%d", user.ID), nil, nil)
+	s.Logger.Info().Str("username", user.Username).Msg("dashboard password changed")
+	http.Redirect(w, r, "/account?notice=password", http.StatusSeeOther)
+}
+
+func redirectUsersError(w http.ResponseWriter, r *http.Request, msg string) {
+	http.Redirect(w, r, "/users?error="+url.QueryEscape(msg), http.StatusSeeOther)
+}
+
+func redirectAccountError(w http.ResponseWriter, r *http.Request, msg string) {
+	http.Redirect(w, r, "/account?error="+url.QueryEscape(msg), http.StatusSeeOther)
+}
@@ -1,6 +1,10 @@
 package database
 
-import "time"
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
 
 type AllowedGroup struct {
 	ChatID  int64
@@ -39,7 +43,7 @@ func (db *DB) RejectPendingGroup(chatID int64) error {
 }
 
 func (db *DB) ListPendingGroups() ([]AllowedGroup, error) {
-	return db.listGroupsByStatus("pending")
+	return db.ListGroupsByStatus("pending")
 }
 
 // Allowed groups
@@ -58,7 +62,7 @@ func (db *DB) RemoveAllowedGroup(chatID int64) error {
 }
 
 func (db *DB) ListAllowedGroups() ([]AllowedGroup, error) {
-	return db.listGroupsByStatus("approved")
+	return db.ListGroupsByStatus("approved")
 }
 
 func (db *DB) IsGroupAllowed(chatID int64) (bool, error) {
@@ -67,7 +71,23 @@ func (db *DB) IsGroupAllowed(chatID int64) (bool, error) {
 	return count > 0, err
 }
 
-func (db *DB) listGroupsByStatus(status string) ([]AllowedGroup, error) {
+// FindGroup returns the group with the given chat ID, whatever its status.
+func (db *DB) FindGroup(chatID int64) (AllowedGroup, bool, error) {
+	var g AllowedGroup
+	err := db.QueryRow(`SELECT chat_id, title, status, added_at FROM allowed_groups WHERE chat_id = ?`, chatID).
+		Scan(&g.ChatID, &g.Title, &g.Status, &g.AddedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AllowedGroup{}, false, nil
+	}
+	if err != nil {
+		return AllowedGroup{}, false, err
+	}
+	return g, true, nil
+}
+
+// ListGroupsByStatus returns the groups with the given status: pending,
+// approved or rejected.
+func (db *DB) ListGroupsByStatus(status string) ([]AllowedGroup, error) {
 	rows, err := db.Query(`SELECT chat_id, title, status, added_at FROM allowed_groups WHERE status = ? ORDER BY added_at DESC`, status)
 	if err != nil {
 		return nil, err
@@ -107,7 +127,7 @@ func (db *DB) RejectPendingUser(userID int64) error {
 }
 
 func (db *DB) ListPendingUsers() ([]AllowedUser, error) {
-	return db.listUsersByStatus("pending")
+	return db.ListUsersByStatus("pending")
 }
 
 // Allowed users
@@ -118,7 +138,7 @@ func (db *DB) RemoveAllowedUser(userID int64) error {
 }
 
 func (db *DB) ListAllowedUsers() ([]AllowedUser, error) {
-	return db.listUsersByStatus("approved")
+	return db.ListUsersByStatus("approved")
 }
 
 func (db *DB) IsUserAllowed(userID int64) (bool, error) {
@@ -127,7 +147,23 @@ func (db *DB) IsUserAllowed(userID int64) (bool, error) {
 	return count > 0, err
 }
 
-func (db *DB) listUsersByStatus(status string) ([]AllowedUser, error) {
+// FindUser returns the user with the given Telegram ID, whatever their status.
+func (db *DB) FindUser(userID int64) (AllowedUser, bool, error) {
+	var u AllowedUser
+	err := db.QueryRow(`SELECT user_id, username, status, added_at FROM allowed_users WHERE user_id = ?`, userID).
+		Scan(&u.UserID, &u.Username, &u.Status, &u.AddedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return AllowedUser{}, false, nil
+	}
+	if err != nil {
+		return AllowedUser{}, false, err
+	}
+	return u, true, nil
+}
+
+// ListUsersByStatus returns the users with the given status: pending,
+// approved or rejected.
+func (db *DB) ListUsersByStatus(status string) ([]AllowedUser, error) {
 	rows, err := db.Query(`SELECT user_id, username, status, added_at FROM allowed_users WHERE status = ? ORDER BY added_at DESC`, status)
 	if err != nil {
 		return nil, err
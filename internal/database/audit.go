@@ -0,0 +1,122 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit log sources: the dashboard, the JSON API and Telegram commands.
+const (
+	AuditSourceDashboard = "dashboard"
+	AuditSourceAPI       = "api"
+	AuditSourceTelegram  = "telegram"
+)
+
+// AuditEntry records who did what to which object. Before and After hold
+// JSON snapshots of the object, empty when it didn't exist.
+type AuditEntry struct {
+	ID        int64
+	Source    string
+	Actor     string
+	Action    string
+	Target    string
+	Before    string
+	After     string
+	IP        string
+	CreatedAt time.Time
+}
+
+type AuditFilter struct {
+	Source string
+	Action string
+	Search string
+	Limit  int // -1 for no limit
+	Offset int
+}
+
+// AuditJSON returns v as a JSON snapshot for AuditEntry.Before or After,
+// or "" for nil.
+func AuditJSON(v any) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func (db *DB) InsertAuditEntry(e AuditEntry) error {
+	_, err := db.Exec(
+		`INSERT INTO audit_log (source, actor, action, target, before, after, ip) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.Source, e.Actor, e.Action, e.Target, e.Before, e.After, e.IP,
+	)
+	return err
+}
+
+func (db *DB) ListAuditEntries(f AuditFilter) ([]AuditEntry, int, error) {
+	if f.Limit == 0 {
+		f.Limit = 100
+	}
+
+	where := " WHERE 1=1"
+	var args []any
+
+	if f.Source != "" {
+		where += " AND source = ?"
+		args = append(args, f.Source)
+	}
+	if f.Action != "" {
+		where += " AND action = ?"
+		args = append(args, f.Action)
+	}
+	if f.Search != "" {
+		where += " AND (actor LIKE ? OR target LIKE ? OR before LIKE ? OR after LIKE ? OR ip LIKE ?)"
+		s := "%" + f.Search + "%"
+		args = append(args, s, s, s, s, s)
+	}
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.Query(
+		"SELECT id, source, actor, action, target, before, after, ip, created_at FROM audit_log"+where+" ORDER BY id DESC LIMIT ? OFFSET ?",
+		append(args, f.Limit, f.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Source, &e.Actor, &e.Action, &e.Target, &e.Before, &e.After, &e.IP, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// ListAuditActions returns the distinct actions in the audit log.
+func (db *DB) ListAuditActions() ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT action FROM audit_log ORDER BY action")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type CookieJar struct {
+	Site          string
+	Path          string
+	Domains       []string
+	CookieCount   int
+	ExpiresAt     time.Time
+	LoginFailures int
+	LastFailureAt time.Time
+	UploadedAt    time.Time
+}
+
+// UpsertCookieJar records a freshly uploaded cookie file and resets its failure counter.
+func (db *DB) UpsertCookieJar(site, path string, domains []string, cookieCount int, expiresAt time.Time) error {
+	var exp any
+	if !expiresAt.IsZero() {
+		exp = expiresAt.UTC()
+	}
+	_, err := db.Exec(
+		`INSERT INTO cookie_jars (site, path, domains, cookie_count, expires_at, login_failures, last_failure_at, uploaded_at)
+		 VALUES (?, ?, ?, ?, ?, 0, NULL, datetime('now'))
+		 ON CONFLICT(site) DO UPDATE SET path = excluded.path, domains = excluded.domains,
+		 cookie_count = excluded.cookie_count, expires_at = excluded.expires_at,
+		 login_failures = 0, last_failure_at = NULL, uploaded_at = excluded.uploaded_at`,
+		site, path, strings.Join(domains, "\n"), cookieCount, exp,
+	)
+	return err
+}
+
+func (db *DB) DeleteCookieJar(site string) error {
+	_, err := db.Exec(`DELETE FROM cookie_jars WHERE site = ?`, site)
+	return err
+}
+
+// RecordCookieLoginFailure bumps the failure counter of the jar stored at path.
+func (db *DB) RecordCookieLoginFailure(path string) error {
+	_, err := db.Exec(
+		`UPDATE cookie_jars SET login_failures = login_failures + 1, last_failure_at = ? WHERE path = ?`,
+		time.Now().UTC(), path,
+	)
+	return err
+}
+
+func (db *DB) ListCookieJars() ([]CookieJar, error) {
+	rows, err := db.Query(`SELECT site, path, domains, cookie_count, expires_at, login_failures, last_failure_at, uploaded_at
+		FROM cookie_jars ORDER BY site`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jars []CookieJar
+	for rows.Next() {
+		var j CookieJar
+		var domains string
+		var expiresAt, lastFailureAt sql.NullTime
+		if err := rows.Scan(&j.Site, &j.Path, &domains, &j.CookieCount, &expiresAt, &j.LoginFailures, &lastFailureAt, &j.UploadedAt); err != nil {
+			return nil, err
+		}
+		j.Domains = splitLines(domains)
+		j.ExpiresAt = expiresAt.Time
+		j.LastFailureAt = lastFailureAt.Time
+		jars = append(jars, j)
+	}
+	return jars, rows.Err()
+}
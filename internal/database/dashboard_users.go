@@ -0,0 +1,238 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DashboardUser is an account that can sign in to the dashboard.
+type DashboardUser struct {
+	ID           int64
+	Username     string
+	PasswordHash string
+	Role         string
+	// TOTPSecret is empty unless two-factor authentication is enabled.
+	TOTPSecret string
+	// TOTPLastCounter is the time step of the last accepted code, so a code
+	// can't be used twice.
+	TOTPLastCounter int64
+	// SSOIssuer and SSOSubject identify accounts created by single sign-on
+	// at the proxy or identity provider. Both are empty for local accounts.
+	SSOIssuer  string
+	SSOSubject string
+	CreatedAt  time.Time
+}
+
+// TOTPEnabled reports whether the account requires a one-time code to sign
+// in.
+func (u DashboardUser) TOTPEnabled() bool {
+	return u.TOTPSecret != ""
+}
+
+// InsertDashboardUser creates an account. It returns false if the username
+// is already taken.
+func (db *DB) InsertDashboardUser(username, passwordHash, role string) (int64, bool, error) {
+	result, err := db.Exec(
+		`INSERT INTO dashboard_users (username, password_hash, role) VALUES (?, ?, ?) ON CONFLICT (username) DO NOTHING`,
+		username, passwordHash, role,
+	)
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return 0, false, err
+	}
+	id, err := result.LastInsertId()
+	return id, true, err
+}
+
+// InsertSSODashboardUser creates an account without a password for someone
+// signed in by the proxy or identity provider. It returns false if the
+// username is already taken.
+func (db *DB) InsertSSODashboardUser(username, role, issuer, subject string) (int64, bool, error) {
+	result, err := db.Exec(
+		`INSERT INTO dashboard_users (username, password_hash, role, sso_issuer, sso_subject) VALUES (?, '', ?, ?, ?) ON CONFLICT (username) DO NOTHING`,
+		username, role, issuer, subject,
+	)
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return 0, false, err
+	}
+	id, err := result.LastInsertId()
+	return id, true, err
+}
+
+// LinkDashboardUserSSO records the single sign-on identity of an account
+// created before identities were stored.
+func (db *DB) LinkDashboardUserSSO(id int64, issuer, subject string) error {
+	_, err := db.Exec(`UPDATE dashboard_users SET sso_issuer = ?, sso_subject = ? WHERE id = ?`, issuer, subject, id)
+	return err
+}
+
+func (db *DB) UpdateDashboardUserPassword(id int64, passwordHash string) error {
+	_, err := db.Exec(`UPDATE dashboard_users SET password_hash = ? WHERE id = ?`, passwordHash, id)
+	return err
+}
+
+func (db *DB) UpdateDashboardUserRole(id int64, role string) error {
+	_, err := db.Exec(`UPDATE dashboard_users SET role = ? WHERE id = ?`, role, id)
+	return err
+}
+
+func (db *DB) DeleteDashboardUser(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM dashboard_users WHERE id = ?`, id); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, id, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// EnableTOTP turns on two-factor authentication for an account, replacing
+// any recovery codes it had. An empty secret turns it off.
+func (db *DB) EnableTOTP(id int64, secret string, counter int64, recoveryCodeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE dashboard_users SET totp_secret = ?, totp_last_counter = ? WHERE id = ?`, secret, counter, id); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, id, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP turns off two-factor authentication and drops the account's
+// recovery codes.
+func (db *DB) DisableTOTP(id int64) error {
+	return db.EnableTOTP(id, "", 0, nil)
+}
+
+// UseTOTPCounter records that the code for time step counter was used. It
+// returns false if that or a later step was already used.
+func (db *DB) UseTOTPCounter(id, counter int64) (bool, error) {
+	result, err := db.Exec(`UPDATE dashboard_users SET totp_last_counter = ? WHERE id = ? AND totp_last_counter < ?`, counter, id, counter)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReplaceRecoveryCodes swaps an account's recovery codes for new ones.
+func (db *DB) ReplaceRecoveryCodes(id int64, codeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, id, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes a recovery code. It returns false if the account
+// has no such code.
+func (db *DB) UseRecoveryCode(id int64, codeHash string) (bool, error) {
+	result, err := db.Exec(`DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?`, id, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes an account has.
+func (db *DB) CountRecoveryCodes(id int64) (int, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ?`, id).Scan(&count)
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, id int64, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, id); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, id, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) ListDashboardUsers() ([]DashboardUser, error) {
+	return db.queryDashboardUsers(`ORDER BY username`)
+}
+
+func (db *DB) FindDashboardUser(id int64) (DashboardUser, bool, error) {
+	return db.findDashboardUser(`WHERE id = ?`, id)
+}
+
+// FindDashboardUserByUsername looks an account up by name, ignoring case.
+func (db *DB) FindDashboardUserByUsername(username string) (DashboardUser, bool, error) {
+	return db.findDashboardUser(`WHERE username = ?`, username)
+}
+
+// FindDashboardUserBySSO looks an account up by its single sign-on
+// identity.
+func (db *DB) FindDashboardUserBySSO(issuer, subject string) (DashboardUser, bool, error) {
+	return db.findDashboardUser(`WHERE sso_issuer = ? AND sso_subject = ?`, issuer, subject)
+}
+
+// CountDashboardUsers returns the number of accounts with role, or of all
+// accounts if role is empty.
+func (db *DB) CountDashboardUsers(role string) (int, error) {
+	var count int
+	var err error
+	if role == "" {
+		err = db.QueryRow(`SELECT COUNT(*) FROM dashboard_users`).Scan(&count)
+	} else {
+		err = db.QueryRow(`SELECT COUNT(*) FROM dashboard_users WHERE role = ?`, role).Scan(&count)
+	}
+	return count, err
+}
+
+func (db *DB) findDashboardUser(where string, args ...any) (DashboardUser, bool, error) {
+	users, err := db.queryDashboardUsers(where, args...)
+	if err != nil || len(users) == 0 {
+		return DashboardUser{}, false, err
+	}
+	return users[0], true, nil
+}
+
+func (db *DB) queryDashboardUsers(where string, args ...any) ([]DashboardUser, error) {
+	rows, err := db.Query(`SELECT id, username, password_hash, role, totp_secret, totp_last_counter, sso_issuer, sso_subject, created_at
+		FROM dashboard_users `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []DashboardUser
+	for rows.Next() {
+		var u DashboardUser
+		if err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.TOTPSecret, &u.TOTPLastCounter, &u.SSOIssuer, &u.SSOSubject, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
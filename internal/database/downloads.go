@@ -1,6 +1,10 @@
 package database
 
-import "time"
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
 
 type Download struct {
 	ID               int64
@@ -12,15 +16,168 @@ type Download struct {
 	Filename         string
 	ErrorMessage     string
 	CreatedAt        time.Time
+
+	Title     string
+	Extractor string
+	Duration  float64 // seconds
+	Format    string  // the yt-dlp format chosen
+	FileSize  int64   // bytes of the file sent
+	CacheKey  string
+	// ErrorKind classifies ErrorMessage, such as "timeout" or "login_required".
+	ErrorKind string
+	// Attempt is 1 for a new download and one more than the download it
+	// retries, RetryOf.
+	Attempt    int
+	RetryOf    int64
+	Timings    DownloadTimings
+	FinishedAt time.Time // zero while pending
+}
+
+// DownloadTimings is the time a download spent in each phase.
+type DownloadTimings struct {
+	Probe      time.Duration
+	Download   time.Duration
+	EncodeWait time.Duration
+	Encode     time.Duration
+	Upload     time.Duration
+}
+
+// Total returns the time spent in all phases.
+func (t DownloadTimings) Total() time.Duration {
+	return t.Probe + t.Download + t.EncodeWait + t.Encode + t.Upload
+}
+
+// DownloadResult is how a download ended.
+type DownloadResult struct {
+	Status       string
+	Filename     string
+	ErrorMessage string
+	ErrorKind    string
+	FileSize     int64
+	Timings      DownloadTimings
 }
 
+const downloadColumns = `id, url, telegram_user_id, telegram_username, chat_id, status, filename, error_message, created_at,
+	title, extractor, duration, format, file_size, cache_key, error_kind, attempt, retry_of,
+	probe_ms, download_ms, encode_wait_ms, encode_ms, upload_ms, finished_at`
+
 type DownloadFilter struct {
-	Status string
-	UserID int64
+	Status    string
+	UserID    int64
+	ChatID    int64
+	Domain    string // host of the URL, including its subdomains
+	Extractor string
+	ErrorKind string
+	Search    string    // substring of the URL or title
+	Since     time.Time // created at or after
+	Until     time.Time // created before
+	// Sort is a key of downloadSorts, ordering by ID when empty. Results are
+	// in descending order unless Asc is set.
+	Sort   string
+	Asc    bool
+	Before int64 // cursor: only downloads after the one with this ID in the sort order
 	Limit  int
 	Offset int
 }
 
+// downloadSorts maps DownloadFilter.Sort values to the columns they order by.
+var downloadSorts = map[string]string{
+	"id":       "id",
+	"url":      "url",
+	"user":     "telegram_username",
+	"chat":     "chat_id",
+	"status":   "status",
+	"filename": "filename",
+	"time":     "created_at",
+	"size":     "file_size",
+	"duration": "duration",
+}
+
+// ValidDownloadSort reports whether sort is a DownloadFilter.Sort value.
+func ValidDownloadSort(sort string) bool {
+	_, ok := downloadSorts[sort]
+	return ok
+}
+
+// where returns the SQL conditions selecting f's downloads, each starting
+// with " AND", and their arguments. It ignores Before, Limit and Offset.
+func (f DownloadFilter) where() (string, []any) {
+	var where string
+	var args []any
+
+	if f.Status != "" {
+		where += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	if f.UserID != 0 {
+		where += " AND telegram_user_id = ?"
+		args = append(args, f.UserID)
+	}
+	if f.ChatID != 0 {
+		where += " AND chat_id = ?"
+		args = append(args, f.ChatID)
+	}
+	if f.Domain != "" {
+		where += ` AND (url LIKE ? ESCAPE '\' OR url LIKE ? ESCAPE '\')`
+		domain := escapeLike(f.Domain)
+		args = append(args, "%://"+domain+"/%", "%://%."+domain+"/%")
+	}
+	if f.Extractor != "" {
+		where += " AND extractor = ?"
+		args = append(args, f.Extractor)
+	}
+	if f.ErrorKind != "" {
+		where += " AND error_kind = ?"
+		args = append(args, f.ErrorKind)
+	}
+	if f.Search != "" {
+		where += ` AND (url LIKE ? ESCAPE '\' OR title LIKE ? ESCAPE '\')`
+		s := "%" + escapeLike(f.Search) + "%"
+		args = append(args, s, s)
+	}
+	if !f.Since.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, f.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !f.Until.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, f.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	return where, args
+}
+
+func (f DownloadFilter) sortColumn() string {
+	if column, ok := downloadSorts[f.Sort]; ok {
+		return column
+	}
+	return "id"
+}
+
+func (f DownloadFilter) orderBy() string {
+	column := f.sortColumn()
+	dir := " DESC"
+	if f.Asc {
+		dir = " ASC"
+	}
+	return " ORDER BY " + column + dir + ", id" + dir
+}
+
+// cursor returns the condition selecting the downloads that come after the
+// one with ID f.Before in f's order, starting with " AND". It compares the
+// sort column first and the ID second, matching orderBy.
+func (f DownloadFilter) cursor() string {
+	op := " < "
+	if f.Asc {
+		op = " > "
+	}
+	column := f.sortColumn()
+	if column == "id" {
+		return " AND id" + op + "?"
+	}
+	return " AND (" + column + ", id)" + op + "(SELECT " + column + ", id FROM downloads WHERE id = ?)"
+}
+
 func (db *DB) InsertDownload(url string, telegramUserID int64, telegramUsername string, chatID int64, status, filename, errorMessage string) (int64, error) {
 	result, err := db.Exec(
 		`INSERT INTO downloads (url, telegram_user_id, telegram_username, chat_id, status, filename, error_message) VALUES (?, ?, ?, ?, ?, ?, ?)`,
@@ -32,42 +189,67 @@ func (db *DB) InsertDownload(url string, telegramUserID int64, telegramUsername
 	return result.LastInsertId()
 }
 
-func (db *DB) UpdateDownloadStatus(id int64, status, filename, errorMessage string) error {
+// RecordDownloadInfo stores what probing the download's URL found, and the
+// cache key its file is kept under.
+func (db *DB) RecordDownloadInfo(id int64, title, extractor string, duration float64, format, cacheKey string) error {
 	_, err := db.Exec(
-		`UPDATE downloads SET status = ?, filename = ?, error_message = ? WHERE id = ?`,
-		status, filename, errorMessage, id,
+		`UPDATE downloads SET title = ?, extractor = ?, duration = ?, format = ?, cache_key = ? WHERE id = ?`,
+		title, extractor, duration, format, cacheKey, id,
 	)
 	return err
 }
 
+// FinishDownload records how a download ended.
+func (db *DB) FinishDownload(id int64, r DownloadResult) error {
+	_, err := db.Exec(
+		`UPDATE downloads SET status = ?, filename = ?, error_message = ?, error_kind = ?, file_size = ?,
+			probe_ms = ?, download_ms = ?, encode_wait_ms = ?, encode_ms = ?, upload_ms = ?, finished_at = ?
+		WHERE id = ?`,
+		r.Status, r.Filename, r.ErrorMessage, r.ErrorKind, r.FileSize,
+		r.Timings.Probe.Milliseconds(), r.Timings.Download.Milliseconds(), r.Timings.EncodeWait.Milliseconds(),
+		r.Timings.Encode.Milliseconds(), r.Timings.Upload.Milliseconds(), time.Now().UTC(), id,
+	)
+	return err
+}
+
+// MarkDownloadRetry records that the download id retries original.
+func (db *DB) MarkDownloadRetry(id int64, original Download) error {
+	_, err := db.Exec(
+		`UPDATE downloads SET retry_of = ?, attempt = ? WHERE id = ?`,
+		original.ID, max(original.Attempt, 1)+1, id,
+	)
+	return err
+}
+
+// CountUserDownloadsSince counts a user's successful and in-progress downloads
+// created after since, for enforcing quotas.
+func (db *DB) CountUserDownloadsSince(telegramUserID int64, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM downloads WHERE telegram_user_id = ? AND status IN ('pending', 'success') AND created_at >= ?`,
+		telegramUserID, since.UTC().Format("2006-01-02 15:04:05"),
+	).Scan(&count)
+	return count, err
+}
+
 func (db *DB) ListDownloads(f DownloadFilter) ([]Download, int, error) {
 	if f.Limit == 0 {
 		f.Limit = 50
 	}
 
-	countQuery := "SELECT COUNT(*) FROM downloads WHERE 1=1"
-	query := "SELECT id, url, telegram_user_id, telegram_username, chat_id, status, filename, error_message, created_at FROM downloads WHERE 1=1"
-	var args []any
-
-	if f.Status != "" {
-		countQuery += " AND status = ?"
-		query += " AND status = ?"
-		args = append(args, f.Status)
-	}
-	if f.UserID != 0 {
-		countQuery += " AND telegram_user_id = ?"
-		query += " AND telegram_user_id = ?"
-		args = append(args, f.UserID)
-	}
+	where, args := f.where()
 
 	var total int
-	countArgs := make([]any, len(args))
-	copy(countArgs, args)
-	if err := db.QueryRow(countQuery, countArgs...).Scan(&total); err != nil {
+	if err := db.QueryRow("SELECT COUNT(*) FROM downloads WHERE 1=1"+where, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	query := "SELECT " + downloadColumns + " FROM downloads WHERE 1=1" + where
+	if f.Before != 0 {
+		query += f.cursor()
+		args = append(args, f.Before)
+	}
+	query += f.orderBy() + " LIMIT ? OFFSET ?"
 	args = append(args, f.Limit, f.Offset)
 
 	rows, err := db.Query(query, args...)
@@ -78,8 +260,8 @@ func (db *DB) ListDownloads(f DownloadFilter) ([]Download, int, error) {
 
 	var downloads []Download
 	for rows.Next() {
-		var d Download
-		if err := rows.Scan(&d.ID, &d.URL, &d.TelegramUserID, &d.TelegramUsername, &d.ChatID, &d.Status, &d.Filename, &d.ErrorMessage, &d.CreatedAt); err != nil {
+		d, err := scanDownload(rows)
+		if err != nil {
 			return nil, 0, err
 		}
 		downloads = append(downloads, d)
@@ -88,6 +270,120 @@ func (db *DB) ListDownloads(f DownloadFilter) ([]Download, int, error) {
 	return downloads, total, rows.Err()
 }
 
+// EachDownload calls fn with every download matching f, in f's order,
+// reading them from the database one at a time. It stops at the first error
+// fn returns. Before, Limit and Offset are ignored.
+func (db *DB) EachDownload(f DownloadFilter, fn func(Download) error) error {
+	where, args := f.where()
+	rows, err := db.Query("SELECT "+downloadColumns+" FROM downloads WHERE 1=1"+where+f.orderBy(), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanDownload(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListDownloadExtractors returns the distinct sites downloads were probed
+// from, for filtering.
+func (db *DB) ListDownloadExtractors() ([]string, error) {
+	return db.distinctDownloadValues("extractor")
+}
+
+// ListDownloadErrorKinds returns the distinct error kinds of failed
+// downloads, for filtering.
+func (db *DB) ListDownloadErrorKinds() ([]string, error) {
+	return db.distinctDownloadValues("error_kind")
+}
+
+// distinctDownloadValues returns the non-empty values of column, sorted.
+// column must not come from user input.
+func (db *DB) distinctDownloadValues(column string) ([]string, error) {
+	rows, err := db.Query("SELECT DISTINCT " + column + " FROM downloads WHERE " + column + " != '' ORDER BY " + column)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// FindDownload returns the download with the given ID.
+func (db *DB) FindDownload(id int64) (Download, bool, error) {
+	d, err := scanDownload(db.QueryRow(`SELECT `+downloadColumns+` FROM downloads WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Download{}, false, nil
+	}
+	if err != nil {
+		return Download{}, false, err
+	}
+	return d, true, nil
+}
+
+// ListRetries returns the downloads that retry the download id, oldest
+// first.
+func (db *DB) ListRetries(id int64) ([]Download, error) {
+	rows, err := db.Query(`SELECT `+downloadColumns+` FROM downloads WHERE retry_of = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var downloads []Download
+	for rows.Next() {
+		d, err := scanDownload(rows)
+		if err != nil {
+			return nil, err
+		}
+		downloads = append(downloads, d)
+	}
+	return downloads, rows.Err()
+}
+
+func scanDownload(row interface{ Scan(...any) error }) (Download, error) {
+	var d Download
+	var probe, download, encodeWait, encode, upload int64
+	var finishedAt sql.NullTime
+	err := row.Scan(&d.ID, &d.URL, &d.TelegramUserID, &d.TelegramUsername, &d.ChatID, &d.Status, &d.Filename, &d.ErrorMessage, &d.CreatedAt,
+		&d.Title, &d.Extractor, &d.Duration, &d.Format, &d.FileSize, &d.CacheKey, &d.ErrorKind, &d.Attempt, &d.RetryOf,
+		&probe, &download, &encodeWait, &encode, &upload, &finishedAt)
+	if err != nil {
+		return Download{}, err
+	}
+	d.Timings = DownloadTimings{
+		Probe:      time.Duration(probe) * time.Millisecond,
+		Download:   time.Duration(download) * time.Millisecond,
+		EncodeWait: time.Duration(encodeWait) * time.Millisecond,
+		Encode:     time.Duration(encode) * time.Millisecond,
+		Upload:     time.Duration(upload) * time.Millisecond,
+	}
+	d.FinishedAt = finishedAt.Time
+	return d, nil
+}
+
+// DeleteDownload removes a download from the history.
+func (db *DB) DeleteDownload(id int64) error {
+	_, err := db.Exec(`DELETE FROM downloads WHERE id = ?`, id)
+	return err
+}
+
 type DownloadStats struct {
 	Total     int
 	Succeeded int
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestListDownloadsCursor(t *testing.T) {
+	db := newTestDB(t)
+	for _, u := range []string{"https://c.com/1", "https://a.com/1", "https://b.com/1", "https://a.com/2", "https://c.com/2", "https://b.com/2", "https://a.com/3"} {
+		if _, err := db.InsertDownload(u, 1, "user", 1, "success", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, f := range []DownloadFilter{
+		{},
+		{Asc: true},
+		{Sort: "url"},
+		{Sort: "url", Asc: true},
+		{Sort: "status", Asc: true},
+		{Sort: "time"},
+	} {
+		all, _, err := db.ListDownloads(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paged []Download
+		page := f
+		page.Limit = 2
+		for {
+			downloads, _, err := db.ListDownloads(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paged = append(paged, downloads...)
+			if len(downloads) < page.Limit {
+				break
+			}
+			page.Before = downloads[len(downloads)-1].ID
+		}
+
+		if !slices.Equal(downloadIDs(paged), downloadIDs(all)) {
+			t.Errorf("sort %q asc %v: pages = %v, want %v", f.Sort, f.Asc, downloadIDs(paged), downloadIDs(all))
+		}
+	}
+}
+
+func TestListDownloadsEscapesPatterns(t *testing.T) {
+	db := newTestDB(t)
+	for _, u := range []string{"https://axb.com/v", "https://a_b.com/v", "https://x.com/100%25", "https://x.com/1000"} {
+		if _, err := db.InsertDownload(u, 1, "user", 1, "success", "", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter DownloadFilter
+		want   []string
+	}{
+		{DownloadFilter{Domain: "a_b.com"}, []string{"https://a_b.com/v"}},
+		{DownloadFilter{Domain: "axb.com"}, []string{"https://axb.com/v"}},
+		{DownloadFilter{Search: "_"}, []string{"https://a_b.com/v"}},
+		{DownloadFilter{Search: "100%"}, []string{"https://x.com/100%25"}},
+		{DownloadFilter{Search: "%"}, []string{"https://x.com/100%25"}},
+	}
+
+	for _, tt := range tests {
+		downloads, total, err := db.ListDownloads(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range downloads {
+			got = append(got, d.URL)
+		}
+		if !slices.Equal(got, tt.want) || total != len(tt.want) {
+			t.Errorf("ListDownloads(%+v) = %v (total %d), want %v", tt.filter, got, total, tt.want)
+		}
+	}
+}
+
+func downloadIDs(downloads []Download) []int64 {
+	ids := make([]int64, len(downloads))
+	for i, d := range downloads {
+		ids[i] = d.ID
+	}
+	return ids
+}
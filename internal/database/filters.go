@@ -11,13 +11,25 @@ type URLFilter struct {
 	ExcludeQueryParams bool
 	PathRegex          string
 	CookiesFile        string
+	Proxy              string
+	Impersonate        string
+	UserAgent          string
+	RateLimit          string
+	Format             string
+	GeoBypassCountry   string
+	ExtraArgs          []string
+	Postprocess        []string
+	Profile            string
 	CreatedAt          time.Time
 }
 
-func (db *DB) InsertFilter(hosts []string, excludeQueryParams bool, pathRegex, cookiesFile string) (int64, error) {
+func (db *DB) InsertFilter(f URLFilter) (int64, error) {
 	result, err := db.Exec(
-		`INSERT INTO url_filters (hosts, exclude_query_params, path_regex, cookies_file) VALUES (?, ?, ?, ?)`,
-		strings.Join(hosts, "\n"), boolToInt(excludeQueryParams), pathRegex, cookiesFile,
+		`INSERT INTO url_filters (hosts, exclude_query_params, path_regex, cookies_file, proxy, impersonate, user_agent, rate_limit, format, geo_bypass_country, extra_args, postprocess, profile)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		strings.Join(f.Hosts, "\n"), boolToInt(f.ExcludeQueryParams), f.PathRegex, f.CookiesFile,
+		f.Proxy, f.Impersonate, f.UserAgent, f.RateLimit, f.Format, f.GeoBypassCountry, strings.Join(f.ExtraArgs, "\n"),
+		strings.Join(f.Postprocess, "\n"), f.Profile,
 	)
 	if err != nil {
 		return 0, err
@@ -25,10 +37,14 @@ func (db *DB) InsertFilter(hosts []string, excludeQueryParams bool, pathRegex, c
 	return result.LastInsertId()
 }
 
-func (db *DB) UpdateFilter(id int64, hosts []string, excludeQueryParams bool, pathRegex, cookiesFile string) error {
+func (db *DB) UpdateFilter(f URLFilter) error {
 	_, err := db.Exec(
-		`UPDATE url_filters SET hosts = ?, exclude_query_params = ?, path_regex = ?, cookies_file = ? WHERE id = ?`,
-		strings.Join(hosts, "\n"), boolToInt(excludeQueryParams), pathRegex, cookiesFile, id,
+		`UPDATE url_filters SET hosts = ?, exclude_query_params = ?, path_regex = ?, cookies_file = ?,
+		 proxy = ?, impersonate = ?, user_agent = ?, rate_limit = ?, format = ?, geo_bypass_country = ?, extra_args = ?, postprocess = ?,
+		 profile = ? WHERE id = ?`,
+		strings.Join(f.Hosts, "\n"), boolToInt(f.ExcludeQueryParams), f.PathRegex, f.CookiesFile,
+		f.Proxy, f.Impersonate, f.UserAgent, f.RateLimit, f.Format, f.GeoBypassCountry, strings.Join(f.ExtraArgs, "\n"),
+		strings.Join(f.Postprocess, "\n"), f.Profile, f.ID,
 	)
 	return err
 }
@@ -39,7 +55,22 @@ func (db *DB) DeleteFilter(id int64) error {
 }
 
 func (db *DB) ListFilters() ([]URLFilter, error) {
-	rows, err := db.Query(`SELECT id, hosts, exclude_query_params, path_regex, cookies_file, created_at FROM url_filters ORDER BY id`)
+	return db.queryFilters(`ORDER BY id`)
+}
+
+// FindFilter returns the filter with the given ID.
+func (db *DB) FindFilter(id int64) (URLFilter, bool, error) {
+	filters, err := db.queryFilters(`WHERE id = ?`, id)
+	if err != nil || len(filters) == 0 {
+		return URLFilter{}, false, err
+	}
+	return filters[0], true, nil
+}
+
+func (db *DB) queryFilters(where string, args ...any) ([]URLFilter, error) {
+	rows, err := db.Query(`SELECT id, hosts, exclude_query_params, path_regex, cookies_file,
+		proxy, impersonate, user_agent, rate_limit, format, geo_bypass_country, extra_args, postprocess, profile, created_at
+		FROM url_filters `+where, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -48,12 +79,16 @@ func (db *DB) ListFilters() ([]URLFilter, error) {
 	var filters []URLFilter
 	for rows.Next() {
 		var f URLFilter
-		var hostsStr string
+		var hostsStr, extraArgsStr, postprocessStr string
 		var excludeQP int
-		if err := rows.Scan(&f.ID, &hostsStr, &excludeQP, &f.PathRegex, &f.CookiesFile, &f.CreatedAt); err != nil {
+		if err := rows.Scan(&f.ID, &hostsStr, &excludeQP, &f.PathRegex, &f.CookiesFile,
+			&f.Proxy, &f.Impersonate, &f.UserAgent, &f.RateLimit, &f.Format, &f.GeoBypassCountry, &extraArgsStr,
+			&postprocessStr, &f.Profile, &f.CreatedAt); err != nil {
 			return nil, err
 		}
-		f.Hosts = splitHosts(hostsStr)
+		f.Hosts = splitLines(hostsStr)
+		f.ExtraArgs = splitLines(extraArgsStr)
+		f.Postprocess = splitLines(postprocessStr)
 		f.ExcludeQueryParams = excludeQP != 0
 		filters = append(filters, f)
 	}
@@ -68,12 +103,7 @@ func (db *DB) FilterCount() (int, error) {
 
 // SeedFilters inserts config filters into the DB only if the table is empty.
 // If no config filters are provided, seeds with default popular platforms.
-func (db *DB) SeedFilters(filters []struct {
-	Hosts              []string
-	ExcludeQueryParams bool
-	PathRegex          string
-	CookiesFile        string
-}) error {
+func (db *DB) SeedFilters(filters []URLFilter) error {
 	count, err := db.FilterCount()
 	if err != nil {
 		return err
@@ -85,7 +115,7 @@ func (db *DB) SeedFilters(filters []struct {
 	// Use config filters if provided
 	if len(filters) > 0 {
 		for _, f := range filters {
-			if _, err := db.InsertFilter(f.Hosts, f.ExcludeQueryParams, f.PathRegex, f.CookiesFile); err != nil {
+			if _, err := db.InsertFilter(f); err != nil {
 				return err
 			}
 		}
@@ -106,7 +136,7 @@ func (db *DB) SeedFilters(filters []struct {
 	}
 
 	for _, d := range defaults {
-		if _, err := db.InsertFilter(d.hosts, d.excludeQueryParams, "", ""); err != nil {
+		if _, err := db.InsertFilter(URLFilter{Hosts: d.hosts, ExcludeQueryParams: d.excludeQueryParams}); err != nil {
 			return err
 		}
 	}
@@ -120,16 +150,16 @@ func boolToInt(b bool) int {
 	return 0
 }
 
-func splitHosts(s string) []string {
+func splitLines(s string) []string {
 	if s == "" {
 		return nil
 	}
-	var hosts []string
-	for _, h := range strings.Split(s, "\n") {
-		h = strings.TrimSpace(h)
-		if h != "" {
-			hosts = append(hosts, h)
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			lines = append(lines, l)
 		}
 	}
-	return hosts
+	return lines
 }
@@ -1,26 +1,36 @@
 package database
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/logquery"
+)
 
 type LogEntry struct {
-	ID        int64
-	Level     string
-	Message   string
-	Fields    string
-	CreatedAt time.Time
+	ID         int64
+	Level      string
+	Message    string
+	Fields     string
+	DownloadID int64 // 0 for lines not logged during a download
+	CreatedAt  time.Time
 }
 
 type LogFilter struct {
-	Level  string
-	Search string
+	Query  logquery.Query
+	Before int64 // only entries with a lower ID, for cursor pagination
 	Limit  int
 	Offset int
 }
 
-func (db *DB) InsertLog(level, message, fields string) (int64, error) {
+const logColumns = "id, level, message, fields, COALESCE(download_id, 0), created_at"
+
+// InsertLog stores a log line. downloadID is the download it was logged
+// for, or 0.
+func (db *DB) InsertLog(level, message, fields string, downloadID int64) (int64, error) {
 	result, err := db.Exec(
-		`INSERT INTO logs (level, message, fields) VALUES (?, ?, ?)`,
-		level, message, fields,
+		`INSERT INTO logs (level, message, fields, download_id) VALUES (?, ?, ?, NULLIF(?, 0))`,
+		level, message, fields, downloadID,
 	)
 	if err != nil {
 		return 0, err
@@ -33,21 +43,9 @@ func (db *DB) ListLogs(f LogFilter) ([]LogEntry, int, error) {
 		f.Limit = 100
 	}
 
-	countQuery := "SELECT COUNT(*) FROM logs WHERE 1=1"
-	query := "SELECT id, level, message, fields, created_at FROM logs WHERE 1=1"
-	var args []any
-
-	if f.Level != "" {
-		countQuery += " AND level = ?"
-		query += " AND level = ?"
-		args = append(args, f.Level)
-	}
-	if f.Search != "" {
-		countQuery += " AND (message LIKE ? OR fields LIKE ?)"
-		query += " AND (message LIKE ? OR fields LIKE ?)"
-		s := "%" + f.Search + "%"
-		args = append(args, s, s)
-	}
+	where, args := logQueryWhere(f.Query)
+	countQuery := "SELECT COUNT(*) FROM logs WHERE 1=1" + where
+	query := "SELECT " + logColumns + " FROM logs WHERE 1=1" + where
 
 	var total int
 	countArgs := make([]any, len(args))
@@ -56,6 +54,10 @@ func (db *DB) ListLogs(f LogFilter) ([]LogEntry, int, error) {
 		return nil, 0, err
 	}
 
+	if f.Before != 0 {
+		query += " AND id < ?"
+		args = append(args, f.Before)
+	}
 	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
 	args = append(args, f.Limit, f.Offset)
 
@@ -68,7 +70,7 @@ func (db *DB) ListLogs(f LogFilter) ([]LogEntry, int, error) {
 	var logs []LogEntry
 	for rows.Next() {
 		var l LogEntry
-		if err := rows.Scan(&l.ID, &l.Level, &l.Message, &l.Fields, &l.CreatedAt); err != nil {
+		if err := rows.Scan(&l.ID, &l.Level, &l.Message, &l.Fields, &l.DownloadID, &l.CreatedAt); err != nil {
 			return nil, 0, err
 		}
 		logs = append(logs, l)
@@ -79,7 +81,7 @@ func (db *DB) ListLogs(f LogFilter) ([]LogEntry, int, error) {
 
 func (db *DB) ListLogsSince(id int64) ([]LogEntry, error) {
 	rows, err := db.Query(
-		"SELECT id, level, message, fields, created_at FROM logs WHERE id > ? ORDER BY id ASC",
+		"SELECT "+logColumns+" FROM logs WHERE id > ? ORDER BY id ASC",
 		id,
 	)
 	if err != nil {
@@ -90,7 +92,31 @@ func (db *DB) ListLogsSince(id int64) ([]LogEntry, error) {
 	var logs []LogEntry
 	for rows.Next() {
 		var l LogEntry
-		if err := rows.Scan(&l.ID, &l.Level, &l.Message, &l.Fields, &l.CreatedAt); err != nil {
+		if err := rows.Scan(&l.ID, &l.Level, &l.Message, &l.Fields, &l.DownloadID, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+
+	return logs, rows.Err()
+}
+
+// ListDownloadLogs returns the log lines recorded with the download's ID,
+// oldest first.
+func (db *DB) ListDownloadLogs(downloadID int64) ([]LogEntry, error) {
+	rows, err := db.Query(
+		"SELECT "+logColumns+" FROM logs WHERE download_id = ? ORDER BY id ASC LIMIT 500",
+		downloadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []LogEntry
+	for rows.Next() {
+		var l LogEntry
+		if err := rows.Scan(&l.ID, &l.Level, &l.Message, &l.Fields, &l.DownloadID, &l.CreatedAt); err != nil {
 			return nil, err
 		}
 		logs = append(logs, l)
@@ -98,3 +124,62 @@ func (db *DB) ListLogsSince(id int64) ([]LogEntry, error) {
 
 	return logs, rows.Err()
 }
+
+// logQueryWhere returns the SQL conditions selecting the logs matching q,
+// each starting with " AND", and their arguments. Terms are looked up in
+// the full-text index, so they match whole words or word prefixes.
+func logQueryWhere(q logquery.Query) (string, []any) {
+	var where string
+	var args []any
+
+	if len(q.Terms) > 0 {
+		phrases := make([]string, len(q.Terms))
+		for i, term := range q.Terms {
+			phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+		}
+		where += " AND id IN (SELECT rowid FROM logs_fts WHERE logs_fts MATCH ?)"
+		args = append(args, strings.Join(phrases, " "))
+	}
+	if q.Level != "" {
+		where += " AND level = ?"
+		args = append(args, q.Level)
+	}
+	if q.DownloadID != 0 {
+		where += " AND download_id = ?"
+		args = append(args, q.DownloadID)
+	}
+	if !q.Since.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, q.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !q.Until.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, q.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+	for _, f := range q.Fields {
+		// Booleans compare as true and false rather than 1 and 0.
+		value := `CASE json_type(fields, ?) WHEN 'true' THEN 'true' WHEN 'false' THEN 'false'
+			ELSE CAST(json_extract(fields, ?) AS TEXT) END`
+		if f.Wildcard() {
+			where += " AND json_valid(fields) AND " + value + ` LIKE ? ESCAPE '\'`
+			args = append(args, f.Path(), f.Path(), likePattern(f.Value))
+		} else {
+			where += " AND json_valid(fields) AND " + value + " = ?"
+			args = append(args, f.Path(), f.Path(), f.Value)
+		}
+	}
+
+	return where, args
+}
+
+// likePattern turns a value with * wildcards into a LIKE pattern escaped
+// with backslashes.
+func likePattern(value string) string {
+	return strings.ReplaceAll(escapeLike(value), "*", "%")
+}
+
+// escapeLike escapes the LIKE wildcards in value, for matching it literally
+// with ESCAPE '\'.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -67,6 +67,151 @@ var migrations = []string{
 
 	// Migration 3: Add status column to allowed_users (pending/approved/rejected)
 	`ALTER TABLE allowed_users ADD COLUMN status TEXT NOT NULL DEFAULT 'approved';`,
+
+	// Migration 4: Per-filter yt-dlp options
+	`ALTER TABLE url_filters ADD COLUMN proxy TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_filters ADD COLUMN impersonate TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_filters ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_filters ADD COLUMN rate_limit TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_filters ADD COLUMN format TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_filters ADD COLUMN geo_bypass_country TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url_filters ADD COLUMN extra_args TEXT NOT NULL DEFAULT '';`,
+
+	// Migration 5: Cookie jars uploaded through the dashboard
+	`CREATE TABLE IF NOT EXISTS cookie_jars (
+		site TEXT PRIMARY KEY,
+		path TEXT NOT NULL,
+		domains TEXT NOT NULL DEFAULT '',
+		cookie_count INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME,
+		login_failures INTEGER NOT NULL DEFAULT 0,
+		last_failure_at DATETIME,
+		uploaded_at DATETIME NOT NULL DEFAULT (datetime('now'))
+	);`,
+
+	// Migration 6: Channel subscriptions and the entries already posted for each
+	`CREATE TABLE IF NOT EXISTS subscriptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		created_by INTEGER NOT NULL DEFAULT 0,
+		created_by_name TEXT NOT NULL DEFAULT '',
+		last_checked_at DATETIME,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT (datetime('now')),
+		UNIQUE(chat_id, url)
+	);
+
+	CREATE TABLE IF NOT EXISTS subscription_items (
+		subscription_id INTEGER NOT NULL,
+		video_id TEXT NOT NULL,
+		seen_at DATETIME NOT NULL DEFAULT (datetime('now')),
+		PRIMARY KEY (subscription_id, video_id)
+	);`,
+
+	// Migration 7: Per-filter post-processing stages
+	`ALTER TABLE url_filters ADD COLUMN postprocess TEXT NOT NULL DEFAULT '';`,
+
+	// Migration 8: Per-filter encoding profile
+	`ALTER TABLE url_filters ADD COLUMN profile TEXT NOT NULL DEFAULT '';`,
+
+	// Migration 9: API tokens
+	`CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		scopes TEXT NOT NULL DEFAULT '',
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		created_at DATETIME NOT NULL DEFAULT (datetime('now'))
+	);`,
+
+	// Migration 10: dashboard accounts
+	`CREATE TABLE IF NOT EXISTS dashboard_users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'viewer',
+		created_at DATETIME NOT NULL DEFAULT (datetime('now'))
+	);`,
+
+	// Migration 11: Session owner and metadata. Tokens are stored hashed.
+	`ALTER TABLE sessions ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+	ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+	ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+	CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);`,
+
+	// Migration 12: TOTP two-factor authentication
+	`ALTER TABLE dashboard_users ADD COLUMN totp_secret TEXT NOT NULL DEFAULT '';
+	ALTER TABLE dashboard_users ADD COLUMN totp_last_counter INTEGER NOT NULL DEFAULT 0;
+
+	CREATE TABLE IF NOT EXISTS recovery_codes (
+		user_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL,
+		PRIMARY KEY (user_id, code_hash)
+	);`,
+
+	// Migration 13: Audit log of administrative actions
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		before TEXT NOT NULL DEFAULT '',
+		after TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT (datetime('now'))
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+	CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action);`,
+
+	// Migration 14: Download metadata, phase timings and retries
+	`ALTER TABLE downloads ADD COLUMN title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE downloads ADD COLUMN extractor TEXT NOT NULL DEFAULT '';
+	ALTER TABLE downloads ADD COLUMN duration REAL NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN format TEXT NOT NULL DEFAULT '';
+	ALTER TABLE downloads ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN cache_key TEXT NOT NULL DEFAULT '';
+	ALTER TABLE downloads ADD COLUMN error_kind TEXT NOT NULL DEFAULT '';
+	ALTER TABLE downloads ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE downloads ADD COLUMN retry_of INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN probe_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN download_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN encode_wait_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN encode_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN upload_ms INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE downloads ADD COLUMN finished_at DATETIME;`,
+
+	// Migration 15: Download ID on log lines
+	`ALTER TABLE logs ADD COLUMN download_id INTEGER;
+	UPDATE logs SET download_id = json_extract(fields, '$.download_id')
+		WHERE json_valid(fields) AND json_type(fields, '$.download_id') = 'integer';
+	CREATE INDEX IF NOT EXISTS idx_logs_download_id ON logs(download_id);`,
+
+	// Migration 16: Indexes for filtering downloads
+	`CREATE INDEX IF NOT EXISTS idx_downloads_chat_id ON downloads(chat_id);
+	CREATE INDEX IF NOT EXISTS idx_downloads_extractor ON downloads(extractor);
+	CREATE INDEX IF NOT EXISTS idx_downloads_error_kind ON downloads(error_kind);`,
+
+	// Migration 17: Full-text index of log messages and fields
+	`CREATE VIRTUAL TABLE IF NOT EXISTS logs_fts USING fts5(message, fields, content='logs', content_rowid='id');
+	CREATE TRIGGER IF NOT EXISTS logs_fts_insert AFTER INSERT ON logs BEGIN
+		INSERT INTO logs_fts (rowid, message, fields) VALUES (new.id, new.message, new.fields);
+	END;
+	CREATE TRIGGER IF NOT EXISTS logs_fts_delete AFTER DELETE ON logs BEGIN
+		INSERT INTO logs_fts (logs_fts, rowid, message, fields) VALUES ('delete', old.id, old.message, old.fields);
+	END;
+	INSERT INTO logs_fts (logs_fts) VALUES ('rebuild');`,
+
+	// Migration 18: Identity of single sign-on accounts at their provider
+	`ALTER TABLE dashboard_users ADD COLUMN sso_issuer TEXT NOT NULL DEFAULT '';
+	ALTER TABLE dashboard_users ADD COLUMN sso_subject TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_dashboard_users_sso ON dashboard_users(sso_issuer, sso_subject) WHERE sso_subject != '';`,
 }
 
 func runMigrations(db *sql.DB) error {
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pruneBatch is how many rows one DELETE removes, so that pruning a large
+// backlog never holds the write lock for long.
+const pruneBatch = 5000
+
+// TableSize is the row count and on-disk size, indexes included, of a table.
+type TableSize struct {
+	Name  string
+	Rows  int64
+	Bytes int64
+}
+
+// DatabaseSize is the size of the database file and how much of it is free
+// pages that a vacuum would return to the file system.
+type DatabaseSize struct {
+	Bytes     int64
+	FreeBytes int64
+}
+
+// PruneLogs deletes log lines at level, or at any level when it is empty,
+// that were logged before cutoff or are older than the newest keep lines.
+// A zero cutoff or keep skips that limit.
+func (db *DB) PruneLogs(level string, cutoff time.Time, keep int) (int64, error) {
+	where, args := "1 = 1", []any{}
+	if level != "" {
+		where, args = "level = ?", []any{level}
+	}
+	return db.prune("logs", where, args, cutoff, keep)
+}
+
+// PruneDownloads deletes finished downloads started before cutoff or older
+// than the newest keep downloads. Pending downloads are never deleted. A
+// zero cutoff or keep skips that limit.
+func (db *DB) PruneDownloads(cutoff time.Time, keep int) (int64, error) {
+	return db.prune("downloads", "status != 'pending'", nil, cutoff, keep)
+}
+
+// prune deletes the rows of table matching where that are older than cutoff
+// or fall outside the newest keep rows matching where, in batches.
+func (db *DB) prune(table, where string, args []any, cutoff time.Time, keep int) (int64, error) {
+	var limits []string
+	var limitArgs []any
+	if !cutoff.IsZero() {
+		limits = append(limits, "created_at < ?")
+		limitArgs = append(limitArgs, cutoff.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if keep > 0 {
+		limits = append(limits, fmt.Sprintf("id <= (SELECT id FROM %s WHERE %s ORDER BY id DESC LIMIT 1 OFFSET ?)", table, where))
+		limitArgs = append(limitArgs, args...)
+		limitArgs = append(limitArgs, keep)
+	}
+	if len(limits) == 0 {
+		return 0, nil
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE %s AND (%s) LIMIT %d)",
+		table, table, where, strings.Join(limits, " OR "), pruneBatch)
+	queryArgs := append(append([]any{}, args...), limitArgs...)
+
+	var deleted int64
+	for {
+		result, err := db.Exec(query, queryArgs...)
+		if err != nil {
+			return deleted, fmt.Errorf("prune %s: %w", table, err)
+		}
+		n, _ := result.RowsAffected()
+		deleted += n
+		if n < pruneBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// TableSizes returns the size of every table, largest first. The shadow
+// tables of the log search index are counted as logs_fts.
+func (db *DB) TableSizes() ([]TableSize, error) {
+	rows, err := db.Query(`SELECT CASE WHEN s.tbl_name LIKE 'logs_fts%' THEN 'logs_fts' ELSE s.tbl_name END AS tbl, SUM(d.pgsize)
+		FROM dbstat d JOIN sqlite_schema s ON s.name = d.name
+		WHERE s.tbl_name NOT LIKE 'sqlite_%'
+		GROUP BY tbl ORDER BY 2 DESC, 1`)
+	if err != nil {
+		return nil, err
+	}
+	var sizes []TableSize
+	for rows.Next() {
+		var t TableSize
+		if err := rows.Scan(&t.Name, &t.Bytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		sizes = append(sizes, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, t := range sizes {
+		if err := db.QueryRow(`SELECT COUNT(*) FROM "` + t.Name + `"`).Scan(&sizes[i].Rows); err != nil {
+			return nil, err
+		}
+	}
+	return sizes, nil
+}
+
+// Size returns the size of the database file and its free pages.
+func (db *DB) Size() (DatabaseSize, error) {
+	var pageSize, pages, free int64
+	if err := db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return DatabaseSize{}, err
+	}
+	if err := db.QueryRow(`PRAGMA page_count`).Scan(&pages); err != nil {
+		return DatabaseSize{}, err
+	}
+	if err := db.QueryRow(`PRAGMA freelist_count`).Scan(&free); err != nil {
+		return DatabaseSize{}, err
+	}
+	return DatabaseSize{Bytes: pages * pageSize, FreeBytes: free * pageSize}, nil
+}
+
+// Vacuum compacts the log search index, rebuilds the database file to
+// return free pages to the file system, and truncates the write-ahead log.
+func (db *DB) Vacuum() error {
+	if _, err := db.Exec(`INSERT INTO logs_fts (logs_fts) VALUES ('optimize')`); err != nil {
+		return fmt.Errorf("optimize log search index: %w", err)
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session is a signed-in dashboard session. Token is a hash of the cookie
+// value, so it is safe to show and to use as an identifier.
+type Session struct {
+	Token      string
+	UserID     int64
+	Username   string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (db *DB) InsertSession(s Session) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(
+		`INSERT INTO sessions (token, user_id, ip, user_agent, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.Token, s.UserID, s.IP, s.UserAgent, now, now, s.ExpiresAt.UTC(),
+	)
+	return err
+}
+
+// FindSession returns the unexpired session with the given token hash.
+func (db *DB) FindSession(token string) (Session, bool, error) {
+	sessions, err := db.querySessions(`WHERE s.token = ? AND s.expires_at > ?`, token, time.Now().UTC())
+	if err != nil || len(sessions) == 0 {
+		return Session{}, false, err
+	}
+	return sessions[0], true, nil
+}
+
+// ListSessions returns unexpired sessions, most recently active first, for
+// userID or for everyone if userID is 0.
+func (db *DB) ListSessions(userID int64) ([]Session, error) {
+	if userID == 0 {
+		return db.querySessions(`WHERE s.expires_at > ? ORDER BY s.last_seen_at DESC`, time.Now().UTC())
+	}
+	return db.querySessions(`WHERE s.expires_at > ? AND s.user_id = ? ORDER BY s.last_seen_at DESC`, time.Now().UTC(), userID)
+}
+
+// RenewSession records activity on a session and pushes its expiry out.
+func (db *DB) RenewSession(token string, ip string, expiresAt time.Time) error {
+	_, err := db.Exec(`UPDATE sessions SET last_seen_at = ?, ip = ?, expires_at = ? WHERE token = ?`,
+		time.Now().UTC(), ip, expiresAt.UTC(), token)
+	return err
+}
+
+func (db *DB) DeleteSession(token string) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE token = ?`, token)
+	return err
+}
+
+// DeleteUserSessions signs userID out everywhere except the session with the
+// token hash keep, which may be empty.
+func (db *DB) DeleteUserSessions(userID int64, keep string) error {
+	_, err := db.Exec(`DELETE FROM sessions WHERE user_id = ? AND token != ?`, userID, keep)
+	return err
+}
+
+// DeleteExpiredSessions removes expired sessions and returns how many there
+// were.
+func (db *DB) DeleteExpiredSessions() (int64, error) {
+	result, err := db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (db *DB) querySessions(where string, args ...any) ([]Session, error) {
+	rows, err := db.Query(`SELECT s.token, s.user_id, COALESCE(u.username, ''), s.ip, s.user_agent, s.created_at, s.last_seen_at, s.expires_at
+		FROM sessions s LEFT JOIN dashboard_users u ON u.id = s.user_id `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		var lastSeenAt sql.NullTime
+		if err := rows.Scan(&s.Token, &s.UserID, &s.Username, &s.IP, &s.UserAgent, &s.CreatedAt, &lastSeenAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		s.LastSeenAt = lastSeenAt.Time
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
@@ -31,7 +31,7 @@ func (db *DB) GetStats() (Stats, error) {
 		return s, err
 	}
 
-	err = db.QueryRow(`SELECT COUNT(DISTINCT telegram_user_id) FROM downloads`).Scan(&s.ActiveUsers)
+	err = db.QueryRow(`SELECT COUNT(DISTINCT telegram_user_id) FROM downloads WHERE telegram_user_id != 0`).Scan(&s.ActiveUsers)
 	if err != nil {
 		return s, err
 	}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Subscription struct {
+	ID            int64
+	ChatID        int64
+	URL           string
+	Title         string
+	CreatedBy     int64
+	CreatedByName string
+	LastCheckedAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
+
+// InsertSubscription adds a subscription. It reports false if the chat is
+// already subscribed to url.
+func (db *DB) InsertSubscription(chatID int64, url, title string, createdBy int64, createdByName string) (bool, error) {
+	result, err := db.Exec(
+		`INSERT OR IGNORE INTO subscriptions (chat_id, url, title, created_by, created_by_name) VALUES (?, ?, ?, ?, ?)`,
+		chatID, url, title, createdBy, createdByName,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteSubscription removes a subscription and its seen entries.
+func (db *DB) DeleteSubscription(id int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM subscription_items WHERE subscription_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM subscriptions WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindSubscription returns the chat's subscription to url, if any.
+func (db *DB) FindSubscription(chatID int64, url string) (Subscription, bool, error) {
+	subs, err := db.querySubscriptions(`WHERE chat_id = ? AND url = ?`, chatID, url)
+	if err != nil || len(subs) == 0 {
+		return Subscription{}, false, err
+	}
+	return subs[0], true, nil
+}
+
+// ListSubscriptions returns all subscriptions, optionally limited to one chat.
+func (db *DB) ListSubscriptions(chatID int64) ([]Subscription, error) {
+	if chatID != 0 {
+		return db.querySubscriptions(`WHERE chat_id = ? ORDER BY created_at`, chatID)
+	}
+	return db.querySubscriptions(`ORDER BY chat_id, created_at`)
+}
+
+// MarkSubscriptionChecked records a successful poll of the subscription.
+func (db *DB) MarkSubscriptionChecked(id int64, title string) error {
+	_, err := db.Exec(
+		`UPDATE subscriptions SET last_checked_at = ?, last_error = '', title = CASE WHEN ? = '' THEN title ELSE ? END WHERE id = ?`,
+		time.Now().UTC(), title, title, id,
+	)
+	return err
+}
+
+// RecordSubscriptionError stores the error from a failed poll of the subscription.
+func (db *DB) RecordSubscriptionError(id int64, lastError string) error {
+	_, err := db.Exec(`UPDATE subscriptions SET last_error = ? WHERE id = ?`, lastError, id)
+	return err
+}
+
+// MarkSubscriptionItemSeen records a channel entry as handled. It reports
+// false if the entry had already been seen.
+func (db *DB) MarkSubscriptionItemSeen(subscriptionID int64, videoID string) (bool, error) {
+	result, err := db.Exec(
+		`INSERT OR IGNORE INTO subscription_items (subscription_id, video_id) VALUES (?, ?)`,
+		subscriptionID, videoID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (db *DB) querySubscriptions(where string, args ...any) ([]Subscription, error) {
+	rows, err := db.Query(`SELECT id, chat_id, url, title, created_by, created_by_name, last_checked_at, last_error, created_at
+		FROM subscriptions `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		var lastCheckedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.ChatID, &s.URL, &s.Title, &s.CreatedBy, &s.CreatedByName, &lastCheckedAt, &s.LastError, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.LastCheckedAt = lastCheckedAt.Time
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
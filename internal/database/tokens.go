@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// APIToken is a personal token for the dashboard API. Only a hash of the
+// token is stored; Prefix identifies it in listings.
+type APIToken struct {
+	ID         int64
+	Name       string
+	Prefix     string
+	Scopes     []string
+	ExpiresAt  time.Time // zero if the token never expires
+	LastUsedAt time.Time // zero if never used
+	CreatedAt  time.Time
+}
+
+// Expired reports whether the token has passed its expiry date.
+func (t APIToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+func (db *DB) InsertAPIToken(name, tokenHash, prefix string, scopes []string, expiresAt time.Time) (int64, error) {
+	var expires any
+	if !expiresAt.IsZero() {
+		expires = expiresAt.UTC()
+	}
+	result, err := db.Exec(
+		`INSERT INTO api_tokens (name, token_hash, prefix, scopes, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		name, tokenHash, prefix, strings.Join(scopes, " "), expires,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (db *DB) DeleteAPIToken(id int64) error {
+	_, err := db.Exec(`DELETE FROM api_tokens WHERE id = ?`, id)
+	return err
+}
+
+func (db *DB) ListAPITokens() ([]APIToken, error) {
+	return db.queryAPITokens(`ORDER BY created_at DESC`)
+}
+
+// FindAPITokenByHash returns the token whose hash matches.
+func (db *DB) FindAPITokenByHash(tokenHash string) (APIToken, bool, error) {
+	tokens, err := db.queryAPITokens(`WHERE token_hash = ?`, tokenHash)
+	if err != nil || len(tokens) == 0 {
+		return APIToken{}, false, err
+	}
+	return tokens[0], true, nil
+}
+
+// TouchAPIToken records that the token was just used.
+func (db *DB) TouchAPIToken(id int64) error {
+	_, err := db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, time.Now().UTC(), id)
+	return err
+}
+
+func (db *DB) queryAPITokens(where string, args ...any) ([]APIToken, error) {
+	rows, err := db.Query(`SELECT id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.Prefix, &scopes, &expiresAt, &lastUsedAt, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Scopes = strings.Fields(scopes)
+		t.ExpiresAt = expiresAt.Time
+		t.LastUsedAt = lastUsedAt.Time
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCancelled is the cancellation cause for jobs stopped by a user or admin.
+var ErrCancelled = errors.New("job cancelled")
+
+// TimeoutError reports which phase of a job ran out of time.
+type TimeoutError struct {
+	Phase string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s phase timed out", e.Phase)
+}
+
+// WithPhaseTimeout derives a context that expires after d, recording a
+// TimeoutError for the phase as the cancellation cause.
+func WithPhaseTimeout(ctx context.Context, phase string, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, d, &TimeoutError{Phase: phase})
+}
+
+// Cause returns the reason ctx was cancelled when err was caused by it,
+// so callers see ErrCancelled or a TimeoutError instead of a bare kill signal.
+func Cause(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	return context.Cause(ctx)
+}
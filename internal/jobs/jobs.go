@@ -0,0 +1,149 @@
+package jobs
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Phases a download job moves through.
+const (
+	PhaseQueued     = "queued"
+	PhaseProbe      = "probe"
+	PhaseDownload   = "download"
+	PhaseEncodeWait = "encode-wait"
+	PhaseEncode     = "encode"
+	PhaseUpload     = "upload"
+)
+
+// Job is an in-flight download started from a Telegram message.
+type Job struct {
+	ID         int64     `json:"id"`
+	DownloadID int64     `json:"download_id"`
+	URL        string    `json:"url"`
+	ChatID     int64     `json:"chat_id"`
+	MessageID  int       `json:"message_id"`
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	Phase      string    `json:"phase"`
+	StartedAt  time.Time `json:"started_at"`
+	PhaseAt    time.Time `json:"phase_at"`
+
+	cancel context.CancelCauseFunc
+	// timings is the time spent in each phase the job has left.
+	timings map[string]time.Duration
+}
+
+// Registry tracks running jobs so they can be listed and cancelled.
+type Registry struct {
+	mu     sync.Mutex
+	nextID int64
+	jobs   map[int64]*Job
+}
+
+// NewRegistry creates an empty job registry.
+func NewRegistry() *Registry {
+	return &Registry{jobs: make(map[int64]*Job)}
+}
+
+// Start registers a job and returns a context that is cancelled when the
+// job is cancelled through the registry.
+func (r *Registry) Start(ctx context.Context, j Job) (context.Context, *Job) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	job := j
+	job.ID = r.nextID
+	job.Phase = PhaseQueued
+	job.StartedAt = time.Now()
+	job.PhaseAt = job.StartedAt
+	job.cancel = cancel
+	job.timings = make(map[string]time.Duration)
+	r.jobs[job.ID] = &job
+
+	return ctx, &job
+}
+
+// SetPhase records the phase the job has entered.
+func (r *Registry) SetPhase(id int64, phase string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if j, ok := r.jobs[id]; ok {
+		now := time.Now()
+		j.timings[j.Phase] += now.Sub(j.PhaseAt)
+		j.Phase = phase
+		j.PhaseAt = now
+	}
+}
+
+// Timings returns the time the job has spent in each phase so far, including
+// the one it is in.
+func (r *Registry) Timings(id int64) map[string]time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok {
+		return nil
+	}
+	timings := make(map[string]time.Duration, len(j.timings)+1)
+	for phase, d := range j.timings {
+		timings[phase] = d
+	}
+	timings[j.Phase] += time.Since(j.PhaseAt)
+	return timings
+}
+
+// Finish removes the job and releases its context.
+func (r *Registry) Finish(id int64) {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	delete(r.jobs, id)
+	r.mu.Unlock()
+
+	if ok {
+		j.cancel(nil)
+	}
+}
+
+// Cancel stops the job with the given ID. It reports whether the job existed.
+func (r *Registry) Cancel(id int64) bool {
+	r.mu.Lock()
+	j, ok := r.jobs[id]
+	r.mu.Unlock()
+
+	if ok {
+		j.cancel(ErrCancelled)
+	}
+	return ok
+}
+
+// FindByMessage returns the jobs started by the given chat message, oldest
+// first. A /playlist command starts one job per entry.
+func (r *Registry) FindByMessage(chatID int64, messageID int) []Job {
+	var found []Job
+	for _, j := range r.List() {
+		if j.ChatID == chatID && j.MessageID == messageID {
+			found = append(found, j)
+		}
+	}
+	return found
+}
+
+// List returns a snapshot of running jobs, oldest first.
+func (r *Registry) List() []Job {
+	r.mu.Lock()
+	list := make([]Job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		list = append(list, *j)
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(list, func(a, b Job) int { return int(a.ID - b.ID) })
+	return list
+}
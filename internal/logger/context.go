@@ -0,0 +1,17 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+)
+
+// Ctx returns the logger attached to ctx with zerolog's WithContext, or
+// fallback if there is none. Downloads attach a logger carrying their ID so
+// every line logged on their behalf can be found together.
+func Ctx(ctx context.Context, fallback *zerolog.Logger) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return fallback
+}
@@ -3,31 +3,37 @@ package logger
 import (
 	"encoding/json"
 	"sync"
+
+	"github.com/rs/zerolog"
 )
 
 // LogEvent represents a parsed log entry for broadcasting.
 type LogEvent struct {
-	Level   string `json:"level"`
-	Message string `json:"message"`
-	Fields  string `json:"fields"`
+	Level      string `json:"level"`
+	Message    string `json:"message"`
+	Fields     string `json:"fields"`
+	DownloadID int64  `json:"download_id,omitempty"`
 }
 
 // DBInserter is the interface for inserting logs into the database.
 type DBInserter interface {
-	InsertLog(level, message, fields string) (int64, error)
+	InsertLog(level, message, fields string, downloadID int64) (int64, error)
 }
 
 // DBWriter is a zerolog writer that writes log entries to SQLite and broadcasts them.
 type DBWriter struct {
 	db          DBInserter
+	minLevel    zerolog.Level
 	subscribers map[chan LogEvent]struct{}
 	mu          sync.RWMutex
 }
 
-// NewDBWriter creates a new DBWriter.
-func NewDBWriter(db DBInserter) *DBWriter {
+// NewDBWriter creates a new DBWriter that stores entries at minLevel and
+// above. Entries below it are only broadcast.
+func NewDBWriter(db DBInserter, minLevel zerolog.Level) *DBWriter {
 	return &DBWriter{
 		db:          db,
+		minLevel:    minLevel,
 		subscribers: make(map[chan LogEvent]struct{}),
 	}
 }
@@ -47,6 +53,14 @@ func (w *DBWriter) Write(p []byte) (n int, err error) {
 	delete(raw, "message")
 	delete(raw, "time")
 
+	// Lines logged during a download carry its ID, which is stored in its
+	// own column so they can be looked up together.
+	var downloadID int64
+	if id, ok := raw["download_id"].(float64); ok {
+		downloadID = int64(id)
+		delete(raw, "download_id")
+	}
+
 	fieldsJSON := "{}"
 	if len(raw) > 0 {
 		if b, err := json.Marshal(raw); err == nil {
@@ -54,9 +68,11 @@ func (w *DBWriter) Write(p []byte) (n int, err error) {
 		}
 	}
 
-	w.db.InsertLog(level, message, fieldsJSON)
+	if l, err := zerolog.ParseLevel(level); err != nil || l >= w.minLevel {
+		w.db.InsertLog(level, message, fieldsJSON, downloadID)
+	}
 
-	event := LogEvent{Level: level, Message: message, Fields: fieldsJSON}
+	event := LogEvent{Level: level, Message: message, Fields: fieldsJSON, DownloadID: downloadID}
 	w.mu.RLock()
 	for ch := range w.subscribers {
 		select {
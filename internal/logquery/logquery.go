@@ -0,0 +1,248 @@
+// Package logquery parses log searches such as
+//
+//	level:error url:*tiktok* chat_id:123 since:1h "upload failed"
+//
+// and matches live log lines against them. The database turns the same
+// queries into SQL, so stored and streamed logs are filtered alike.
+package logquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a parsed log search. Every part must match; the zero Query
+// matches everything.
+type Query struct {
+	// Terms are words or quoted phrases that must appear in the message
+	// or fields.
+	Terms      []string
+	Level      string
+	DownloadID int64
+	Since      time.Time // logged at or after
+	Until      time.Time // logged before
+	Fields     []Field
+}
+
+// Field matches a value in a log line's JSON fields.
+type Field struct {
+	// Key names the field, with dots for nested objects, such as "url".
+	Key string
+	// Value is compared with the field's value as text. A * matches any
+	// run of characters and makes the comparison case-insensitive.
+	Value string
+}
+
+// Wildcard reports whether the value contains * wildcards.
+func (f Field) Wildcard() bool {
+	return strings.Contains(f.Value, "*")
+}
+
+// Path returns the field's SQLite JSON path, such as "$.url".
+func (f Field) Path() string {
+	return "$." + f.Key
+}
+
+// keyPattern limits field keys to names that are safe in a JSON path.
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// Parse parses s. Relative times in since: and until:, such as "1h" or
+// "7d", count back from now; dates are whole UTC days, and until: includes
+// its day.
+func Parse(s string, now time.Time) (Query, error) {
+	var q Query
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+
+		var key string
+		j := i
+		for j < len(s) && s[j] != ' ' && s[j] != '\t' && s[j] != ':' && s[j] != '"' {
+			j++
+		}
+		// "https://..." is a term, not an https field.
+		if j < len(s) && s[j] == ':' && keyPattern.MatchString(s[i:j]) && !strings.HasPrefix(s[j+1:], "//") {
+			key = s[i:j]
+			i = j + 1
+		}
+
+		var value string
+		if i < len(s) && s[i] == '"' {
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return Query{}, errors.New("unterminated quote")
+			}
+			value = s[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			j = i
+			for j < len(s) && s[j] != ' ' && s[j] != '\t' {
+				j++
+			}
+			value = s[i:j]
+			i = j
+		}
+
+		if err := q.add(key, value, now); err != nil {
+			return Query{}, err
+		}
+	}
+	return q, nil
+}
+
+func (q *Query) add(key, value string, now time.Time) error {
+	if key != "" && value == "" {
+		return fmt.Errorf("%s: needs a value", key)
+	}
+
+	switch key {
+	case "":
+		if value != "" {
+			q.Terms = append(q.Terms, value)
+		}
+	case "level":
+		q.Level = strings.ToLower(value)
+	case "download_id":
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("download_id: %q is not a number", value)
+		}
+		q.DownloadID = id
+	case "since":
+		t, err := parseTime(value, now, false)
+		if err != nil {
+			return fmt.Errorf("since: %w", err)
+		}
+		q.Since = t
+	case "until":
+		t, err := parseTime(value, now, true)
+		if err != nil {
+			return fmt.Errorf("until: %w", err)
+		}
+		q.Until = t
+	default:
+		q.Fields = append(q.Fields, Field{Key: key, Value: value})
+	}
+	return nil
+}
+
+// parseTime reads a duration before now ("90m", "2d"), a date, or an
+// RFC 3339 time. A date means its end when endOfDay is set.
+func parseTime(s string, now time.Time, endOfDay bool) (time.Time, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a duration like 1h or 7d, a date, or an RFC 3339 time", s)
+}
+
+// Entry is a log line to match.
+type Entry struct {
+	Level      string
+	Message    string
+	Fields     string // JSON object
+	DownloadID int64
+	Time       time.Time
+}
+
+// Match reports whether e matches q. Terms match as case-insensitive
+// substrings, which is looser than the word matching of stored logs.
+func (q Query) Match(e Entry) bool {
+	if q.Level != "" && e.Level != q.Level {
+		return false
+	}
+	if q.DownloadID != 0 && e.DownloadID != q.DownloadID {
+		return false
+	}
+	if !q.Since.IsZero() && e.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !e.Time.Before(q.Until) {
+		return false
+	}
+
+	message, fields := strings.ToLower(e.Message), strings.ToLower(e.Fields)
+	for _, term := range q.Terms {
+		term = strings.ToLower(term)
+		if !strings.Contains(message, term) && !strings.Contains(fields, term) {
+			return false
+		}
+	}
+
+	if len(q.Fields) == 0 {
+		return true
+	}
+	var values map[string]any
+	dec := json.NewDecoder(strings.NewReader(e.Fields))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return false
+	}
+	for _, f := range q.Fields {
+		v, ok := lookup(values, f.Key)
+		if !ok || !f.matchValue(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Field) matchValue(v string) bool {
+	if !f.Wildcard() {
+		return v == f.Value
+	}
+	parts := strings.Split(f.Value, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile(`(?is)^` + strings.Join(parts, ".*") + `$`).MatchString(v)
+}
+
+// lookup returns the text of the value at the dotted key, rendered the way
+// the database compares it.
+func lookup(values map[string]any, key string) (string, bool) {
+	var v any = values
+	for part := range strings.SplitSeq(key, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if v, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		b, _ := json.Marshal(v)
+		return string(b), true
+	}
+}
@@ -0,0 +1,134 @@
+package logquery
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Query
+	}{
+		{"", Query{}},
+		{"  \t ", Query{}},
+		{"timeout", Query{Terms: []string{"timeout"}}},
+		{`"upload failed" retry`, Query{Terms: []string{"upload failed", "retry"}}},
+		{"level:ERROR", Query{Level: "error"}},
+		{"download_id:42", Query{DownloadID: 42}},
+		{"since:1h", Query{Since: now.Add(-time.Hour)}},
+		{"since:7d", Query{Since: now.AddDate(0, 0, -7)}},
+		{"since:2026-05-01 until:2026-05-02", Query{
+			Since: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC),
+			Until: time.Date(2026, 5, 3, 0, 0, 0, 0, time.UTC),
+		}},
+		{"until:2026-05-01T08:30:00Z", Query{Until: time.Date(2026, 5, 1, 8, 30, 0, 0, time.UTC)}},
+		{"url:*tiktok* chat_id:123", Query{Fields: []Field{{Key: "url", Value: "*tiktok*"}, {Key: "chat_id", Value: "123"}}}},
+		{`error.kind:"too large"`, Query{Fields: []Field{{Key: "error.kind", Value: "too large"}}}},
+		{"https://example.com/v", Query{Terms: []string{"https://example.com/v"}}},
+		{"a-b:c", Query{Terms: []string{"a-b:c"}}},
+		{
+			`level:warn url:*youtu* since:30m "rate limit" 429`,
+			Query{
+				Terms:  []string{"rate limit", "429"},
+				Level:  "warn",
+				Since:  now.Add(-30 * time.Minute),
+				Fields: []Field{{Key: "url", Value: "*youtu*"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in, now)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, in := range []string{
+		`"unterminated`,
+		"level:",
+		"url:",
+		"download_id:abc",
+		"since:yesterday",
+		"until:2026-13-01",
+	} {
+		if _, err := Parse(in, now); err == nil {
+			t.Errorf("Parse(%q) error = nil, want an error", in)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	entry := Entry{
+		Level:      "error",
+		Message:    "Upload failed",
+		Fields:     `{"url":"https://www.TikTok.com/v/1","chat_id":123,"retry":true,"error":{"kind":"too_large"}}`,
+		DownloadID: 7,
+		Time:       now.Add(-10 * time.Minute),
+	}
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"level:error", true},
+		{"level:warn", false},
+		{"download_id:7", true},
+		{"download_id:8", false},
+		{"since:1h", true},
+		{"since:5m", false},
+		{"until:5m", true},
+		{"until:1h", false},
+		{"upload", true},
+		{`"UPLOAD FAILED"`, true},
+		{"tiktok", true},
+		{"youtube", false},
+		{"url:*tiktok*", true},
+		{"url:*youtube*", false},
+		{"url:https://www.TikTok.com/v/1", true},
+		{"url:https://www.tiktok.com/v/1", false},
+		{"chat_id:123", true},
+		{"chat_id:12", false},
+		{"retry:true", true},
+		{"error.kind:too_large", true},
+		{"error.kind:*large", true},
+		{"error.missing:x", false},
+		{"missing:x", false},
+		{"level:error url:*tiktok* upload since:1h", true},
+		{"level:error url:*tiktok* download since:1h", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query, now)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.query, err)
+			}
+			if got := q.Match(entry); got != tt.want {
+				t.Errorf("Parse(%q).Match() = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchInvalidFields(t *testing.T) {
+	q, err := Parse("url:*", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Match(Entry{Fields: "not json"}) {
+		t.Error("Match() = true for a line with invalid fields, want false")
+	}
+}
@@ -0,0 +1,72 @@
+package postprocess
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Options holds the config values stages are built with.
+type Options struct {
+	Encoder        string
+	Threads        int
+	Profile        Profile
+	GIFMaxDuration time.Duration
+}
+
+// Names lists the stage names accepted by Parse, in a sensible pipeline order.
+var Names = []string{"remux", "transcode", "loudnorm", "watermark", "strip-metadata", "gif"}
+
+// Parse builds stages from specs such as "transcode" or
+// "watermark:/data/logo.png", in the order given.
+func Parse(specs []string, opts Options) ([]Stage, error) {
+	stages := make([]Stage, 0, len(specs))
+	for _, spec := range specs {
+		name, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		if name != "watermark" && arg != "" {
+			return nil, fmt.Errorf("stage %q takes no argument", name)
+		}
+
+		switch name {
+		case "remux":
+			stages = append(stages, Remux{})
+		case "transcode":
+			if err := checkCodec(opts.Profile.Codec); err != nil {
+				return nil, err
+			}
+			stages = append(stages, Transcode{Encoder: opts.Encoder, Threads: opts.Threads, Profile: opts.Profile})
+		case "loudnorm":
+			stages = append(stages, Loudnorm{})
+		case "watermark":
+			if arg == "" {
+				return nil, fmt.Errorf("stage %q requires an image path, e.g. watermark:/data/logo.png", name)
+			}
+			if err := checkCodec(opts.Profile.Codec); err != nil {
+				return nil, err
+			}
+			stages = append(stages, Watermark{Image: arg, Encoder: opts.Encoder, Threads: opts.Threads, Profile: opts.Profile})
+		case "strip-metadata":
+			stages = append(stages, StripMetadata{})
+		case "gif":
+			stages = append(stages, GIF{MaxDuration: opts.GIFMaxDuration})
+		default:
+			return nil, fmt.Errorf("unknown stage %q (available: %s)", name, strings.Join(Names, ", "))
+		}
+	}
+	return stages, nil
+}
+
+// checkCodec reports an error if codec is set but not one of Codecs.
+func checkCodec(codec string) error {
+	if codec != "" && !slices.Contains(Codecs, codec) {
+		return fmt.Errorf("unknown codec %q (available: %s)", codec, strings.Join(Codecs, ", "))
+	}
+	return nil
+}
+
+// Validate reports whether specs can be parsed.
+func Validate(specs []string) error {
+	_, err := Parse(specs, Options{})
+	return err
+}
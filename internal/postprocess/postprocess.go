@@ -0,0 +1,139 @@
+package postprocess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/logger"
+	"github.com/baranovskis/go-ytdlp-bot/internal/proclimit"
+	"github.com/rs/zerolog"
+)
+
+// killWaitDelay bounds how long Run waits for ffmpeg's pipes to close after
+// it has been killed.
+const killWaitDelay = 5 * time.Second
+
+// Media describes the downloaded file as reported by yt-dlp.
+type Media struct {
+	Duration float64
+	HasAudio bool
+}
+
+// IsAnimation reports whether m is a clip without audio no longer than
+// maxDuration, which Telegram should show as a looping animation.
+func IsAnimation(m Media, maxDuration time.Duration) bool {
+	return !m.HasAudio && m.Duration > 0 && time.Duration(m.Duration*float64(time.Second)) <= maxDuration
+}
+
+// Stage is a single ffmpeg step of a pipeline.
+type Stage interface {
+	// Name identifies the stage in config and logs.
+	Name() string
+	// Ext is the extension of the stage output, or "" to keep the input's.
+	Ext() string
+	// Args returns the ffmpeg arguments that read in and write out.
+	Args(in, out string) []string
+}
+
+// Conditional is implemented by stages that only apply to some media.
+type Conditional interface {
+	Applies(m Media) bool
+}
+
+// Pipeline runs stages one after another on a downloaded file.
+type Pipeline struct {
+	FFmpeg string
+	Stages []Stage
+	Limits proclimit.Limits
+	Logger zerolog.Logger
+}
+
+// Run applies the stages to input and returns the path of the result. The
+// input and intermediate files are removed once the pipeline succeeds, so
+// input must not be shared with another pipeline; outputs are named after it.
+// With no applicable stages, input is returned unchanged. It logs through
+// the logger attached to ctx, falling back to p.Logger.
+func (p *Pipeline) Run(ctx context.Context, input string, m Media) (string, error) {
+	log := logger.Ctx(ctx, &p.Logger)
+	ext := filepath.Ext(input)
+	base := strings.TrimSuffix(input, ext)
+
+	current := input
+	var intermediates []string
+	for _, stage := range p.Stages {
+		if c, ok := stage.(Conditional); ok && !c.Applies(m) {
+			log.Debug().Str("stage", stage.Name()).Msg("post-processing stage skipped")
+			continue
+		}
+
+		if stage.Ext() != "" {
+			ext = stage.Ext()
+		}
+		out := base + "." + stage.Name() + ext
+
+		start := time.Now()
+		if err := p.run(ctx, stage.Args(current, out)); err != nil {
+			os.Remove(out)
+			removeAll(intermediates)
+			return "", fmt.Errorf("%s: %w", stage.Name(), err)
+		}
+
+		log.Debug().
+			Str("stage", stage.Name()).
+			Str("file", filepath.Base(out)).
+			Dur("took", time.Since(start)).
+			Msg("post-processing stage finished")
+
+		intermediates = append(intermediates, out)
+		current = out
+	}
+
+	if current == input {
+		return input, nil
+	}
+
+	final := base + ".processed" + ext
+	if err := os.Rename(current, final); err != nil {
+		removeAll(intermediates)
+		return "", err
+	}
+	removeAll(intermediates[:len(intermediates)-1])
+	os.Remove(input)
+
+	return final, nil
+}
+
+func (p *Pipeline) run(ctx context.Context, args []string) error {
+	args = append([]string{"-hide_banner", "-nostdin", "-y", "-loglevel", "error"}, args...)
+	cmd := exec.CommandContext(ctx, p.FFmpeg, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = killWaitDelay
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := p.Limits.Apply(cmd.Process.Pid); err != nil {
+		logger.Ctx(ctx, &p.Logger).Warn().Str("reason", err.Error()).Msg("failed apply ffmpeg process limits")
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return fmt.Errorf("%w\n\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func removeAll(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,199 @@
+package postprocess
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Remux copies the streams into an MP4 container with the index up front.
+type Remux struct{}
+
+func (Remux) Name() string { return "remux" }
+func (Remux) Ext() string  { return ".mp4" }
+
+func (Remux) Args(in, out string) []string {
+	return []string{"-i", in, "-map", "0", "-c", "copy", "-movflags", "+faststart", out}
+}
+
+// Profile tunes the output of Transcode. Zero values keep the encoder's
+// defaults and apply no cap.
+type Profile struct {
+	Codec        string
+	CRF          int
+	MaxBitrate   int
+	AudioBitrate int
+	MaxFPS       int
+	MaxHeight    int
+}
+
+// Codecs lists the values accepted for Profile.Codec.
+var Codecs = []string{"h264", "hevc", "av1"}
+
+// Transcode re-encodes to MP4 with AAC audio for universal playback, using
+// the configured hardware or CPU encoder for the profile's codec.
+type Transcode struct {
+	Encoder string
+	Threads int
+	Profile Profile
+}
+
+func (Transcode) Name() string { return "transcode" }
+func (Transcode) Ext() string  { return ".mp4" }
+
+func (t Transcode) Args(in, out string) []string {
+	p := t.Profile
+	enc := encodingFor(encoderFor(t.Encoder, p.Codec), t.Threads, p)
+
+	var filters []string
+	if p.MaxHeight > 0 {
+		filters = append(filters, fmt.Sprintf("scale=-2:'min(ih,%d)'", p.MaxHeight))
+	}
+
+	args := append(enc.input, "-i", in)
+	args = append(args, vf(append(filters, enc.filters...))...)
+	args = append(args, enc.codec...)
+
+	args = append(args, "-c:a", "aac")
+	if p.AudioBitrate > 0 {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", p.AudioBitrate))
+	}
+	return append(args, "-movflags", "+faststart", out)
+}
+
+// videoEncoding holds the ffmpeg arguments that encode video with one
+// encoder, split by where they go on the command line.
+type videoEncoding struct {
+	input   []string // before the first -i
+	filters []string // after the stage's own video filters
+	codec   []string // among the output options
+}
+
+// encodingFor returns the arguments for encoding video with encoder, tuned
+// by the profile's quality, bitrate and frame rate caps.
+func encodingFor(encoder string, threads int, p Profile) videoEncoding {
+	var enc videoEncoding
+	switch {
+	case strings.HasSuffix(encoder, "_vaapi"):
+		enc.input = []string{"-vaapi_device", "/dev/dri/renderD128"}
+		enc.filters = []string{"format=nv12", "hwupload"}
+		enc.codec = []string{"-c:v", encoder, "-global_quality", strconv.Itoa(crfOr(p, 23))}
+	case strings.HasSuffix(encoder, "_nvenc"):
+		enc.codec = []string{"-c:v", encoder, "-preset", "p4", "-cq", strconv.Itoa(crfOr(p, 23)), "-pix_fmt", "yuv420p"}
+	case strings.HasSuffix(encoder, "_qsv"):
+		enc.codec = []string{"-c:v", encoder, "-preset", "fast", "-global_quality", strconv.Itoa(crfOr(p, 23))}
+	case encoder == "libsvtav1":
+		enc.codec = []string{"-threads", strconv.Itoa(threads),
+			"-c:v", encoder, "-preset", "8", "-crf", strconv.Itoa(crfOr(p, 35)), "-pix_fmt", "yuv420p"}
+	case encoder == "libx265":
+		enc.codec = []string{"-threads", strconv.Itoa(threads),
+			"-c:v", encoder, "-preset", "fast", "-crf", strconv.Itoa(crfOr(p, 28)), "-pix_fmt", "yuv420p"}
+	default:
+		enc.codec = []string{"-threads", strconv.Itoa(threads),
+			"-c:v", encoder, "-preset", "fast", "-crf", strconv.Itoa(crfOr(p, 23)), "-pix_fmt", "yuv420p"}
+	}
+
+	if p.Codec == "hevc" {
+		// Apple players only recognise HEVC in MP4 with the hvc1 tag.
+		enc.codec = append(enc.codec, "-tag:v", "hvc1")
+	}
+	if p.MaxBitrate > 0 {
+		enc.codec = append(enc.codec, "-maxrate", fmt.Sprintf("%dk", p.MaxBitrate), "-bufsize", fmt.Sprintf("%dk", 2*p.MaxBitrate))
+	}
+	if p.MaxFPS > 0 {
+		enc.codec = append(enc.codec, "-fpsmax", strconv.Itoa(p.MaxFPS))
+	}
+	return enc
+}
+
+// encoderFor maps the configured H.264 encoder to the one for codec on the
+// same backend, e.g. h264_nvenc to hevc_nvenc or libx264 to libx265.
+func encoderFor(encoder, codec string) string {
+	if codec == "" || codec == "h264" {
+		return encoder
+	}
+	if _, backend, ok := strings.Cut(encoder, "_"); ok {
+		return codec + "_" + backend
+	}
+	if codec == "hevc" {
+		return "libx265"
+	}
+	return "libsvtav1"
+}
+
+func crfOr(p Profile, def int) int {
+	if p.CRF > 0 {
+		return p.CRF
+	}
+	return def
+}
+
+func vf(filters []string) []string {
+	if len(filters) == 0 {
+		return nil
+	}
+	return []string{"-vf", strings.Join(filters, ",")}
+}
+
+// Loudnorm normalises audio loudness to -16 LUFS, leaving video untouched.
+type Loudnorm struct{}
+
+func (Loudnorm) Name() string { return "loudnorm" }
+func (Loudnorm) Ext() string  { return "" }
+
+func (Loudnorm) Args(in, out string) []string {
+	return []string{"-i", in, "-c:v", "copy", "-af", "loudnorm=I=-16:TP=-1.5:LRA=11", "-c:a", "aac", out}
+}
+
+func (Loudnorm) Applies(m Media) bool { return m.HasAudio }
+
+// Watermark overlays an image in the bottom-right corner, re-encoding the
+// video the same way as Transcode.
+type Watermark struct {
+	Image   string
+	Encoder string
+	Threads int
+	Profile Profile
+}
+
+func (Watermark) Name() string { return "watermark" }
+func (Watermark) Ext() string  { return ".mp4" }
+
+func (w Watermark) Args(in, out string) []string {
+	enc := encodingFor(encoderFor(w.Encoder, w.Profile.Codec), w.Threads, w.Profile)
+	filter := strings.Join(append([]string{"[0:v][1:v]overlay=W-w-10:H-h-10"}, enc.filters...), ",")
+
+	args := append(enc.input, "-i", in, "-i", w.Image, "-filter_complex", filter)
+	args = append(args, enc.codec...)
+	return append(args, "-c:a", "copy", "-movflags", "+faststart", out)
+}
+
+// StripMetadata drops container and stream metadata such as titles,
+// encoder tags and location.
+type StripMetadata struct{}
+
+func (StripMetadata) Name() string { return "strip-metadata" }
+func (StripMetadata) Ext() string  { return "" }
+
+func (StripMetadata) Args(in, out string) []string {
+	return []string{"-i", in, "-map", "0", "-map_metadata", "-1", "-map_chapters", "-1", "-c", "copy", out}
+}
+
+// GIF converts short clips without audio into an animated GIF.
+type GIF struct {
+	MaxDuration time.Duration
+}
+
+func (GIF) Name() string { return "gif" }
+func (GIF) Ext() string  { return ".gif" }
+
+func (GIF) Args(in, out string) []string {
+	return []string{"-i", in,
+		"-vf", "fps=12,scale=480:-1:flags=lanczos,split[a][b];[a]palettegen[p];[b][p]paletteuse",
+		"-loop", "0", out}
+}
+
+func (g GIF) Applies(m Media) bool {
+	return IsAnimation(m, g.MaxDuration)
+}
@@ -0,0 +1,131 @@
+package postprocess
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStageArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		stage Stage
+		want  string
+	}{
+		{
+			name:  "remux",
+			stage: Remux{},
+			want:  "-i in.webm -map 0 -c copy -movflags +faststart out.mp4",
+		},
+		{
+			name:  "transcode default",
+			stage: Transcode{Encoder: "libx264", Threads: 2},
+			want:  "-i in.webm -threads 2 -c:v libx264 -preset fast -crf 23 -pix_fmt yuv420p -c:a aac -movflags +faststart out.mp4",
+		},
+		{
+			name:  "transcode profile",
+			stage: Transcode{Encoder: "libx264", Threads: 2, Profile: Profile{CRF: 30, MaxHeight: 720, MaxBitrate: 2000, MaxFPS: 30, AudioBitrate: 96}},
+			want: "-i in.webm -vf scale=-2:'min(ih,720)' -threads 2 -c:v libx264 -preset fast -crf 30 -pix_fmt yuv420p " +
+				"-maxrate 2000k -bufsize 4000k -fpsmax 30 -c:a aac -b:a 96k -movflags +faststart out.mp4",
+		},
+		{
+			name:  "transcode hevc",
+			stage: Transcode{Encoder: "libx264", Threads: 2, Profile: Profile{Codec: "hevc"}},
+			want:  "-i in.webm -threads 2 -c:v libx265 -preset fast -crf 28 -pix_fmt yuv420p -tag:v hvc1 -c:a aac -movflags +faststart out.mp4",
+		},
+		{
+			name:  "transcode av1 nvenc",
+			stage: Transcode{Encoder: "h264_nvenc", Profile: Profile{Codec: "av1"}},
+			want:  "-i in.webm -c:v av1_nvenc -preset p4 -cq 23 -pix_fmt yuv420p -c:a aac -movflags +faststart out.mp4",
+		},
+		{
+			name:  "transcode vaapi",
+			stage: Transcode{Encoder: "h264_vaapi", Profile: Profile{MaxHeight: 480}},
+			want: "-vaapi_device /dev/dri/renderD128 -i in.webm -vf scale=-2:'min(ih,480)',format=nv12,hwupload " +
+				"-c:v h264_vaapi -global_quality 23 -c:a aac -movflags +faststart out.mp4",
+		},
+		{
+			name:  "watermark default",
+			stage: Watermark{Image: "logo.png", Encoder: "libx264", Threads: 2},
+			want: "-i in.webm -i logo.png -filter_complex [0:v][1:v]overlay=W-w-10:H-h-10 " +
+				"-threads 2 -c:v libx264 -preset fast -crf 23 -pix_fmt yuv420p -c:a copy -movflags +faststart out.mp4",
+		},
+		{
+			name:  "watermark profile",
+			stage: Watermark{Image: "logo.png", Encoder: "h264_qsv", Profile: Profile{Codec: "hevc", CRF: 26}},
+			want: "-i in.webm -i logo.png -filter_complex [0:v][1:v]overlay=W-w-10:H-h-10 " +
+				"-c:v hevc_qsv -preset fast -global_quality 26 -tag:v hvc1 -c:a copy -movflags +faststart out.mp4",
+		},
+		{
+			name:  "watermark vaapi",
+			stage: Watermark{Image: "logo.png", Encoder: "h264_vaapi"},
+			want: "-vaapi_device /dev/dri/renderD128 -i in.webm -i logo.png -filter_complex [0:v][1:v]overlay=W-w-10:H-h-10,format=nv12,hwupload " +
+				"-c:v h264_vaapi -global_quality 23 -c:a copy -movflags +faststart out.mp4",
+		},
+		{
+			name:  "loudnorm",
+			stage: Loudnorm{},
+			want:  "-i in.webm -c:v copy -af loudnorm=I=-16:TP=-1.5:LRA=11 -c:a aac out.mp4",
+		},
+		{
+			name:  "strip metadata",
+			stage: StripMetadata{},
+			want:  "-i in.webm -map 0 -map_metadata -1 -map_chapters -1 -c copy out.mp4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.stage.Args("in.webm", "out.mp4")
+			if want := strings.Fields(tt.want); !slices.Equal(got, want) {
+				t.Errorf("Args() =\n%q\nwant\n%q", got, want)
+			}
+		})
+	}
+}
+
+func TestEncoderFor(t *testing.T) {
+	tests := []struct {
+		encoder, codec, want string
+	}{
+		{"libx264", "", "libx264"},
+		{"libx264", "h264", "libx264"},
+		{"libx264", "hevc", "libx265"},
+		{"libx264", "av1", "libsvtav1"},
+		{"h264_nvenc", "hevc", "hevc_nvenc"},
+		{"h264_vaapi", "av1", "av1_vaapi"},
+		{"h264_qsv", "h264", "h264_qsv"},
+	}
+
+	for _, tt := range tests {
+		if got := encoderFor(tt.encoder, tt.codec); got != tt.want {
+			t.Errorf("encoderFor(%q, %q) = %q, want %q", tt.encoder, tt.codec, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	opts := Options{Encoder: "h264_nvenc", Threads: 4, Profile: Profile{CRF: 20}}
+
+	stages, err := Parse([]string{"remux", "watermark:logo.png"}, opts)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := Watermark{Image: "logo.png", Encoder: "h264_nvenc", Threads: 4, Profile: Profile{CRF: 20}}
+	if len(stages) != 2 || stages[1] != want {
+		t.Errorf("Parse() = %#v, want remux and %#v", stages, want)
+	}
+
+	for _, in := range []string{"watermark", "watermark:", "remux:x", "unknown"} {
+		if _, err := Parse([]string{in}, opts); err == nil {
+			t.Errorf("Parse(%q) error = nil, want an error", in)
+		}
+	}
+
+	opts.Profile.Codec = "vp9"
+	for _, in := range []string{"transcode", "watermark:logo.png"} {
+		if _, err := Parse([]string{in}, opts); err == nil {
+			t.Errorf("Parse(%q) with codec vp9 error = nil, want an error", in)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Package proclimit lowers the priority and caps the memory of child
+// processes such as yt-dlp and ffmpeg, so heavy jobs don't starve the bot.
+package proclimit
+
+import "fmt"
+
+// IOClasses lists the values accepted for Limits.IOClass.
+var IOClasses = []string{"best-effort", "idle"}
+
+// Limits are applied to a child process right after it starts; processes it
+// spawns later inherit them. Zero values leave the defaults.
+type Limits struct {
+	// Nice is the CPU niceness, 1 (slightly lower priority) to 19 (lowest).
+	Nice int
+	// IOClass is the disk I/O scheduling class: "best-effort" at its lowest
+	// priority, or "idle" to only use the disk when nothing else does.
+	IOClass string
+	// MemoryBytes caps the address space of the process.
+	MemoryBytes int64
+}
+
+// IsZero reports whether l changes nothing.
+func (l Limits) IsZero() bool {
+	return l.Nice == 0 && l.IOClass == "" && l.MemoryBytes == 0
+}
+
+func (l Limits) validate() error {
+	if l.Nice < 0 || l.Nice > 19 {
+		return fmt.Errorf("nice %d out of range 0-19", l.Nice)
+	}
+	switch l.IOClass {
+	case "", "best-effort", "idle":
+	default:
+		return fmt.Errorf("unknown I/O class %q", l.IOClass)
+	}
+	return nil
+}
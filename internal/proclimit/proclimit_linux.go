@@ -0,0 +1,52 @@
+//go:build linux
+
+package proclimit
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// I/O priority encoding from linux/ioprio.h.
+const (
+	ioprioWhoProcess    = 1
+	ioprioClassShift    = 13
+	ioprioClassBE       = 2
+	ioprioClassIdle     = 3
+	ioprioLowestBELevel = 7
+)
+
+// Apply sets the limits on the running process pid.
+func (l Limits) Apply(pid int) error {
+	if err := l.validate(); err != nil {
+		return err
+	}
+
+	var errs []error
+	if l.Nice > 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, l.Nice); err != nil {
+			errs = append(errs, fmt.Errorf("set nice: %w", err))
+		}
+	}
+
+	if l.IOClass != "" {
+		prio := ioprioClassIdle << ioprioClassShift
+		if l.IOClass == "best-effort" {
+			prio = ioprioClassBE<<ioprioClassShift | ioprioLowestBELevel
+		}
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+			errs = append(errs, fmt.Errorf("set I/O priority: %w", errno))
+		}
+	}
+
+	if l.MemoryBytes > 0 {
+		limit := &unix.Rlimit{Cur: uint64(l.MemoryBytes), Max: uint64(l.MemoryBytes)}
+		if err := unix.Prlimit(pid, unix.RLIMIT_AS, limit, nil); err != nil {
+			errs = append(errs, fmt.Errorf("set memory limit: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package proclimit
+
+import "errors"
+
+// Apply is only supported on Linux; elsewhere any limit reports an error
+// and the process runs unrestricted.
+func (l Limits) Apply(_ int) error {
+	if l.IsZero() {
+		return nil
+	}
+	return errors.New("process limits are only supported on Linux")
+}
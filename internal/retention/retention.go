@@ -0,0 +1,201 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/database"
+	"github.com/rs/zerolog"
+)
+
+// Status is a snapshot of the pruner state for display.
+type Status struct {
+	Running          bool
+	LastRun          time.Time
+	LastDuration     time.Duration
+	LogsDeleted      int64
+	DownloadsDeleted int64
+	LastError        string
+	NextRunAt        time.Time
+}
+
+// Rule is a configured retention rule, named by what it applies to.
+type Rule struct {
+	Table   string
+	Level   string // log level, or empty for every line
+	MaxAge  time.Duration
+	MaxRows int
+}
+
+// Pruner deletes log lines and downloads that are past their retention
+// rules, on a schedule and on demand.
+type Pruner struct {
+	db     *database.DB
+	cfg    config.Retention
+	logger zerolog.Logger
+
+	mu       sync.Mutex
+	status   Status
+	lifetime context.Context // from Run; ends at shutdown
+	running  sync.WaitGroup
+}
+
+// New creates a pruner for the rules in cfg.
+func New(db *database.DB, cfg config.Retention, log zerolog.Logger) *Pruner {
+	return &Pruner{
+		db:     db,
+		cfg:    cfg,
+		logger: log,
+	}
+}
+
+// Run prunes once and then on the configured interval until ctx is
+// cancelled. It returns at once when no rule is configured.
+func (p *Pruner) Run(ctx context.Context) {
+	p.mu.Lock()
+	p.lifetime = ctx
+	p.mu.Unlock()
+
+	if !p.cfg.Enabled() {
+		return
+	}
+
+	interval := p.cfg.GetInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.Prune(ctx)
+		p.setNextRun(time.Now().Add(interval))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Config returns the retention settings the pruner was created with.
+func (p *Pruner) Config() config.Retention {
+	return p.cfg
+}
+
+// Rules returns the configured rules: per-level log rules sorted by level,
+// then the rules for all logs and for downloads.
+func (p *Pruner) Rules() []Rule {
+	var rules []Rule
+	for _, level := range slices.Sorted(maps.Keys(p.cfg.LogLevels)) {
+		if r := p.cfg.LogLevels[level]; r.Enabled() {
+			rules = append(rules, Rule{Table: "logs", Level: level, MaxAge: r.GetMaxAge(), MaxRows: r.GetMaxRows()})
+		}
+	}
+	if r := p.cfg.Logs; r.Enabled() {
+		rules = append(rules, Rule{Table: "logs", MaxAge: r.GetMaxAge(), MaxRows: r.GetMaxRows()})
+	}
+	if r := p.cfg.Downloads; r.Enabled() {
+		rules = append(rules, Rule{Table: "downloads", MaxAge: r.GetMaxAge(), MaxRows: r.GetMaxRows()})
+	}
+	return rules
+}
+
+// Status returns the current pruner state.
+func (p *Pruner) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Trigger starts pruning in the background unless it is already running.
+// Pruning stops at shutdown rather than with the caller's request.
+func (p *Pruner) Trigger() bool {
+	p.mu.Lock()
+	if p.status.Running {
+		p.mu.Unlock()
+		return false
+	}
+	ctx := p.lifetime
+	p.mu.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go p.Prune(ctx)
+	return true
+}
+
+// Wait blocks until a running prune has finished its current batch.
+func (p *Pruner) Wait() {
+	p.running.Wait()
+}
+
+// Prune applies every rule once and returns the errors it met, joined.
+func (p *Pruner) Prune(ctx context.Context) error {
+	p.mu.Lock()
+	if p.status.Running {
+		p.mu.Unlock()
+		return errors.New("pruning already in progress")
+	}
+	p.status.Running = true
+	p.running.Add(1)
+	p.mu.Unlock()
+	defer p.running.Done()
+
+	start := time.Now()
+	var logs, downloads int64
+	var errs []error
+	for _, rule := range p.Rules() {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		var cutoff time.Time
+		if rule.MaxAge > 0 {
+			cutoff = start.Add(-rule.MaxAge)
+		}
+		var n int64
+		var err error
+		if rule.Table == "downloads" {
+			n, err = p.db.PruneDownloads(cutoff, rule.MaxRows)
+			downloads += n
+		} else {
+			n, err = p.db.PruneLogs(rule.Level, cutoff, rule.MaxRows)
+			logs += n
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
+
+	if err != nil {
+		p.logger.Error().Str("reason", err.Error()).Msg("failed prune history")
+	} else if logs > 0 || downloads > 0 {
+		p.logger.Info().Int64("logs", logs).Int64("downloads", downloads).Msg("pruned history")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.Running = false
+	p.status.LastRun = start
+	p.status.LastDuration = time.Since(start)
+	p.status.LogsDeleted = logs
+	p.status.DownloadsDeleted = downloads
+	p.status.LastError = ""
+	if err != nil {
+		p.status.LastError = err.Error()
+	}
+	return err
+}
+
+func (p *Pruner) setNextRun(t time.Time) {
+	p.mu.Lock()
+	p.status.NextRunAt = t
+	p.mu.Unlock()
+}
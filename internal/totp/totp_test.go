@@ -0,0 +1,116 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 test key of RFC 6238, "12345678901234567890",
+// base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 checks the SHA-1 test vectors of RFC 6238, appendix B.
+// The RFC lists 8-digit codes; 6-digit codes are their last six digits.
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeLowercaseSecret(t *testing.T) {
+	got, err := Code(strings.ToLower(rfcSecret), 1)
+	if err != nil || got != "287082" {
+		t.Errorf("Code() = %s, %v, want 287082", got, err)
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() error = nil, want an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0) // step 37037037, code 050471
+	step := Counter(at)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", "050471", step, true},
+		{"spaces", " 050 471 ", step, true},
+		{"previous step", "081804", step - 1, true},
+		{"wrong code", "123456", 0, false},
+		{"too short", "05047", 0, false},
+		{"too long", "0504710", 0, false},
+		{"empty", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := Validate(rfcSecret, tt.code, at)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %v, want %d, %v", tt.code, gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	step := Counter(at)
+
+	for offset := int64(-Skew - 1); offset <= Skew+1; offset++ {
+		code, err := Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := offset >= -Skew && offset <= Skew
+		if _, ok := Validate(rfcSecret, code, at); ok != want {
+			t.Errorf("Validate() of the code %d steps away = %v, want %v", offset, ok, want)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("GenerateSecret() = %q, want 32 base32 characters", secret)
+	}
+	if _, err := Code(secret, 1); err != nil {
+		t.Errorf("Code() with a generated secret: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("ytdlp bot", "alice", rfcSecret)
+	want := "otpauth://totp/ytdlp%20bot:alice?algorithm=SHA1&digits=6&issuer=ytdlp+bot&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("URI() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,278 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/lrstanley/go-ytdlp"
+	"github.com/rs/zerolog"
+)
+
+const (
+	updateTimeout = 5 * time.Minute
+	smokeTimeout  = 2 * time.Minute
+)
+
+// Status is a snapshot of the updater state for display.
+type Status struct {
+	Executable  string
+	Version     string
+	Target      string
+	Updating    bool
+	LastCheck   time.Time
+	LastResult  string
+	LastError   string
+	RolledBack  bool
+	NextCheckAt time.Time
+}
+
+// Updater keeps the yt-dlp binary current. Each update backs up the binary,
+// runs yt-dlp --update-to, smoke tests the result and restores the backup on failure.
+type Updater struct {
+	executable string
+	cfg        config.YtDlp
+	logger     zerolog.Logger
+
+	mu       sync.Mutex
+	status   Status
+	lifetime context.Context // from Run; ends at shutdown
+	running  sync.WaitGroup
+}
+
+// New creates an updater for the yt-dlp executable at path.
+func New(executable string, cfg config.YtDlp, log zerolog.Logger) *Updater {
+	u := &Updater{
+		executable: executable,
+		cfg:        cfg,
+		logger:     log,
+	}
+	u.status.Executable = executable
+	u.status.Target = cfg.GetUpdateTarget()
+	return u
+}
+
+// Run refreshes the installed version and, if an interval is configured,
+// updates yt-dlp on that schedule until ctx is cancelled. Updates started
+// with Trigger also end with ctx.
+func (u *Updater) Run(ctx context.Context) {
+	u.mu.Lock()
+	u.lifetime = ctx
+	u.mu.Unlock()
+
+	if version, err := u.installedVersion(ctx); err != nil {
+		u.logger.Error().Str("reason", err.Error()).Msg("failed get yt-dlp version")
+	} else {
+		u.mu.Lock()
+		u.status.Version = version
+		u.mu.Unlock()
+		u.logger.Info().Str("version", version).Str("path", u.executable).Msg("yt-dlp installed")
+	}
+
+	interval := u.cfg.GetUpdateInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	u.setNextCheck(time.Now().Add(interval))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.Update(ctx)
+			u.setNextCheck(time.Now().Add(interval))
+		}
+	}
+}
+
+// Status returns the current updater state.
+func (u *Updater) Status() Status {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.status
+}
+
+// Trigger starts an update in the background unless one is already running.
+// The update is cancelled at shutdown rather than with the caller's request;
+// a cancelled update still rolls back.
+func (u *Updater) Trigger() bool {
+	u.mu.Lock()
+	if u.status.Updating {
+		u.mu.Unlock()
+		return false
+	}
+	ctx := u.lifetime
+	u.mu.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	go u.Update(ctx)
+	return true
+}
+
+// Wait blocks until a running update, including its rollback, has finished.
+func (u *Updater) Wait() {
+	u.running.Wait()
+}
+
+// Update runs a single update cycle and returns the resulting error, if any.
+func (u *Updater) Update(ctx context.Context) error {
+	u.mu.Lock()
+	if u.status.Updating {
+		u.mu.Unlock()
+		return errors.New("update already in progress")
+	}
+	u.status.Updating = true
+	u.running.Add(1)
+	u.mu.Unlock()
+	defer u.running.Done()
+
+	result, rolledBack, err := u.update(ctx)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status.Updating = false
+	u.status.LastCheck = time.Now()
+	u.status.LastResult = result
+	u.status.RolledBack = rolledBack
+	u.status.LastError = ""
+	if err != nil {
+		u.status.LastError = err.Error()
+	}
+	if version, verr := u.installedVersion(ctx); verr == nil {
+		u.status.Version = version
+	}
+
+	return err
+}
+
+func (u *Updater) update(ctx context.Context) (string, bool, error) {
+	target := u.cfg.GetUpdateTarget()
+	before, err := u.installedVersion(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("get current version: %w", err)
+	}
+
+	backup := u.executable + ".bak"
+	if err := copyFile(u.executable, backup); err != nil {
+		return "", false, fmt.Errorf("backup yt-dlp: %w", err)
+	}
+
+	u.logger.Info().
+		Str("version", before).
+		Str("target", target).
+		Msg("updating yt-dlp")
+
+	updateCtx, cancel := context.WithTimeout(ctx, updateTimeout)
+	_, updateErr := ytdlp.New().SetExecutable(u.executable).UpdateTo(updateCtx, target)
+	cancel()
+
+	if updateErr == nil {
+		updateErr = u.smokeTest(ctx)
+	}
+
+	if updateErr != nil {
+		u.logger.Error().
+			Str("target", target).
+			Str("reason", updateErr.Error()).
+			Msg("yt-dlp update failed, rolling back")
+		if err := copyFile(backup, u.executable); err != nil {
+			return "", false, fmt.Errorf("update failed (%v) and rollback failed: %w", updateErr, err)
+		}
+		return fmt.Sprintf("rolled back to %s", before), true, updateErr
+	}
+
+	after, _ := u.installedVersion(ctx)
+	if after == before {
+		u.logger.Info().Str("version", after).Msg("yt-dlp is up to date")
+		return fmt.Sprintf("%s is up to date", after), false, nil
+	}
+
+	u.logger.Info().
+		Str("from", before).
+		Str("to", after).
+		Msg("yt-dlp updated")
+	return fmt.Sprintf("updated %s → %s", before, after), false, nil
+}
+
+// smokeTest checks the binary still runs, and if configured, can extract a known URL.
+func (u *Updater) smokeTest(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, smokeTimeout)
+	defer cancel()
+
+	if _, err := u.installedVersion(ctx); err != nil {
+		return fmt.Errorf("smoke test: %w", err)
+	}
+
+	if u.cfg.SmokeTestURL == "" {
+		return nil
+	}
+
+	_, err := ytdlp.New().
+		SetExecutable(u.executable).
+		Simulate().
+		Quiet().
+		Run(ctx, u.cfg.SmokeTestURL)
+	if err != nil {
+		return fmt.Errorf("smoke test %s: %w", u.cfg.SmokeTestURL, err)
+	}
+	return nil
+}
+
+func (u *Updater) installedVersion(ctx context.Context) (string, error) {
+	r, err := ytdlp.New().SetExecutable(u.executable).Version(ctx)
+	if err != nil {
+		return "", err
+	}
+	version := strings.TrimSpace(r.Stdout)
+	if version == "" {
+		return "", errors.New("yt-dlp --version returned no output")
+	}
+	return version, nil
+}
+
+func (u *Updater) setNextCheck(t time.Time) {
+	u.mu.Lock()
+	u.status.NextCheckAt = t
+	u.mu.Unlock()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
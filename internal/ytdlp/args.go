@@ -0,0 +1,168 @@
+package ytdlp
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lrstanley/go-ytdlp"
+)
+
+// extraArg describes a yt-dlp flag that may be passed through from a filter.
+type extraArg struct {
+	hasValue bool
+	apply    func(c *ytdlp.Command, value string) error
+}
+
+// allowedExtraArgs is the allow-list of yt-dlp flags that can be set per filter.
+// Anything touching output paths, exec hooks or config files is deliberately absent.
+var allowedExtraArgs = map[string]extraArg{
+	"--force-ipv4": {apply: func(c *ytdlp.Command, _ string) error {
+		c.ForceIPv4()
+		return nil
+	}},
+	"--force-ipv6": {apply: func(c *ytdlp.Command, _ string) error {
+		c.ForceIPv6()
+		return nil
+	}},
+	"--no-check-certificates": {apply: func(c *ytdlp.Command, _ string) error {
+		c.NoCheckCertificates()
+		return nil
+	}},
+	"--legacy-server-connect": {apply: func(c *ytdlp.Command, _ string) error {
+		c.LegacyServerConnect()
+		return nil
+	}},
+	"--referer": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		c.AddHeaders("Referer:" + v)
+		return nil
+	}},
+	"--add-headers": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		if !strings.Contains(v, ":") {
+			return fmt.Errorf("expected FIELD:VALUE, got %q", v)
+		}
+		c.AddHeaders(v)
+		return nil
+	}},
+	"--extractor-args": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		c.ExtractorArgs(v)
+		return nil
+	}},
+	"--retries": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		if err := checkRetries(v); err != nil {
+			return err
+		}
+		c.Retries(v)
+		return nil
+	}},
+	"--fragment-retries": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		if err := checkRetries(v); err != nil {
+			return err
+		}
+		c.FragmentRetries(v)
+		return nil
+	}},
+	"--extractor-retries": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		if err := checkRetries(v); err != nil {
+			return err
+		}
+		c.ExtractorRetries(v)
+		return nil
+	}},
+	"--socket-timeout": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		n, err := parseSeconds(v)
+		if err != nil {
+			return err
+		}
+		c.SocketTimeout(n)
+		return nil
+	}},
+	"--sleep-requests": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		n, err := parseSeconds(v)
+		if err != nil {
+			return err
+		}
+		c.SleepRequests(n)
+		return nil
+	}},
+	"--sleep-interval": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		n, err := parseSeconds(v)
+		if err != nil {
+			return err
+		}
+		c.SleepInterval(n)
+		return nil
+	}},
+	"--max-sleep-interval": {hasValue: true, apply: func(c *ytdlp.Command, v string) error {
+		n, err := parseSeconds(v)
+		if err != nil {
+			return err
+		}
+		c.MaxSleepInterval(n)
+		return nil
+	}},
+}
+
+// checkRetries accepts a retry count: a non-negative integer or "infinite".
+func checkRetries(v string) error {
+	if v == "infinite" {
+		return nil
+	}
+	if n, err := strconv.Atoi(v); err != nil || n < 0 {
+		return fmt.Errorf("expected a number of retries or \"infinite\", got %q", v)
+	}
+	return nil
+}
+
+// parseSeconds reads a non-negative, finite number of seconds.
+func parseSeconds(v string) (float64, error) {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+		return 0, fmt.Errorf("expected a number of seconds, got %q", v)
+	}
+	return n, nil
+}
+
+// parseExtraArg splits a single "--flag value" line and looks it up in the allow-list.
+func parseExtraArg(line string) (string, string, extraArg, error) {
+	line = strings.TrimSpace(line)
+	flag, value := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		flag, value = line[:i], line[i+1:]
+	}
+	if eq := strings.Index(flag, "="); eq > 0 {
+		flag, value = flag[:eq], flag[eq+1:]
+	}
+	value = strings.TrimSpace(value)
+
+	arg, ok := allowedExtraArgs[flag]
+	if !ok {
+		return "", "", extraArg{}, fmt.Errorf("yt-dlp flag %q is not allowed", flag)
+	}
+	if arg.hasValue && value == "" {
+		return "", "", extraArg{}, fmt.Errorf("yt-dlp flag %q requires a value", flag)
+	}
+	if !arg.hasValue && value != "" {
+		return "", "", extraArg{}, fmt.Errorf("yt-dlp flag %q does not take a value", flag)
+	}
+	return flag, value, arg, nil
+}
+
+// ValidateExtraArgs checks that every line is an allow-listed yt-dlp flag
+// with a well-formed value. Each line holds one flag, e.g. "--retries 5".
+func ValidateExtraArgs(lines []string) error {
+	scratch := &YtDlp{Command: ytdlp.New()}
+	return scratch.ExtraArgs(lines)
+}
+
+// AllowedExtraArgs returns the sorted list of flags accepted by ValidateExtraArgs.
+func AllowedExtraArgs() []string {
+	flags := make([]string, 0, len(allowedExtraArgs))
+	for f := range allowedExtraArgs {
+		flags = append(flags, f)
+	}
+	slices.Sort(flags)
+	return flags
+}
@@ -0,0 +1,125 @@
+package ytdlp
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lrstanley/go-ytdlp"
+)
+
+func TestParseExtraArg(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantFlag  string
+		wantValue string
+		wantErr   string
+	}{
+		// Accepted flags, in both forms.
+		{line: "--force-ipv4", wantFlag: "--force-ipv4"},
+		{line: "  --no-check-certificates  ", wantFlag: "--no-check-certificates"},
+		{line: "--retries 5", wantFlag: "--retries", wantValue: "5"},
+		{line: "--retries=5", wantFlag: "--retries", wantValue: "5"},
+		{line: "--retries\t5", wantFlag: "--retries", wantValue: "5"},
+		{line: "--referer https://example.com/?a=b", wantFlag: "--referer", wantValue: "https://example.com/?a=b"},
+		{line: "--add-headers=X-Token:a=b", wantFlag: "--add-headers", wantValue: "X-Token:a=b"},
+		{line: "--extractor-args youtube:player_client=web", wantFlag: "--extractor-args", wantValue: "youtube:player_client=web"},
+
+		// Flags that touch files, run commands or load config are not on
+		// the list, in any form.
+		{line: "--exec rm -rf /", wantErr: "not allowed"},
+		{line: "--exec=id", wantErr: "not allowed"},
+		{line: "-o /etc/passwd", wantErr: "not allowed"},
+		{line: "--output /tmp/x", wantErr: "not allowed"},
+		{line: "--config-location /tmp/evil.conf", wantErr: "not allowed"},
+		{line: "--load-info-json /tmp/info.json", wantErr: "not allowed"},
+		{line: "--batch-file=/etc/hosts", wantErr: "not allowed"},
+		{line: "--cookies /tmp/cookies.txt", wantErr: "not allowed"},
+		{line: "--FORCE-IPV4", wantErr: "not allowed"},
+		{line: "force-ipv4", wantErr: "not allowed"},
+		{line: "", wantErr: "not allowed"},
+
+		// Values must be present exactly when the flag takes one.
+		{line: "--retries", wantErr: "requires a value"},
+		{line: "--retries=", wantErr: "requires a value"},
+		{line: "--force-ipv4 yes", wantErr: "does not take a value"},
+		{line: "--force-ipv4=yes", wantErr: "does not take a value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			flag, value, _, err := parseExtraArg(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseExtraArg(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExtraArg(%q) error = %v", tt.line, err)
+			}
+			if flag != tt.wantFlag || value != tt.wantValue {
+				t.Errorf("parseExtraArg(%q) = %q, %q, want %q, %q", tt.line, flag, value, tt.wantFlag, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestValidateExtraArgs(t *testing.T) {
+	tests := []struct {
+		lines []string
+		ok    bool
+	}{
+		{nil, true},
+		{[]string{"--retries 10", "--fragment-retries infinite", "--extractor-retries=0"}, true},
+		{[]string{"--socket-timeout 2.5", "--sleep-requests 0", "--sleep-interval=1", "--max-sleep-interval 30"}, true},
+		{[]string{"--add-headers Accept-Language:en"}, true},
+
+		{[]string{"--retries many"}, false},
+		{[]string{"--retries -1"}, false},
+		{[]string{"--fragment-retries 1.5"}, false},
+		{[]string{"--socket-timeout soon"}, false},
+		{[]string{"--socket-timeout -5"}, false},
+		{[]string{"--sleep-interval NaN"}, false},
+		{[]string{"--max-sleep-interval Inf"}, false},
+		{[]string{"--add-headers no-colon"}, false},
+		// One bad line rejects the whole list.
+		{[]string{"--retries 3", "--exec id"}, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateExtraArgs(tt.lines)
+		if (err == nil) != tt.ok {
+			t.Errorf("ValidateExtraArgs(%q) error = %v, want ok %v", tt.lines, err, tt.ok)
+		}
+	}
+}
+
+func TestExtraArgsApply(t *testing.T) {
+	b := &YtDlp{Command: ytdlp.New()}
+	err := b.ExtraArgs([]string{"--retries=3", "--referer https://example.com", "--force-ipv4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := b.Command.BuildCommand(context.Background(), "https://example.com/v").Args
+	for _, want := range [][]string{{"--retries", "3"}, {"--add-headers", "Referer:https://example.com"}, {"--force-ipv4"}} {
+		i := slices.Index(args, want[0])
+		if i < 0 || !slices.Equal(args[i:min(i+len(want), len(args))], want) {
+			t.Errorf("command args %q lack %q", args, want)
+		}
+	}
+}
+
+func TestAllowedExtraArgs(t *testing.T) {
+	flags := AllowedExtraArgs()
+	if !slices.IsSorted(flags) || len(flags) != len(allowedExtraArgs) {
+		t.Errorf("AllowedExtraArgs() = %q, want every allowed flag, sorted", flags)
+	}
+	for _, f := range []string{"--exec", "-o", "--output", "--config-location", "--load-info-json"} {
+		if slices.Contains(flags, f) {
+			t.Errorf("AllowedExtraArgs() contains %q", f)
+		}
+	}
+}
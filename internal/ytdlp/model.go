@@ -28,6 +28,7 @@ type Info struct {
 	CommentCount       float64 `json:"comment_count"`        // Number of comments on the video
 	AgeLimit           float64 `json:"age_limit"`            // Age restriction for the video (years)
 	IsLive             bool    `json:"is_live"`              // Whether this video is a live stream or a fixed-length video
+	LiveStatus         string  `json:"live_status"`          // One of not_live, is_live, is_upcoming, was_live, post_live
 	StartTime          float64 `json:"start_time"`           // Time in seconds where the reproduction should start, as specified in the URL
 	EndTime            float64 `json:"end_time"`             // Time in seconds where the reproduction should end, as specified in the URL
 	Extractor          string  `json:"extractor"`            // Name of the extractor
@@ -76,8 +77,9 @@ type Info struct {
 	ThumbnailBytes []byte      `json:"-"`
 	Thumbnails     []Thumbnail `json:"thumbnails"`
 
-	Formats   []Format              `json:"formats"`
-	Subtitles map[string][]Subtitle `json:"subtitles"`
+	Formats          []Format              `json:"formats"`
+	RequestedFormats []Format              `json:"requested_formats"`
+	Subtitles        map[string][]Subtitle `json:"subtitles"`
 
 	// Playlist entries if _type is playlist
 	Entries []Info `json:"entries"`
@@ -86,6 +88,50 @@ type Info struct {
 	Format
 }
 
+// Live reports whether the info describes a live or upcoming stream.
+func (i *Info) Live() bool {
+	return i.IsLive || i.LiveStatus == "is_live" || i.LiveStatus == "is_upcoming"
+}
+
+// EstimatedSize returns the expected download size in bytes for the selected
+// format, summing video and audio when they are merged. It returns 0 if unknown.
+func (i *Info) EstimatedSize() int64 {
+	if len(i.RequestedFormats) > 0 {
+		var total int64
+		for _, f := range i.RequestedFormats {
+			size := f.size()
+			if size == 0 {
+				return 0
+			}
+			total += size
+		}
+		return total
+	}
+	return i.Format.size()
+}
+
+// HasAudio reports whether the selected format includes an audio stream.
+// An unknown codec is assumed to carry audio.
+func (i *Info) HasAudio() bool {
+	if len(i.RequestedFormats) > 0 {
+		for _, f := range i.RequestedFormats {
+			if f.ACodec != "none" {
+				return true
+			}
+		}
+		return false
+	}
+	return i.ACodec != "none"
+}
+
+// EntryURL returns the URL to download a flat playlist entry from.
+func (i *Info) EntryURL() string {
+	if i.WebpageURL != "" {
+		return i.WebpageURL
+	}
+	return i.URL
+}
+
 type Thumbnail struct {
 	ID         string `json:"id"`
 	URL        string `json:"url"`
@@ -119,6 +165,13 @@ type Format struct {
 	HTTPHeaders    map[string]string `json:"http_headers"`
 }
 
+func (f *Format) size() int64 {
+	if f.Filesize > 0 {
+		return int64(f.Filesize)
+	}
+	return int64(f.FilesizeApprox)
+}
+
 // Subtitle youtube-dl subtitle
 type Subtitle struct {
 	URL      string `json:"url"`
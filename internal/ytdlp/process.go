@@ -0,0 +1,145 @@
+package ytdlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baranovskis/go-ytdlp-bot/internal/logger"
+	"github.com/rs/zerolog"
+)
+
+// progressPrefix marks progress lines emitted through --progress-template.
+const progressPrefix = "progress:"
+
+// killWaitDelay bounds how long Run waits for pipes to close after the
+// process group has been killed.
+const killWaitDelay = 5 * time.Second
+
+type progressLine struct {
+	Info struct {
+		Format string `json:"format"`
+	} `json:"info"`
+	Progress struct {
+		Status             string  `json:"status"`
+		Filename           string  `json:"filename"`
+		DownloadedBytes    float64 `json:"downloaded_bytes"`
+		TotalBytes         float64 `json:"total_bytes"`
+		TotalBytesEstimate float64 `json:"total_bytes_estimate"`
+		ETA                float64 `json:"eta"`
+	} `json:"progress"`
+}
+
+// lineWriter splits process output into lines, handing progress lines to
+// onProgress and keeping everything else.
+type lineWriter struct {
+	mu         sync.Mutex
+	partial    bytes.Buffer
+	lines      []string
+	onProgress func(progressLine)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.partial.Write(p)
+	for {
+		data := w.partial.Bytes()
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		w.handle(strings.TrimRight(string(data[:i]), "\r"))
+		w.partial.Next(i + 1)
+	}
+	return len(p), nil
+}
+
+func (w *lineWriter) handle(line string) {
+	if raw, ok := strings.CutPrefix(line, progressPrefix); ok {
+		var p progressLine
+		if w.onProgress != nil && json.Unmarshal([]byte(raw), &p) == nil {
+			w.onProgress(p)
+		}
+		return
+	}
+	w.lines = append(w.lines, line)
+}
+
+func (w *lineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.partial.Len() > 0 {
+		w.handle(w.partial.String())
+		w.partial.Reset()
+	}
+	return strings.Join(w.lines, "\n")
+}
+
+// run executes yt-dlp with args in its own process group, so cancelling ctx
+// also kills ffmpeg and any other children it spawned, which also inherit the
+// configured process limits. It returns stdout without progress lines, and
+// logs through the logger attached to ctx, if any.
+func (b *YtDlp) run(ctx context.Context, args ...string) (string, error) {
+	log := logger.Ctx(ctx, &b.logger)
+	cmd := b.Command.BuildCommand(ctx, args...)
+	if cmd.Err != nil {
+		return "", cmd.Err
+	}
+
+	stdout := &lineWriter{onProgress: func(p progressLine) { logProgress(log, p) }}
+	stderr := &lineWriter{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = killWaitDelay
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	// Limits can only be applied once the process exists, so yt-dlp runs
+	// unrestricted for the moment between starting and this call.
+	if err := b.limits.Apply(cmd.Process.Pid); err != nil {
+		log.Warn().Str("reason", err.Error()).Msg("failed apply yt-dlp process limits")
+	}
+
+	err := cmd.Wait()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", context.Cause(ctx)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("exit code %d: %w\n\n%s", exitErr.ExitCode(), err, stderr.String())
+		}
+		return "", err
+	}
+
+	return stdout.String(), nil
+}
+
+func logProgress(log *zerolog.Logger, p progressLine) {
+	total := p.Progress.TotalBytes
+	if total == 0 {
+		total = p.Progress.TotalBytesEstimate
+	}
+	percent := "N/A"
+	if total > 0 {
+		percent = fmt.Sprintf("%.1f%%", p.Progress.DownloadedBytes/total*100)
+	}
+
+	log.Debug().
+		Str("file", p.Progress.Filename).
+		Str("format", p.Info.Format).
+		Str("percent", percent).
+		Dur("eta", time.Duration(p.Progress.ETA)*time.Second).
+		Msgf("yt-dlp - %s", p.Progress.Status)
+}
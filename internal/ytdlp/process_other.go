@@ -0,0 +1,9 @@
+//go:build !unix
+
+package ytdlp
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are unavailable; the
+// default cancellation only kills yt-dlp itself.
+func setProcessGroup(_ *exec.Cmd) {}
@@ -3,39 +3,27 @@ package ytdlp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+
 	"github.com/baranovskis/go-ytdlp-bot/internal/config"
+	"github.com/baranovskis/go-ytdlp-bot/internal/proclimit"
 	"github.com/lrstanley/go-ytdlp"
 	"github.com/rs/zerolog"
-	"time"
 )
 
 type YtDlp struct {
 	Command *ytdlp.Command
-}
-
-func buildFFmpegArgs(encoder string, threads int) string {
-	switch encoder {
-	case "h264_nvenc":
-		return "ffmpeg:-c:v h264_nvenc -preset p4 -cq 23 -pix_fmt yuv420p -c:a aac -movflags +faststart"
-	case "h264_vaapi":
-		return "ffmpeg:-vaapi_device /dev/dri/renderD128 -vf format=nv12,hwupload -c:v h264_vaapi -global_quality 23 -c:a aac -movflags +faststart"
-	case "h264_qsv":
-		return "ffmpeg:-c:v h264_qsv -preset fast -global_quality 23 -c:a aac -movflags +faststart"
-	default:
-		return fmt.Sprintf("ffmpeg:-threads %d -c:v libx264 -preset fast -crf 23 -pix_fmt yuv420p -c:a aac -movflags +faststart", threads)
-	}
+	limits  proclimit.Limits
+	logger  zerolog.Logger
 }
 
 func Init(cfg *config.Config, log zerolog.Logger) *YtDlp {
 	maxHeight := cfg.Video.GetMaxHeight()
-	threads := cfg.Video.GetThreads()
-	encoder := cfg.Video.GetEncoder()
 
 	log.Info().
-		Str("encoder", encoder).
 		Int("max_height", maxHeight).
-		Int("threads", threads).
 		Msg("video settings initialized")
 
 	command := ytdlp.New().
@@ -46,45 +34,133 @@ func Init(cfg *config.Config, log zerolog.Logger) *YtDlp {
 			maxHeight, maxHeight, maxHeight,
 		)).
 		MergeOutputFormat("mp4").
-		RecodeVideo("mp4").
-		PostProcessorArgs(buildFFmpegArgs(encoder, threads)).
 		NoOverwrites().
 		NoPlaylist().
 		PlaylistItems("1:1").
 		ConcurrentFragments(5).
 		Continue().
-		NoProgress().
-		ProgressFunc(100*time.Millisecond, func(prog ytdlp.ProgressUpdate) {
-			log.Debug().
-				Str("file", prog.Filename).
-				Str("format", prog.Info.Format).
-				Str("percent", prog.PercentString()).
-				Dur("eta", prog.ETA()).
-				Msgf("yt-dlp - %s", prog.Status)
-		}).
+		Progress().
+		ProgressDelta(0.1).
+		ProgressTemplate(progressPrefix + "%()j").
+		Newline().
 		SetWorkDir(cfg.Storage.Path).
-		Output("%(extractor)s_%(id)s.%(ext)s").
+		Output(outputTemplate("")).
 		PrintJSON()
 
 	return &YtDlp{
 		Command: command,
+		limits:  cfg.Resources.ProcessLimits(),
+		logger:  log,
 	}
 }
 
+// outputTemplate names downloads after the extractor and video ID, followed
+// by tag when it is not empty.
+func outputTemplate(tag string) string {
+	if tag == "" {
+		return "%(extractor)s_%(id)s.%(ext)s"
+	}
+	return "%(extractor)s_%(id)s." + tag + ".%(ext)s"
+}
+
+// Tag adds tag to the name of the downloaded file, so downloads of the same
+// video that are processed differently never share files.
+func (b *YtDlp) Tag(tag string) {
+	b.Command.Output(outputTemplate(tag))
+}
+
 func (b *YtDlp) Cookies(file string) {
 	b.Command.Cookies(file)
 }
 
+func (b *YtDlp) Proxy(url string) {
+	b.Command.Proxy(url)
+}
+
+func (b *YtDlp) Impersonate(client string) {
+	b.Command.Impersonate(client)
+}
+
+func (b *YtDlp) UserAgent(ua string) {
+	b.Command.AddHeaders("User-Agent:" + ua)
+}
+
+func (b *YtDlp) LimitRate(rate string) {
+	b.Command.LimitRate(rate)
+}
+
+// Format replaces the default height-capped format selector.
+func (b *YtDlp) Format(format string) {
+	b.Command.Format(format)
+}
+
+func (b *YtDlp) GeoBypassCountry(code string) {
+	b.Command.GeoBypassCountry(code)
+}
+
+// ExtraArgs applies allow-listed yt-dlp flags, one "--flag value" per line.
+func (b *YtDlp) ExtraArgs(lines []string) error {
+	for _, line := range lines {
+		flag, value, arg, err := parseExtraArg(line)
+		if err != nil {
+			return err
+		}
+		if err := arg.apply(b.Command, value); err != nil {
+			return fmt.Errorf("yt-dlp flag %q: %w", flag, err)
+		}
+	}
+	return nil
+}
+
+// Probe fetches metadata for url without downloading, using the same
+// options as the download so format selection and size estimates match.
+func (b *YtDlp) Probe(ctx context.Context, url string) (*Info, error) {
+	probe := *b
+	probe.Command = b.Command.Clone().
+		UnsetPrintJSON().
+		UnsetProgress().
+		UnsetProgressTemplate().
+		UnsetProgressDelta().
+		DumpJSON().
+		SkipDownload()
+	return probe.Run(ctx, url)
+}
+
+// Playlist lists the entries of a playlist or channel URL without resolving
+// each video. items uses yt-dlp's --playlist-items syntax, e.g. "1:10".
+func (b *YtDlp) Playlist(ctx context.Context, url, items string) (*Info, error) {
+	list := *b
+	list.Command = b.Command.Clone().
+		UnsetPrintJSON().
+		UnsetProgress().
+		UnsetProgressTemplate().
+		UnsetProgressDelta().
+		YesPlaylist().
+		PlaylistItems(items).
+		FlatPlaylist().
+		DumpSingleJSON().
+		SkipDownload()
+	return list.Run(ctx, url)
+}
+
 func (b *YtDlp) Run(ctx context.Context, url ...string) (*Info, error) {
-	r, err := b.Command.Run(ctx, url...)
+	stdout, err := b.run(ctx, url...)
 	if err != nil {
 		return nil, err
 	}
 
-	var info Info
-	if err = json.Unmarshal([]byte(r.Stdout), &info); err != nil {
-		return nil, err
+	// --print-json and --dump-json emit the info dict as the last JSON line on stdout.
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(lines[i], "{") {
+			continue
+		}
+		var info Info
+		if err := json.Unmarshal([]byte(lines[i]), &info); err != nil {
+			return nil, err
+		}
+		return &info, nil
 	}
 
-	return &info, nil
+	return nil, errors.New("yt-dlp returned no video info")
 }